@@ -93,8 +93,9 @@ func main() {
 		return
 	}
 
-	// Generar firma HMAC-SHA256
-	signature := generateSignature(secretKey, payloadBytes)
+	// Generar firma HMAC-SHA256 (v1 firma timestamp + "." + payload)
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+	signature := generateSignature(secretKey, timestamp, payloadBytes)
 
 	// Crear petición HTTP
 	req, err := http.NewRequest("POST", webhookURL, bytes.NewBuffer(payloadBytes))
@@ -106,7 +107,7 @@ func main() {
 	// Agregar headers
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-Webhook-Signature", signature)
-	req.Header.Set("X-Webhook-Timestamp", time.Now().UTC().Format(time.RFC3339))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
 	req.Header.Set("X-Webhook-ID", "12345")
 	req.Header.Set("X-Idempotency-Key", fmt.Sprintf("test-%d", time.Now().Unix()))
 
@@ -144,9 +145,11 @@ func main() {
 	}
 }
 
-// generateSignature genera la firma HMAC-SHA256 del payload
-func generateSignature(secretKey string, payload []byte) string {
+// generateSignature genera el header de firma con el esquema v1 (HMAC-SHA256 de timestamp + "." + payload)
+// Para receptores que aún no soportan v1 se puede enviar también la firma legacy: "v0=<hex>,v1=<hex>"
+func generateSignature(secretKey, timestamp string, payload []byte) string {
 	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte(timestamp + "."))
 	mac.Write(payload)
-	return hex.EncodeToString(mac.Sum(nil))
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
 }
@@ -0,0 +1,66 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Mode es el modo de contenido con el que se emite un CloudEvent
+type Mode string
+
+// Modos de contenido de CloudEvents. ModeNone emite el webhook original, sin CloudEvents.
+const (
+	ModeNone       Mode = ""
+	ModeStructured Mode = "structured"
+	ModeBinary     Mode = "binary"
+)
+
+// ContentTypeStructured es el Content-Type del modo estructurado
+const ContentTypeStructured = "application/cloudevents+json"
+
+// Prefijos de los headers de los atributos en modo binario según el protocolo
+const (
+	HTTPHeaderPrefix  = "ce-"
+	KafkaHeaderPrefix = "ce_"
+)
+
+// ParseMode valida un modo de contenido ("", "structured" o "binary")
+func ParseMode(value string) (Mode, error) {
+	switch mode := Mode(value); mode {
+	case ModeNone, ModeStructured, ModeBinary:
+		return mode, nil
+	default:
+		return ModeNone, fmt.Errorf("unknown CloudEvents mode %q (expected structured or binary)", value)
+	}
+}
+
+// Message es un CloudEvent codificado para un protocolo
+type Message struct {
+	Body        []byte
+	ContentType string
+	// Headers contiene los atributos en modo binario, con el prefijo del protocolo
+	Headers map[string]string
+}
+
+// Encode codifica e en mode. En modo estructurado el body es el CloudEvent completo en JSON;
+// en modo binario el body son los datos y los atributos viajan como headers con headerPrefix
+// (HTTPHeaderPrefix en HTTP y NATS, KafkaHeaderPrefix en Kafka).
+func (e Event) Encode(mode Mode, headerPrefix string) (Message, error) {
+	switch mode {
+	case ModeStructured:
+		body, err := json.Marshal(e)
+		if err != nil {
+			return Message{}, fmt.Errorf("failed to encode CloudEvent: %w", err)
+		}
+		return Message{Body: body, ContentType: ContentTypeStructured}, nil
+	case ModeBinary:
+		attrs := e.attributes()
+		headers := make(map[string]string, len(attrs))
+		for name, value := range attrs {
+			headers[headerPrefix+name] = value
+		}
+		return Message{Body: e.Data, ContentType: e.DataContentType, Headers: headers}, nil
+	default:
+		return Message{}, fmt.Errorf("unknown CloudEvents mode %q", mode)
+	}
+}
@@ -0,0 +1,109 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEncode(t *testing.T) {
+	event := Event{
+		SpecVersion:     SpecVersion,
+		ID:              "key-1",
+		Source:          SourcePrefix + "12345",
+		Type:            TypePrefix + "bill.paid",
+		Subject:         "2001",
+		Time:            time.Date(2025, 10, 9, 10, 0, 0, 500, time.UTC),
+		DataContentType: "application/json",
+		Data:            json.RawMessage(`{"webhook_id":12345}`),
+	}
+
+	tests := []struct {
+		name            string
+		event           Event
+		mode            Mode
+		prefix          string
+		wantContentType string
+		wantBody        string
+		wantHeaders     map[string]string
+		wantErr         bool
+	}{
+		{
+			name:            "structured",
+			event:           event,
+			mode:            ModeStructured,
+			prefix:          HTTPHeaderPrefix,
+			wantContentType: ContentTypeStructured,
+			wantBody: `{"specversion":"1.0","id":"key-1","source":"/bia-consumptions/webhooks/12345","type":"co.bia.bill.paid",` +
+				`"subject":"2001","time":"2025-10-09T10:00:00.0000005Z","datacontenttype":"application/json","data":{"webhook_id":12345}}`,
+		},
+		{
+			name:            "binary over HTTP",
+			event:           event,
+			mode:            ModeBinary,
+			prefix:          HTTPHeaderPrefix,
+			wantContentType: "application/json",
+			wantBody:        `{"webhook_id":12345}`,
+			wantHeaders: map[string]string{
+				"ce-specversion": "1.0",
+				"ce-id":          "key-1",
+				"ce-source":      "/bia-consumptions/webhooks/12345",
+				"ce-type":        "co.bia.bill.paid",
+				"ce-subject":     "2001",
+				"ce-time":        "2025-10-09T10:00:00.0000005Z",
+			},
+		},
+		{
+			name: "binary over Kafka without subject",
+			event: func() Event {
+				e := event
+				e.Subject = ""
+				return e
+			}(),
+			mode:            ModeBinary,
+			prefix:          KafkaHeaderPrefix,
+			wantContentType: "application/json",
+			wantBody:        `{"webhook_id":12345}`,
+			wantHeaders: map[string]string{
+				"ce_specversion": "1.0",
+				"ce_id":          "key-1",
+				"ce_source":      "/bia-consumptions/webhooks/12345",
+				"ce_type":        "co.bia.bill.paid",
+				"ce_time":        "2025-10-09T10:00:00.0000005Z",
+			},
+		},
+		{name: "no mode", event: event, mode: ModeNone, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := tt.event.Encode(tt.mode, tt.prefix)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Encode() error = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if msg.ContentType != tt.wantContentType || string(msg.Body) != tt.wantBody {
+				t.Errorf("Encode() = %s (%s), want %s (%s)", msg.Body, msg.ContentType, tt.wantBody, tt.wantContentType)
+			}
+			if !reflect.DeepEqual(msg.Headers, tt.wantHeaders) {
+				t.Errorf("headers = %v, want %v", msg.Headers, tt.wantHeaders)
+			}
+		})
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for value, want := range map[string]Mode{"": ModeNone, "structured": ModeStructured, "binary": ModeBinary} {
+		if got, err := ParseMode(value); err != nil || got != want {
+			t.Errorf("ParseMode(%q) = %q, %v, want %q", value, got, err, want)
+		}
+	}
+	if _, err := ParseMode("batched"); err == nil {
+		t.Error("ParseMode(batched) error = nil, want an error")
+	}
+}
@@ -0,0 +1,91 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"webhook_receiver/internal/dto"
+	"webhook_receiver/internal/idempotency"
+	"webhook_receiver/internal/processor"
+)
+
+// SpecVersion es la versión de CloudEvents que se emite
+const SpecVersion = "1.0"
+
+// TypePrefix antecede al tipo de cada evento: co.bia.consumption.hourly, co.bia.bill.paid, ...
+const TypePrefix = "co.bia."
+
+// SourcePrefix antecede al webhook_id en el atributo source
+const SourcePrefix = "/bia-consumptions/webhooks/"
+
+// Event es un CloudEvent 1.0 con datos JSON
+type Event struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// FromEvent convierte un webhook decodificado en un CloudEvent:
+//
+//   - type: co.bia.consumption.<send_interval> o co.bia.bill.<trigger_type>
+//   - source: SourcePrefix + webhook_id
+//   - subject: contract_id
+//   - time: timestamp del payload (o la hora de recepción si el payload no lo trae)
+//   - id: X-Idempotency-Key, o idempotency.HashBody del body si no viene, para que las
+//     reentregas del mismo webhook conserven el id
+//   - data: el body original del webhook
+func FromEvent(event processor.Event) Event {
+	ce := Event{
+		SpecVersion:     SpecVersion,
+		ID:              event.Headers.IDKey,
+		Source:          SourcePrefix + strconv.Itoa(event.WebhookID),
+		Type:            TypePrefix + event.DataType,
+		Subject:         strconv.Itoa(event.ContractID),
+		Time:            event.ReceivedAt,
+		DataContentType: "application/json",
+		Data:            json.RawMessage(event.Body),
+	}
+
+	var timestamp time.Time
+	switch payload := event.Payload.(type) {
+	case dto.WebhookPayload:
+		ce.Type = TypePrefix + "consumption." + payload.SendInterval
+		timestamp = payload.Timestamp
+	case dto.BillWebhookPayload:
+		ce.Type = TypePrefix + "bill." + payload.TriggerType
+		timestamp = payload.Timestamp
+	}
+	if !timestamp.IsZero() {
+		ce.Time = timestamp
+	}
+
+	if ce.ID == "" {
+		ce.ID = idempotency.HashBody(event.Body)
+	}
+	if event.ContractID == 0 {
+		ce.Subject = ""
+	}
+	ce.Time = ce.Time.UTC()
+	return ce
+}
+
+// attributes retorna los atributos de contexto del evento, sin datacontenttype ni data
+func (e Event) attributes() map[string]string {
+	attrs := map[string]string{
+		"specversion": e.SpecVersion,
+		"id":          e.ID,
+		"source":      e.Source,
+		"type":        e.Type,
+		"time":        e.Time.Format(time.RFC3339Nano),
+	}
+	if e.Subject != "" {
+		attrs["subject"] = e.Subject
+	}
+	return attrs
+}
@@ -0,0 +1,104 @@
+package cloudevents
+
+import (
+	"testing"
+	"time"
+
+	"webhook_receiver/internal/dto"
+	"webhook_receiver/internal/idempotency"
+	"webhook_receiver/internal/processor"
+)
+
+func TestFromEvent(t *testing.T) {
+	receivedAt := time.Date(2025, 10, 9, 12, 0, 0, 0, time.UTC)
+	timestamp := time.Date(2025, 10, 9, 5, 0, 0, 0, time.FixedZone("COT", -5*3600))
+	body := []byte(`{"webhook_id":12345}`)
+
+	tests := []struct {
+		name        string
+		event       processor.Event
+		wantType    string
+		wantSubject string
+		wantTime    time.Time
+		wantID      string
+	}{
+		{
+			name: "hourly consumption",
+			event: processor.Event{
+				DataType: "consumption", WebhookID: 12345, ContractID: 1001, Body: body, ReceivedAt: receivedAt,
+				Headers: dto.WebhookHeaders{IDKey: "key-1"},
+				Payload: dto.WebhookPayload{SendInterval: dto.SendIntervalHourly, Timestamp: timestamp},
+			},
+			wantType:    "co.bia.consumption.hourly",
+			wantSubject: "1001",
+			wantTime:    timestamp.UTC(),
+			wantID:      "key-1",
+		},
+		{
+			name: "monthly consumption",
+			event: processor.Event{
+				DataType: "consumption", WebhookID: 12345, ContractID: 1001, Body: body, ReceivedAt: receivedAt,
+				Payload: dto.WebhookPayload{SendInterval: dto.SendIntervalMonthly, Timestamp: timestamp},
+			},
+			wantType:    "co.bia.consumption.monthly",
+			wantSubject: "1001",
+			wantTime:    timestamp.UTC(),
+			wantID:      idempotency.HashBody(body),
+		},
+		{
+			name: "paid bill",
+			event: processor.Event{
+				DataType: "bills", WebhookID: 12345, ContractID: 2001, Body: body, ReceivedAt: receivedAt,
+				Headers: dto.WebhookHeaders{IDKey: "key-2"},
+				Payload: dto.BillWebhookPayload{TriggerType: dto.TriggerTypePaid, Timestamp: timestamp},
+			},
+			wantType:    "co.bia.bill.paid",
+			wantSubject: "2001",
+			wantTime:    timestamp.UTC(),
+			wantID:      "key-2",
+		},
+		{
+			name: "available bill without timestamp",
+			event: processor.Event{
+				DataType: "bills", WebhookID: 12345, ContractID: 2001, Body: body, ReceivedAt: receivedAt,
+				Payload: dto.BillWebhookPayload{TriggerType: dto.TriggerTypeAvailable},
+			},
+			wantType:    "co.bia.bill.available",
+			wantSubject: "2001",
+			wantTime:    receivedAt,
+			wantID:      idempotency.HashBody(body),
+		},
+		{
+			name: "other data type without contract",
+			event: processor.Event{
+				DataType: "meters", WebhookID: 12345, Body: body, ReceivedAt: receivedAt,
+			},
+			wantType: "co.bia.meters",
+			wantTime: receivedAt,
+			wantID:   idempotency.HashBody(body),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ce := FromEvent(tt.event)
+			if ce.SpecVersion != SpecVersion || ce.Source != "/bia-consumptions/webhooks/12345" {
+				t.Errorf("specversion = %q, source = %q", ce.SpecVersion, ce.Source)
+			}
+			if ce.Type != tt.wantType {
+				t.Errorf("type = %q, want %q", ce.Type, tt.wantType)
+			}
+			if ce.Subject != tt.wantSubject {
+				t.Errorf("subject = %q, want %q", ce.Subject, tt.wantSubject)
+			}
+			if !ce.Time.Equal(tt.wantTime) || ce.Time.Location() != time.UTC {
+				t.Errorf("time = %v, want %v in UTC", ce.Time, tt.wantTime)
+			}
+			if ce.ID != tt.wantID {
+				t.Errorf("id = %q, want %q", ce.ID, tt.wantID)
+			}
+			if ce.DataContentType != "application/json" || string(ce.Data) != string(body) {
+				t.Errorf("data = %s (%s), want the original body", ce.Data, ce.DataContentType)
+			}
+		})
+	}
+}
@@ -0,0 +1,366 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"webhook_receiver/internal/forwarder"
+	"webhook_receiver/internal/idempotency"
+	"webhook_receiver/internal/inbox"
+	"webhook_receiver/internal/invoice"
+	"webhook_receiver/internal/middleware"
+	"webhook_receiver/internal/sink"
+	"webhook_receiver/internal/worker"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joho/godotenv"
+	toml "github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// DevelopmentSecretKey es la clave de firma que se usa fuera de producción cuando no se
+// configura ninguna. En producción (modo release) el receptor no arranca con ella.
+const DevelopmentSecretKey = "secret_key"
+
+// Config reúne toda la configuración del receptor. Se carga con Load: valores por defecto,
+// luego el archivo de configuración (YAML o TOML), luego las variables de entorno y por
+// último los flags de la línea de comandos.
+type Config struct {
+	// Mode es el modo de Gin: debug, release (producción) o test
+	Mode string `yaml:"mode"`
+	Port string `yaml:"port"`
+	// ShutdownTimeout es el tiempo máximo para terminar las peticiones y los webhooks
+	// encolados al recibir SIGINT o SIGTERM
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+
+	Log         LogConfig         `yaml:"log"`
+	Webhook     WebhookConfig     `yaml:"webhook"`
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	Inbox       InboxConfig       `yaml:"inbox"`
+	DeadLetter  DeadLetterConfig  `yaml:"dead_letter"`
+	Retry       RetryConfig       `yaml:"retry"`
+	Async       AsyncConfig       `yaml:"async"`
+	// AdminToken protege los endpoints /admin; vacío los desactiva
+	AdminToken string         `yaml:"admin_token"`
+	Database   DatabaseConfig `yaml:"database"`
+	Invoice    InvoiceConfig  `yaml:"invoice"`
+	Forward    ForwardConfig  `yaml:"forward"`
+	Sink       SinkConfig     `yaml:"sink"`
+	Hooks      HooksConfig    `yaml:"hooks"`
+	Metrics    MetricsConfig  `yaml:"metrics"`
+	Tracing    TracingConfig  `yaml:"tracing"`
+}
+
+// LogConfig configura los logs estructurados
+type LogConfig struct {
+	Level  string `yaml:"level"`  // debug, info, warn o error
+	Format string `yaml:"format"` // text o json
+}
+
+// WebhookConfig configura la verificación de firma
+type WebhookConfig struct {
+	// SecretKey es la clave única de firma; se ignora si hay SecretsFile
+	SecretKey string `yaml:"secret_key"`
+	// SecretsFile es el archivo de secretos con vigencia, por webhook_id y por contract_id
+	SecretsFile        string        `yaml:"secrets_file"`
+	TimestampTolerance time.Duration `yaml:"timestamp_tolerance"`
+	// SignatureSchemes son los esquemas aceptados (vacío = middleware.DefaultSignatureSchemes)
+	SignatureSchemes []string `yaml:"signature_schemes"`
+	// LegacyReplayWindow es cuánto se recuerda una firma v0, que no autentica el timestamp
+	LegacyReplayWindow time.Duration `yaml:"legacy_replay_window"`
+}
+
+// IdempotencyConfig configura el store de idempotencia
+type IdempotencyConfig struct {
+	Store       string        `yaml:"store"` // memory o file
+	Dir         string        `yaml:"dir"`
+	TTL         time.Duration `yaml:"ttl"`
+	LockTimeout time.Duration `yaml:"lock_timeout"`
+	MaxKeys     int           `yaml:"max_keys"`
+}
+
+// InboxConfig configura el inbox durable; Dir vacío lo desactiva
+type InboxConfig struct {
+	Dir         string `yaml:"dir"`
+	SegmentSize int    `yaml:"segment_size"`
+}
+
+// DeadLetterConfig configura el dead-letter queue
+type DeadLetterConfig struct {
+	Store string `yaml:"store"` // memory o file
+	Dir   string `yaml:"dir"`
+}
+
+// RetryConfig es una política de reintentos con backoff exponencial
+type RetryConfig struct {
+	MaxAttempts    int           `yaml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+}
+
+// Policy convierte la configuración en un worker.RetryPolicy
+func (r RetryConfig) Policy() worker.RetryPolicy {
+	return worker.RetryPolicy{
+		MaxAttempts:    r.MaxAttempts,
+		InitialBackoff: r.InitialBackoff,
+		MaxBackoff:     r.MaxBackoff,
+	}
+}
+
+// AsyncConfig configura el modo asíncrono
+type AsyncConfig struct {
+	Enabled   bool `yaml:"enabled"`
+	Workers   int  `yaml:"workers"`
+	QueueSize int  `yaml:"queue_size"`
+	// DataTypes sobrescribe Workers y QueueSize para un data_type (0 = el valor general)
+	DataTypes map[string]QueueConfig `yaml:"data_types"`
+}
+
+// QueueConfig es el tamaño del pool y de la cola de un data_type
+type QueueConfig struct {
+	Workers   int `yaml:"workers"`
+	QueueSize int `yaml:"queue_size"`
+}
+
+// DatabaseConfig configura la base de datos SQLite; Path vacío la desactiva
+type DatabaseConfig struct {
+	Path string `yaml:"path"`
+}
+
+// InvoiceConfig configura la descarga del XML de las facturas electrónicas
+type InvoiceConfig struct {
+	FetchEnabled  bool          `yaml:"fetch_enabled"`
+	FetchAttempts int           `yaml:"fetch_attempts"`
+	FetchTimeout  time.Duration `yaml:"fetch_timeout"`
+	MaxSize       int           `yaml:"max_size"`
+	AllowedHosts  []string      `yaml:"allowed_hosts"`
+}
+
+// ForwardConfig configura el reenvío a servicios internos; TargetsFile vacío lo desactiva
+type ForwardConfig struct {
+	TargetsFile      string        `yaml:"targets_file"`
+	Retry            RetryConfig   `yaml:"retry"`
+	BreakerThreshold int           `yaml:"breaker_threshold"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown"`
+}
+
+// SinkConfig configura la publicación en un broker; Type vacío la desactiva
+type SinkConfig struct {
+	Type           string        `yaml:"type"` // kafka o nats
+	KafkaBrokers   []string      `yaml:"kafka_brokers"`
+	NATSURL        string        `yaml:"nats_url"`
+	TopicPrefix    string        `yaml:"topic_prefix"`
+	PublishTimeout time.Duration `yaml:"publish_timeout"`
+	CloudEvents    string        `yaml:"cloudevents"` // structured o binary
+	// Topics asigna un topic a un data_type en lugar de TopicPrefix + data_type
+	Topics map[string]string `yaml:"topics"`
+}
+
+// HooksConfig configura los comandos por evento; File vacío los desactiva
+type HooksConfig struct {
+	File string `yaml:"file"`
+}
+
+// MetricsConfig configura las métricas de Prometheus
+type MetricsConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// TracingConfig configura las trazas de OpenTelemetry; Exporter vacío las desactiva
+type TracingConfig struct {
+	Exporter    string `yaml:"exporter"` // otlp o stdout
+	ServiceName string `yaml:"service_name"`
+}
+
+// Default retorna la configuración por defecto
+func Default() *Config {
+	return &Config{
+		Mode:            gin.DebugMode,
+		Port:            "8080",
+		ShutdownTimeout: 30 * time.Second,
+		Log:             LogConfig{Level: "info", Format: "text"},
+		Webhook: WebhookConfig{
+			TimestampTolerance: middleware.DefaultTimestampTolerance,
+			LegacyReplayWindow: middleware.DefaultLegacyReplayWindow,
+		},
+		Idempotency: IdempotencyConfig{
+			Store:       "memory",
+			Dir:         "data/idempotency",
+			TTL:         idempotency.DefaultTTL,
+			LockTimeout: idempotency.DefaultLockTimeout,
+			MaxKeys:     idempotency.DefaultMaxEntries,
+		},
+		Inbox:      InboxConfig{SegmentSize: inbox.DefaultSegmentSize},
+		DeadLetter: DeadLetterConfig{Store: "memory", Dir: "data/dead-letters"},
+		Retry: RetryConfig{
+			MaxAttempts:    worker.DefaultMaxAttempts,
+			InitialBackoff: worker.DefaultInitialBackoff,
+			MaxBackoff:     worker.DefaultMaxBackoff,
+		},
+		Async: AsyncConfig{
+			Workers:   worker.DefaultWorkers,
+			QueueSize: worker.DefaultQueueSize,
+		},
+		Invoice: InvoiceConfig{
+			FetchAttempts: invoice.DefaultMaxAttempts,
+			FetchTimeout:  invoice.DefaultTimeout,
+			MaxSize:       invoice.DefaultMaxSize,
+		},
+		Forward: ForwardConfig{
+			Retry: RetryConfig{
+				MaxAttempts:    forwarder.DefaultRetryPolicy.MaxAttempts,
+				InitialBackoff: forwarder.DefaultRetryPolicy.InitialBackoff,
+				MaxBackoff:     forwarder.DefaultRetryPolicy.MaxBackoff,
+			},
+			BreakerThreshold: forwarder.DefaultBreakerThreshold,
+			BreakerCooldown:  forwarder.DefaultBreakerCooldown,
+		},
+		Sink: SinkConfig{
+			NATSURL:        "nats://localhost:4222",
+			TopicPrefix:    sink.DefaultTopicPrefix,
+			PublishTimeout: sink.DefaultPublishTimeout,
+		},
+		Metrics: MetricsConfig{Enabled: true},
+		Tracing: TracingConfig{ServiceName: "webhook-receiver"},
+	}
+}
+
+// Production indica si el receptor corre en producción (modo release)
+func (c *Config) Production() bool {
+	mode, err := ParseMode(c.Mode)
+	return err == nil && mode == gin.ReleaseMode
+}
+
+// Load arma la configuración a partir de args (normalmente os.Args[1:]). Carga el archivo
+// .env si existe, el archivo de -config (o CONFIG_FILE), las variables de entorno y los
+// flags, en ese orden de prioridad creciente, y valida el resultado. Cada variable de
+// entorno tiene un flag equivalente (IDEMPOTENCY_TTL es -idempotency-ttl, GIN_MODE es -mode);
+// los overrides por data_type (ASYNC_WORKERS_<DATA_TYPE>) solo se leen del archivo y el entorno.
+func Load(args []string) (*Config, error) {
+	flags := flag.NewFlagSet("webhook-receiver", flag.ContinueOnError)
+	path := flags.String("config", "", "configuration file (YAML or TOML)")
+	values := make(map[string]*string)
+	for _, key := range envKeys() {
+		name := flagName(key)
+		if name == "" {
+			continue
+		}
+		values[key] = flags.String(name, "", "overrides "+key)
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load .env file: %w", err)
+	}
+
+	cfg := Default()
+	if *path == "" {
+		*path = os.Getenv("CONFIG_FILE")
+	}
+	if *path != "" {
+		if err := cfg.loadFile(*path); err != nil {
+			return nil, err
+		}
+	}
+	envErr := cfg.loadEnv(os.LookupEnv, os.Environ())
+
+	// Los flags tienen prioridad sobre el archivo y el entorno. Se aplican con el mismo
+	// lector que las variables de entorno, así cada clave se valida igual en ambas fuentes.
+	visited := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { visited[f.Name] = true })
+	flagErr := cfg.loadEnv(func(key string) (string, bool) {
+		value, ok := values[key]
+		if !ok || !visited[flagName(key)] {
+			return "", false
+		}
+		return *value, true
+	}, nil)
+
+	if flagErr != nil {
+		flagErr = fmt.Errorf("invalid flags: %w", flagErr)
+	}
+	if err := errors.Join(envErr, flagErr, cfg.Validate()); err != nil {
+		return nil, err
+	}
+	cfg.Mode, _ = ParseMode(cfg.Mode)
+	return cfg, nil
+}
+
+// envKeys retorna las variables de entorno que lee loadEnv, en orden
+func envKeys() []string {
+	var keys []string
+	(&Config{}).loadEnv(func(key string) (string, bool) {
+		keys = append(keys, key)
+		return "", false
+	}, nil)
+	return keys
+}
+
+// flagName retorna el flag equivalente a una variable de entorno (IDEMPOTENCY_TTL es
+// -idempotency-ttl); retorna "" para las variables sin flag
+func flagName(key string) string {
+	switch key {
+	case "GIN_MODE":
+		return "mode"
+	case "GO_ENV":
+		// Alias de GIN_MODE: -mode ya lo cubre
+		return ""
+	}
+	return strings.ToLower(strings.ReplaceAll(key, "_", "-"))
+}
+
+// loadFile aplica el archivo de configuración sobre c. El formato se detecta por la
+// extensión: .toml es TOML y cualquier otra YAML.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("configuration file %s not found", path)
+		}
+		return fmt.Errorf("failed to read configuration file: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		// Las duraciones se escriben como texto ("24h"), que go-toml no convierte a
+		// time.Duration: el documento se pasa por YAML para usar un solo juego de tags
+		var doc map[string]interface{}
+		if err := toml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("invalid configuration file %s: %w", path, err)
+		}
+		if data, err = yaml.Marshal(doc); err != nil {
+			return fmt.Errorf("invalid configuration file %s: %w", path, err)
+		}
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid configuration file %s: %w", path, err)
+	}
+	return nil
+}
+
+// ParseMode normaliza el modo de ejecución. Acepta los modos de Gin y los alias de GO_ENV
+// (production/prod, development/dev); vacío es debug.
+func ParseMode(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "", gin.DebugMode, "development", "dev":
+		return gin.DebugMode, nil
+	case gin.ReleaseMode, "production", "prod":
+		return gin.ReleaseMode, nil
+	case gin.TestMode:
+		return gin.TestMode, nil
+	default:
+		return "", fmt.Errorf("invalid mode %q (expected debug, release or test)", value)
+	}
+}
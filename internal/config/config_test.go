@@ -0,0 +1,216 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv deja vacías las variables que lee Load, para que el entorno de quien corre las
+// pruebas no cambie el resultado
+func clearEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+	for _, key := range envKeys() {
+		t.Setenv(key, "")
+	}
+}
+
+// writeFile crea un archivo de configuración con name (la extensión define el formato)
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadLayering(t *testing.T) {
+	yamlFile := `
+port: "9000"
+log:
+  level: warn
+webhook:
+  timestamp_tolerance: 2m
+async:
+  enabled: true
+  data_types:
+    bills:
+      workers: 2
+`
+	tomlFile := `
+port = "9100"
+
+[idempotency]
+ttl = "12h"
+`
+
+	tests := []struct {
+		name    string
+		file    string // nombre del archivo y contenido, separados por ":"
+		useFlag bool   // pasar el archivo con -config en lugar de CONFIG_FILE
+		env     map[string]string
+		args    []string
+		check   func(t *testing.T, cfg *Config)
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, cfg *Config) {
+				want := Default()
+				if cfg.Port != want.Port || cfg.Mode != want.Mode || cfg.Webhook.TimestampTolerance != want.Webhook.TimestampTolerance ||
+					cfg.ShutdownTimeout != want.ShutdownTimeout || cfg.Async.Enabled {
+					t.Errorf("Load() = %+v, want the defaults", cfg)
+				}
+			},
+		},
+		{
+			name: "yaml file",
+			file: "config.yaml:" + yamlFile,
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Port != "9000" || cfg.Log.Level != "warn" || cfg.Webhook.TimestampTolerance != 2*time.Minute {
+					t.Errorf("port = %s, log level = %s, tolerance = %s", cfg.Port, cfg.Log.Level, cfg.Webhook.TimestampTolerance)
+				}
+				if cfg.Async.DataTypes["bills"].Workers != 2 {
+					t.Errorf("bills workers = %d, want 2", cfg.Async.DataTypes["bills"].Workers)
+				}
+				// Los valores que el archivo no define conservan el valor por defecto
+				if cfg.Log.Format != "text" {
+					t.Errorf("log format = %s, want the default", cfg.Log.Format)
+				}
+			},
+		},
+		{
+			name: "toml file",
+			file: "config.toml:" + tomlFile,
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Port != "9100" || cfg.Idempotency.TTL != 12*time.Hour {
+					t.Errorf("port = %s, idempotency ttl = %s", cfg.Port, cfg.Idempotency.TTL)
+				}
+			},
+		},
+		{
+			name: "env overrides file",
+			file: "config.yaml:" + yamlFile,
+			env:  map[string]string{"PORT": "9001", "ASYNC_WORKERS_BILLS": "3", "WEBHOOK_SIGNATURE_SCHEMES": "v1"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Port != "9001" || cfg.Log.Level != "warn" {
+					t.Errorf("port = %s, log level = %s", cfg.Port, cfg.Log.Level)
+				}
+				if cfg.Async.DataTypes["bills"].Workers != 3 {
+					t.Errorf("bills workers = %d, want 3", cfg.Async.DataTypes["bills"].Workers)
+				}
+				if len(cfg.Webhook.SignatureSchemes) != 1 || cfg.Webhook.SignatureSchemes[0] != "v1" {
+					t.Errorf("signature schemes = %v, want [v1]", cfg.Webhook.SignatureSchemes)
+				}
+			},
+		},
+		{
+			name: "flags override env and file",
+			file: "config.yaml:" + yamlFile,
+			env:  map[string]string{"PORT": "9001", "LOG_LEVEL": "error"},
+			args: []string{"-port", "9002", "-webhook-timestamp-tolerance", "90s"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Port != "9002" || cfg.Log.Level != "error" || cfg.Webhook.TimestampTolerance != 90*time.Second {
+					t.Errorf("port = %s, log level = %s, tolerance = %s", cfg.Port, cfg.Log.Level, cfg.Webhook.TimestampTolerance)
+				}
+			},
+		},
+		{
+			name:    "config file from flag",
+			file:    "config.yaml:" + yamlFile,
+			useFlag: true,
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Port != "9000" {
+					t.Errorf("port = %s, want the one from the file", cfg.Port)
+				}
+			},
+		},
+		{
+			name: "GIN_MODE over GO_ENV and -mode over both",
+			env:  map[string]string{"GO_ENV": "production", "GIN_MODE": "test"},
+			args: []string{"-mode", "dev"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Mode != "debug" {
+					t.Errorf("mode = %s, want debug", cfg.Mode)
+				}
+			},
+		},
+		{
+			name:    "unknown file field",
+			file:    "config.yaml:" + "port: \"9000\"\nbogus: true\n",
+			wantErr: "field bogus not found",
+		},
+		{
+			name:    "missing file",
+			env:     map[string]string{"CONFIG_FILE": "/nonexistent/config.yaml"},
+			wantErr: "not found",
+		},
+		{
+			name:    "invalid env value",
+			env:     map[string]string{"ASYNC_WORKERS": "many"},
+			wantErr: `invalid ASYNC_WORKERS "many"`,
+		},
+		{
+			name:    "invalid flag value",
+			args:    []string{"-retry-max-backoff", "soon"},
+			wantErr: "invalid flags",
+		},
+		{
+			name:    "validation runs on the merged result",
+			env:     map[string]string{"GIN_MODE": "release"},
+			wantErr: ErrInsecureSecret.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			args := tt.args
+			if tt.file != "" {
+				name, content, _ := strings.Cut(tt.file, ":")
+				path := writeFile(t, name, content)
+				if tt.useFlag {
+					args = append([]string{"-config", path}, args...)
+				} else {
+					t.Setenv("CONFIG_FILE", path)
+				}
+			}
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			cfg, err := Load(args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestLoadDefinesAFlagPerKey(t *testing.T) {
+	clearEnv(t)
+	for _, key := range envKeys() {
+		name := flagName(key)
+		if name == "" {
+			continue
+		}
+		if _, err := Load([]string{"-" + name, ""}); err != nil && strings.Contains(err.Error(), "flag provided but not defined") {
+			t.Errorf("no flag for %s: %v", key, err)
+		}
+	}
+	if _, err := Load([]string{"-h"}); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("Load(-h) error = %v, want flag.ErrHelp", err)
+	}
+}
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envReader aplica las variables de entorno definidas sobre la configuración y acumula
+// los valores inválidos, para reportarlos todos juntos
+type envReader struct {
+	lookup func(key string) (string, bool)
+	errs   []error
+}
+
+// value retorna la variable key si está definida y no está vacía
+func (e *envReader) value(key string) (string, bool) {
+	value, ok := e.lookup(key)
+	value = strings.TrimSpace(value)
+	return value, ok && value != ""
+}
+
+func (e *envReader) invalid(key, value string, err error) {
+	e.errs = append(e.errs, fmt.Errorf("invalid %s %q: %w", key, value, err))
+}
+
+func (e *envReader) string(key string, dst *string) {
+	if value, ok := e.value(key); ok {
+		*dst = value
+	}
+}
+
+func (e *envReader) int(key string, dst *int) {
+	value, ok := e.value(key)
+	if !ok {
+		return
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		e.invalid(key, value, errors.New("expected an integer"))
+		return
+	}
+	*dst = n
+}
+
+func (e *envReader) bool(key string, dst *bool) {
+	value, ok := e.value(key)
+	if !ok {
+		return
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		e.invalid(key, value, errors.New("expected true or false"))
+		return
+	}
+	*dst = b
+}
+
+func (e *envReader) duration(key string, dst *time.Duration) {
+	value, ok := e.value(key)
+	if !ok {
+		return
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		e.invalid(key, value, errors.New(`expected a duration such as "30s" or "5m"`))
+		return
+	}
+	*dst = d
+}
+
+// list lee una lista separada por comas
+func (e *envReader) list(key string, dst *[]string) {
+	value, ok := e.value(key)
+	if !ok {
+		return
+	}
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	*dst = values
+}
+
+// loadEnv aplica las variables de entorno sobre c. environ se usa para descubrir las
+// variables por data_type (ASYNC_WORKERS_<DATA_TYPE>, ASYNC_QUEUE_SIZE_<DATA_TYPE>).
+func (c *Config) loadEnv(lookup func(key string) (string, bool), environ []string) error {
+	e := &envReader{lookup: lookup}
+
+	// GIN_MODE tiene prioridad sobre GO_ENV
+	e.string("GO_ENV", &c.Mode)
+	e.string("GIN_MODE", &c.Mode)
+	e.string("PORT", &c.Port)
+	e.duration("SHUTDOWN_TIMEOUT", &c.ShutdownTimeout)
+	e.string("LOG_LEVEL", &c.Log.Level)
+	e.string("LOG_FORMAT", &c.Log.Format)
+
+	e.string("WEBHOOK_SECRET_KEY", &c.Webhook.SecretKey)
+	e.string("WEBHOOK_SECRETS_FILE", &c.Webhook.SecretsFile)
+	e.duration("WEBHOOK_TIMESTAMP_TOLERANCE", &c.Webhook.TimestampTolerance)
+	e.list("WEBHOOK_SIGNATURE_SCHEMES", &c.Webhook.SignatureSchemes)
+	e.duration("WEBHOOK_LEGACY_REPLAY_WINDOW", &c.Webhook.LegacyReplayWindow)
+
+	e.string("IDEMPOTENCY_STORE", &c.Idempotency.Store)
+	e.string("IDEMPOTENCY_DIR", &c.Idempotency.Dir)
+	e.duration("IDEMPOTENCY_TTL", &c.Idempotency.TTL)
+	e.duration("IDEMPOTENCY_LOCK_TIMEOUT", &c.Idempotency.LockTimeout)
+	e.int("IDEMPOTENCY_MAX_KEYS", &c.Idempotency.MaxKeys)
+
+	e.string("INBOX_DIR", &c.Inbox.Dir)
+	e.int("INBOX_SEGMENT_SIZE", &c.Inbox.SegmentSize)
+
+	e.string("DEAD_LETTER_STORE", &c.DeadLetter.Store)
+	e.string("DEAD_LETTER_DIR", &c.DeadLetter.Dir)
+
+	e.int("RETRY_MAX_ATTEMPTS", &c.Retry.MaxAttempts)
+	e.duration("RETRY_INITIAL_BACKOFF", &c.Retry.InitialBackoff)
+	e.duration("RETRY_MAX_BACKOFF", &c.Retry.MaxBackoff)
+
+	e.bool("ASYNC_MODE", &c.Async.Enabled)
+	e.int("ASYNC_WORKERS", &c.Async.Workers)
+	e.int("ASYNC_QUEUE_SIZE", &c.Async.QueueSize)
+	for _, entry := range environ {
+		key, _, _ := strings.Cut(entry, "=")
+		for _, prefix := range []string{"ASYNC_WORKERS_", "ASYNC_QUEUE_SIZE_"} {
+			suffix, ok := strings.CutPrefix(key, prefix)
+			if !ok || suffix == "" {
+				continue
+			}
+			if c.Async.DataTypes == nil {
+				c.Async.DataTypes = make(map[string]QueueConfig)
+			}
+			dataType := strings.ToLower(suffix)
+			queue := c.Async.DataTypes[dataType]
+			if prefix == "ASYNC_WORKERS_" {
+				e.int(key, &queue.Workers)
+			} else {
+				e.int(key, &queue.QueueSize)
+			}
+			c.Async.DataTypes[dataType] = queue
+		}
+	}
+
+	e.string("ADMIN_TOKEN", &c.AdminToken)
+	e.string("DATABASE_PATH", &c.Database.Path)
+
+	e.bool("INVOICE_FETCH_ENABLED", &c.Invoice.FetchEnabled)
+	e.int("INVOICE_FETCH_ATTEMPTS", &c.Invoice.FetchAttempts)
+	e.duration("INVOICE_FETCH_TIMEOUT", &c.Invoice.FetchTimeout)
+	e.int("INVOICE_MAX_SIZE", &c.Invoice.MaxSize)
+	e.list("INVOICE_ALLOWED_HOSTS", &c.Invoice.AllowedHosts)
+
+	e.string("FORWARD_TARGETS_FILE", &c.Forward.TargetsFile)
+	e.int("FORWARD_MAX_ATTEMPTS", &c.Forward.Retry.MaxAttempts)
+	e.duration("FORWARD_INITIAL_BACKOFF", &c.Forward.Retry.InitialBackoff)
+	e.duration("FORWARD_MAX_BACKOFF", &c.Forward.Retry.MaxBackoff)
+	e.int("FORWARD_BREAKER_THRESHOLD", &c.Forward.BreakerThreshold)
+	e.duration("FORWARD_BREAKER_COOLDOWN", &c.Forward.BreakerCooldown)
+
+	e.string("SINK_TYPE", &c.Sink.Type)
+	e.list("KAFKA_BROKERS", &c.Sink.KafkaBrokers)
+	e.string("NATS_URL", &c.Sink.NATSURL)
+	e.string("SINK_TOPIC_PREFIX", &c.Sink.TopicPrefix)
+	e.duration("SINK_PUBLISH_TIMEOUT", &c.Sink.PublishTimeout)
+	e.string("SINK_CLOUDEVENTS", &c.Sink.CloudEvents)
+	// SINK_TOPICS=consumption=energy.readings,bills=billing.events
+	var topics []string
+	e.list("SINK_TOPICS", &topics)
+	for _, mapping := range topics {
+		dataType, topic, ok := strings.Cut(mapping, "=")
+		if !ok || dataType == "" || topic == "" {
+			e.invalid("SINK_TOPICS entry", mapping, errors.New("expected data_type=topic"))
+			continue
+		}
+		if c.Sink.Topics == nil {
+			c.Sink.Topics = make(map[string]string)
+		}
+		c.Sink.Topics[dataType] = topic
+	}
+
+	e.string("HOOKS_FILE", &c.Hooks.File)
+	e.bool("METRICS_ENABLED", &c.Metrics.Enabled)
+	e.string("TRACING_EXPORTER", &c.Tracing.Exporter)
+	e.string("OTEL_SERVICE_NAME", &c.Tracing.ServiceName)
+
+	return errors.Join(e.errs...)
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"webhook_receiver/internal/cloudevents"
+	"webhook_receiver/internal/logging"
+	"webhook_receiver/internal/signature"
+	"webhook_receiver/internal/tracing"
+)
+
+// ErrInsecureSecret se retorna cuando en producción la clave de firma está vacía o es
+// una de las claves de ejemplo del repositorio
+var ErrInsecureSecret = errors.New("insecure webhook secret")
+
+// defaultSecrets son las claves de ejemplo de la documentación, el Dockerfile y docker-compose
+var defaultSecrets = map[string]bool{
+	DevelopmentSecretKey:    true,
+	"default-secret-key":    true,
+	"your-secret-key-here":  true,
+	"tu-clave-secreta-aqui": true,
+}
+
+// Validate revisa la configuración y retorna todos los problemas encontrados
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	if _, err := ParseMode(c.Mode); err != nil {
+		errs = append(errs, err)
+	}
+	port, err := strconv.Atoi(c.Port)
+	check(err == nil && port > 0 && port <= 65535, "invalid port %q", c.Port)
+	check(c.ShutdownTimeout > 0, "shutdown timeout must be positive")
+
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil,
+		"invalid log level %q (expected debug, info, warn or error)", c.Log.Level)
+	format := strings.ToLower(c.Log.Format)
+	check(format == logging.FormatText || format == logging.FormatJSON,
+		"invalid log format %q (expected text or json)", c.Log.Format)
+
+	if c.Production() && c.Webhook.SecretsFile == "" {
+		switch {
+		case c.Webhook.SecretKey == "":
+			errs = append(errs, fmt.Errorf("%w: a webhook secret key is required in production (set WEBHOOK_SECRET_KEY or WEBHOOK_SECRETS_FILE)", ErrInsecureSecret))
+		case defaultSecrets[c.Webhook.SecretKey]:
+			errs = append(errs, fmt.Errorf("%w: the webhook secret key is a default value and cannot be used in production", ErrInsecureSecret))
+		}
+	}
+	check(c.Webhook.TimestampTolerance > 0, "webhook timestamp tolerance must be positive")
+	check(c.Webhook.LegacyReplayWindow >= c.Webhook.TimestampTolerance,
+		"webhook legacy replay window must be at least the timestamp tolerance")
+	for _, scheme := range c.Webhook.SignatureSchemes {
+		check(scheme == signature.SchemeLegacy || scheme == signature.SchemeV1,
+			"unknown signature scheme %q (expected %s or %s)", scheme, signature.SchemeLegacy, signature.SchemeV1)
+	}
+
+	check(c.Idempotency.Store == "memory" || c.Idempotency.Store == "file",
+		"invalid idempotency store %q (expected memory or file)", c.Idempotency.Store)
+	check(c.Idempotency.TTL > 0 && c.Idempotency.LockTimeout > 0, "idempotency TTL and lock timeout must be positive")
+	check(c.Idempotency.MaxKeys > 0, "idempotency max keys must be positive")
+	check(c.Inbox.SegmentSize > 0, "inbox segment size must be positive")
+	check(c.DeadLetter.Store == "memory" || c.DeadLetter.Store == "file",
+		"invalid dead-letter store %q (expected memory or file)", c.DeadLetter.Store)
+
+	errs = append(errs, c.Retry.validate("retry"))
+	check(c.Async.Workers > 0 && c.Async.QueueSize > 0, "async workers and queue size must be positive")
+	// En modo síncrono bia-consumptions reintenta los 5xx: los reintentos y el dead-letter
+	// queue solo existen en modo asíncrono
+	defaults := Default()
+	check(c.Async.Enabled || c.Retry == defaults.Retry,
+		"retry settings require async mode (set ASYNC_MODE=true)")
+	check(c.Async.Enabled || c.DeadLetter == defaults.DeadLetter,
+		"the dead-letter queue requires async mode (set ASYNC_MODE=true)")
+	for dataType, queue := range c.Async.DataTypes {
+		check(queue.Workers >= 0 && queue.QueueSize >= 0, "async workers and queue size of %s cannot be negative", dataType)
+	}
+
+	check(c.Invoice.FetchAttempts > 0, "invoice fetch attempts must be positive")
+	check(c.Invoice.FetchTimeout > 0, "invoice fetch timeout must be positive")
+	check(c.Invoice.MaxSize > 0, "invoice max size must be positive")
+	check(!c.Invoice.FetchEnabled || len(c.Invoice.AllowedHosts) > 0,
+		"invoice fetch requires at least one allowed host (set INVOICE_ALLOWED_HOSTS)")
+
+	errs = append(errs, c.Forward.Retry.validate("forward retry"))
+	check(c.Forward.BreakerThreshold > 0 && c.Forward.BreakerCooldown > 0,
+		"forward circuit breaker threshold and cooldown must be positive")
+
+	switch c.Sink.Type {
+	case "":
+	case "kafka":
+		check(len(c.Sink.KafkaBrokers) > 0, "the kafka sink requires at least one broker (KAFKA_BROKERS)")
+	case "nats":
+		check(c.Sink.NATSURL != "", "the nats sink requires a server URL (NATS_URL)")
+	default:
+		errs = append(errs, fmt.Errorf("unknown sink type %q (expected kafka or nats)", c.Sink.Type))
+	}
+	check(c.Sink.PublishTimeout > 0, "sink publish timeout must be positive")
+	if _, err := cloudevents.ParseMode(c.Sink.CloudEvents); err != nil {
+		errs = append(errs, err)
+	}
+
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout:
+	default:
+		errs = append(errs, fmt.Errorf("unknown tracing exporter %q (expected otlp or stdout)", c.Tracing.Exporter))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	return nil
+}
+
+func (r RetryConfig) validate(name string) error {
+	if r.MaxAttempts <= 0 || r.InitialBackoff <= 0 || r.MaxBackoff <= 0 {
+		return fmt.Errorf("%s max attempts and backoffs must be positive", name)
+	}
+	if r.InitialBackoff > r.MaxBackoff {
+		return fmt.Errorf("%s initial backoff (%s) is greater than max backoff (%s)", name,
+			r.InitialBackoff, r.MaxBackoff)
+	}
+	return nil
+}
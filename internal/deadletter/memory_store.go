@@ -0,0 +1,84 @@
+package deadletter
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// MemoryStore es un Store en memoria; las entradas se pierden al reiniciar
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+// NewMemoryStore crea un MemoryStore vacío
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]Entry),
+	}
+}
+
+// Put implementa Store
+func (s *MemoryStore) Put(ctx context.Context, entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[entry.ID] = entry
+	return nil
+}
+
+// Get implementa Store
+func (s *MemoryStore) Get(ctx context.Context, id string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[id]
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+	return entry, nil
+}
+
+// List implementa Store
+func (s *MemoryStore) List(ctx context.Context, filter Filter) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]Entry, 0)
+	for _, entry := range s.entries {
+		if filter.Matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+	return entries, nil
+}
+
+// Delete implementa Store
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.entries, id)
+	return nil
+}
+
+// Purge implementa Store
+func (s *MemoryStore) Purge(ctx context.Context, filter Filter) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := 0
+	for id, entry := range s.entries {
+		if filter.Matches(entry) {
+			delete(s.entries, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
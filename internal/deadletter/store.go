@@ -0,0 +1,77 @@
+package deadletter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"webhook_receiver/internal/dto"
+)
+
+// ErrNotFound se retorna cuando no existe una entrada con el ID indicado
+var ErrNotFound = errors.New("dead letter not found")
+
+// Entry es un evento que agotó sus reintentos o fue rechazado por el procesador
+type Entry struct {
+	ID            string             `json:"id"`
+	DataType      string             `json:"data_type"`
+	TriggerType   string             `json:"trigger_type,omitempty"`
+	WebhookID     int                `json:"webhook_id"`
+	ContractID    int                `json:"contract_id"`
+	InboxRecordID string             `json:"inbox_record_id,omitempty"`
+	Headers       dto.WebhookHeaders `json:"headers"`
+	Payload       json.RawMessage    `json:"payload"`
+	ReceivedAt    time.Time          `json:"received_at"`
+	Attempts      int                `json:"attempts"`
+	LastError     string             `json:"last_error"`
+	FirstFailedAt time.Time          `json:"first_failed_at"`
+	LastFailedAt  time.Time          `json:"last_failed_at"`
+}
+
+// Filter selecciona entradas; los campos vacíos o en cero no filtran
+type Filter struct {
+	DataType   string
+	WebhookID  int
+	ContractID int
+}
+
+// Matches indica si entry cumple el filtro
+func (f Filter) Matches(entry Entry) bool {
+	if f.DataType != "" && entry.DataType != f.DataType {
+		return false
+	}
+	if f.WebhookID != 0 && entry.WebhookID != f.WebhookID {
+		return false
+	}
+	if f.ContractID != 0 && entry.ContractID != f.ContractID {
+		return false
+	}
+	return true
+}
+
+// Store persiste las entradas del dead-letter queue
+type Store interface {
+	// Put agrega una entrada o reemplaza la que tenga el mismo ID
+	Put(ctx context.Context, entry Entry) error
+	// Get retorna una entrada por ID
+	Get(ctx context.Context, id string) (Entry, error)
+	// List retorna las entradas que cumplen filter, de la más antigua a la más reciente
+	List(ctx context.Context, filter Filter) ([]Entry, error)
+	// Delete elimina una entrada por ID
+	Delete(ctx context.Context, id string) error
+	// Purge elimina las entradas que cumplen filter y retorna cuántas eliminó
+	Purge(ctx context.Context, filter Filter) (int, error)
+}
+
+// NewID genera un ID ordenable por tiempo para una entrada nueva
+func NewID() string {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Sprintf("dl-%020d", time.Now().UnixNano())
+	}
+	return fmt.Sprintf("dl-%020d-%s", time.Now().UnixNano(), hex.EncodeToString(suffix))
+}
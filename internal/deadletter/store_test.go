@@ -0,0 +1,154 @@
+package deadletter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newStores(t *testing.T) map[string]Store {
+	t.Helper()
+	fileStore, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	return map[string]Store{"memory": NewMemoryStore(), "file": fileStore}
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	seed := []Entry{
+		{ID: "dl-1", DataType: "consumption", WebhookID: 10, ContractID: 1001, Payload: []byte(`{"data_type":"consumption"}`)},
+		{ID: "dl-2", DataType: "bills", WebhookID: 10, ContractID: 2002, Payload: []byte(`{"data_type":"bills"}`)},
+		{ID: "dl-3", DataType: "consumption", WebhookID: 20, ContractID: 2002, Payload: []byte(`{"data_type":"consumption"}`)},
+	}
+
+	filters := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{name: "no filter", filter: Filter{}, want: []string{"dl-1", "dl-2", "dl-3"}},
+		{name: "data_type", filter: Filter{DataType: "consumption"}, want: []string{"dl-1", "dl-3"}},
+		{name: "webhook_id", filter: Filter{WebhookID: 10}, want: []string{"dl-1", "dl-2"}},
+		{name: "contract_id", filter: Filter{ContractID: 2002}, want: []string{"dl-2", "dl-3"}},
+		{name: "combined", filter: Filter{DataType: "consumption", ContractID: 2002}, want: []string{"dl-3"}},
+		{name: "no match", filter: Filter{WebhookID: 99}, want: []string{}},
+	}
+
+	for name, store := range newStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, entry := range seed {
+				if err := store.Put(ctx, entry); err != nil {
+					t.Fatalf("Put(%s) error = %v", entry.ID, err)
+				}
+			}
+
+			for _, tt := range filters {
+				entries, err := store.List(ctx, tt.filter)
+				if err != nil {
+					t.Fatalf("List(%s) error = %v", tt.name, err)
+				}
+				if got := ids(entries); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("List(%s) = %v, want %v", tt.name, got, tt.want)
+				}
+			}
+
+			// Put con un ID existente reemplaza la entrada
+			updated := seed[0]
+			updated.Attempts = 7
+			if err := store.Put(ctx, updated); err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+			got, err := store.Get(ctx, "dl-1")
+			if err != nil || got.Attempts != 7 || compact(t, got.Payload) != compact(t, seed[0].Payload) {
+				t.Errorf("Get() = %+v, %v, want the updated entry", got, err)
+			}
+
+			if _, err := store.Get(ctx, "dl-missing"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get() of a missing entry error = %v, want ErrNotFound", err)
+			}
+			if err := store.Delete(ctx, "dl-1"); err != nil {
+				t.Errorf("Delete() error = %v", err)
+			}
+			if err := store.Delete(ctx, "dl-1"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("second Delete() error = %v, want ErrNotFound", err)
+			}
+
+			purged, err := store.Purge(ctx, Filter{ContractID: 2002, DataType: "bills"})
+			if err != nil || purged != 1 {
+				t.Errorf("Purge() = %d, %v, want 1", purged, err)
+			}
+			remaining, _ := store.List(ctx, Filter{})
+			if got := ids(remaining); !reflect.DeepEqual(got, []string{"dl-3"}) {
+				t.Errorf("List() after purge = %v, want [dl-3]", got)
+			}
+		})
+	}
+}
+
+func TestFileStorePath(t *testing.T) {
+	ctx := context.Background()
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "dead-letters")
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+
+	tests := []struct {
+		id   string
+		file string
+	}{
+		{id: "dl-1", file: "dl-1.json"},
+		{id: "../escape", file: "escape.json"},
+		{id: "../../etc/passwd", file: "passwd.json"},
+		{id: "/abs/path", file: "path.json"},
+		{id: "nested/../dl-2", file: "dl-2.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			if err := store.Put(ctx, Entry{ID: tt.id, DataType: "consumption"}); err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, tt.file)); err != nil {
+				t.Errorf("entry %q was not written to %s: %v", tt.id, tt.file, err)
+			}
+			if _, err := store.Get(ctx, tt.id); err != nil {
+				t.Errorf("Get(%q) error = %v", tt.id, err)
+			}
+		})
+	}
+
+	// Nada se escribió fuera del directorio del store
+	files, err := os.ReadDir(parent)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	if len(files) != 1 || files[0].Name() != "dead-letters" {
+		t.Errorf("files outside the store: %v", files)
+	}
+}
+
+// compact retorna el JSON sin espacios, porque FileStore guarda las entradas indentadas
+func compact(t *testing.T, data []byte) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		t.Fatalf("json.Compact() error = %v", err)
+	}
+	return buf.String()
+}
+
+func ids(entries []Entry) []string {
+	result := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, entry.ID)
+	}
+	return result
+}
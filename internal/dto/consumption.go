@@ -0,0 +1,203 @@
+package dto
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Valores soportados de group_by en webhooks de consumo
+const (
+	GroupByHour        = "hour"
+	GroupByDay         = "day"
+	GroupByMonth       = "month"
+	GroupByDateAndHour = "date_and_hour"
+)
+
+// ErrUnknownGroupBy se retorna cuando group_by no corresponde a ninguna forma de consumo conocida
+var ErrUnknownGroupBy = errors.New("unknown group_by")
+
+// ErrConsumptionShape se retorna cuando una entrada de consumption tiene la forma de otro group_by
+var ErrConsumptionShape = errors.New("consumption entry does not match group_by")
+
+// shapeFields son los campos que identifican la forma de una entrada de cada group_by. Una
+// entrada debe traer los de su group_by y ninguno de los demás; el resto de campos (métricas
+// nuevas del emisor, por ejemplo) se ignora.
+var shapeFields = map[string][]string{
+	GroupByHour:        {"hour"},
+	GroupByDay:         {"date"},
+	GroupByMonth:       {"month"},
+	GroupByDateAndHour: {"date", "hours"},
+}
+
+// ConsumptionDecodeError describe un fallo al decodificar el campo consumption
+type ConsumptionDecodeError struct {
+	GroupBy string
+	Err     error
+}
+
+func (e *ConsumptionDecodeError) Error() string {
+	return fmt.Sprintf("invalid consumption for group_by %q: %v", e.GroupBy, e.Err)
+}
+
+func (e *ConsumptionDecodeError) Unwrap() error {
+	return e.Err
+}
+
+// UnmarshalJSON decodifica el payload de consumo y convierte data.consumption
+// al slice tipado que corresponde según group_by
+func (p *WebhookPayload) UnmarshalJSON(data []byte) error {
+	// Alias sin métodos para evitar recursión infinita
+	type payloadAlias WebhookPayload
+	var raw struct {
+		payloadAlias
+		Data struct {
+			ContractID   int             `json:"contract_id"`
+			ContractName string          `json:"contract_name"`
+			SIC          string          `json:"sic"`
+			Consumption  json.RawMessage `json:"consumption"`
+		} `json:"data"`
+	}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	consumption, err := DecodeConsumption(raw.GroupBy, raw.Data.Consumption)
+	if err != nil {
+		return err
+	}
+
+	*p = WebhookPayload(raw.payloadAlias)
+	p.Data = WebhookContractData{
+		ContractID:   raw.Data.ContractID,
+		ContractName: raw.Data.ContractName,
+		SIC:          raw.Data.SIC,
+		Consumption:  consumption,
+	}
+
+	return nil
+}
+
+// DecodeConsumption decodifica el JSON de consumption al slice tipado que corresponde a groupBy:
+//   - hour:          []WebhookHourlyConsumptionSummary
+//   - day:           []WebhookDailyConsumptionSummary
+//   - month:         []WebhookMonthlyConsumptionSummary
+//   - date_and_hour: []WebhookDateAndHourlyConsumptionSummary
+//
+// Un consumption ausente o null retorna nil sin error.
+func DecodeConsumption(groupBy string, raw json.RawMessage) (interface{}, error) {
+	if len(raw) == 0 || bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		return nil, nil
+	}
+
+	var target interface{}
+	switch groupBy {
+	case GroupByHour:
+		target = &[]WebhookHourlyConsumptionSummary{}
+	case GroupByDay:
+		target = &[]WebhookDailyConsumptionSummary{}
+	case GroupByMonth:
+		target = &[]WebhookMonthlyConsumptionSummary{}
+	case GroupByDateAndHour:
+		target = &[]WebhookDateAndHourlyConsumptionSummary{}
+	default:
+		return nil, &ConsumptionDecodeError{GroupBy: groupBy, Err: ErrUnknownGroupBy}
+	}
+
+	if err := json.Unmarshal(raw, target); err != nil {
+		return nil, &ConsumptionDecodeError{GroupBy: groupBy, Err: err}
+	}
+	if err := checkShape(groupBy, raw); err != nil {
+		return nil, &ConsumptionDecodeError{GroupBy: groupBy, Err: err}
+	}
+
+	switch v := target.(type) {
+	case *[]WebhookHourlyConsumptionSummary:
+		return *v, nil
+	case *[]WebhookDailyConsumptionSummary:
+		return *v, nil
+	case *[]WebhookMonthlyConsumptionSummary:
+		return *v, nil
+	default:
+		return *target.(*[]WebhookDateAndHourlyConsumptionSummary), nil
+	}
+}
+
+// checkShape verifica que cada entrada traiga los campos de la forma de groupBy y ninguno de
+// los que identifican a otro group_by. Los campos desconocidos no son un error, para que una
+// métrica nueva del emisor no haga fallar la entrega.
+func checkShape(groupBy string, raw json.RawMessage) error {
+	var entries []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return err
+	}
+
+	want := shapeFields[groupBy]
+	for i, entry := range entries {
+		for _, field := range want {
+			if _, ok := entry[field]; !ok {
+				return fmt.Errorf("%w: entry %d has no %q field", ErrConsumptionShape, i, field)
+			}
+		}
+		for _, fields := range shapeFields {
+			for _, field := range fields {
+				if _, ok := entry[field]; ok && !contains(want, field) {
+					return fmt.Errorf("%w: entry %d has field %q", ErrConsumptionShape, i, field)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// contains indica si values incluye value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// HourlyConsumption retorna el consumo por hora si group_by es "hour"
+func (d WebhookContractData) HourlyConsumption() ([]WebhookHourlyConsumptionSummary, bool) {
+	v, ok := d.Consumption.([]WebhookHourlyConsumptionSummary)
+	return v, ok
+}
+
+// DailyConsumption retorna el consumo por día si group_by es "day"
+func (d WebhookContractData) DailyConsumption() ([]WebhookDailyConsumptionSummary, bool) {
+	v, ok := d.Consumption.([]WebhookDailyConsumptionSummary)
+	return v, ok
+}
+
+// MonthlyConsumption retorna el consumo por mes si group_by es "month"
+func (d WebhookContractData) MonthlyConsumption() ([]WebhookMonthlyConsumptionSummary, bool) {
+	v, ok := d.Consumption.([]WebhookMonthlyConsumptionSummary)
+	return v, ok
+}
+
+// DateAndHourlyConsumption retorna el consumo por fecha y hora si group_by es "date_and_hour"
+func (d WebhookContractData) DateAndHourlyConsumption() ([]WebhookDateAndHourlyConsumptionSummary, bool) {
+	v, ok := d.Consumption.([]WebhookDateAndHourlyConsumptionSummary)
+	return v, ok
+}
+
+// ConsumptionEntries retorna la cantidad de registros de consumo decodificados
+func (d WebhookContractData) ConsumptionEntries() int {
+	switch v := d.Consumption.(type) {
+	case []WebhookHourlyConsumptionSummary:
+		return len(v)
+	case []WebhookDailyConsumptionSummary:
+		return len(v)
+	case []WebhookMonthlyConsumptionSummary:
+		return len(v)
+	case []WebhookDateAndHourlyConsumptionSummary:
+		return len(v)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,120 @@
+package dto
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestWebhookPayloadDecodesConsumption(t *testing.T) {
+	tests := []struct {
+		name        string
+		groupBy     string
+		consumption string
+		wantEntries int
+		check       func(t *testing.T, data WebhookContractData)
+		wantErr     bool
+		wantErrIs   error
+	}{
+		{
+			name:        "hour",
+			groupBy:     GroupByHour,
+			consumption: `[{"hour":0,"active_energy":150.5},{"hour":1,"active_energy":140.25}]`,
+			wantEntries: 2,
+			check: func(t *testing.T, data WebhookContractData) {
+				hours, ok := data.HourlyConsumption()
+				if !ok || hours[1].Hour != 1 {
+					t.Errorf("HourlyConsumption() = %+v, %v", hours, ok)
+				}
+			},
+		},
+		{
+			name:        "day",
+			groupBy:     GroupByDay,
+			consumption: `[{"date":"2025-10-08","active_energy":3600}]`,
+			wantEntries: 1,
+			check: func(t *testing.T, data WebhookContractData) {
+				days, ok := data.DailyConsumption()
+				if !ok || days[0].Date != "2025-10-08" {
+					t.Errorf("DailyConsumption() = %+v, %v", days, ok)
+				}
+				if _, ok := data.HourlyConsumption(); ok {
+					t.Error("HourlyConsumption() ok for group_by day")
+				}
+			},
+		},
+		{
+			name:        "month",
+			groupBy:     GroupByMonth,
+			consumption: `[{"month":"2025-10","active_energy":108000}]`,
+			wantEntries: 1,
+			check: func(t *testing.T, data WebhookContractData) {
+				if months, ok := data.MonthlyConsumption(); !ok || months[0].Month != "2025-10" {
+					t.Errorf("MonthlyConsumption() = %+v, %v", months, ok)
+				}
+			},
+		},
+		{
+			name:        "date and hour",
+			groupBy:     GroupByDateAndHour,
+			consumption: `[{"date":"2025-10-01","hours":[{"hour":0,"active_energy":1},{"hour":1,"active_energy":2}]}]`,
+			wantEntries: 1,
+			check: func(t *testing.T, data WebhookContractData) {
+				if days, ok := data.DateAndHourlyConsumption(); !ok || len(days[0].Hours) != 2 {
+					t.Errorf("DateAndHourlyConsumption() = %+v, %v", days, ok)
+				}
+			},
+		},
+		{name: "missing consumption", groupBy: GroupByHour, consumption: `null`},
+		{name: "unknown group_by", groupBy: "week", consumption: `[]`, wantErr: true, wantErrIs: ErrUnknownGroupBy},
+		{
+			name:        "unknown metric field is ignored",
+			groupBy:     GroupByDay,
+			consumption: `[{"date":"2025-10-08","active_energy":3600,"apparent_energy":3700}]`,
+			wantEntries: 1,
+		},
+		{name: "shape of another group_by", groupBy: GroupByHour, consumption: `[{"date":"2025-10-08"}]`, wantErr: true, wantErrIs: ErrConsumptionShape},
+		{name: "month entries for group_by day", groupBy: GroupByDay, consumption: `[{"month":"2025-10"}]`, wantErr: true, wantErrIs: ErrConsumptionShape},
+		{
+			name:        "date and hour entries for group_by day",
+			groupBy:     GroupByDay,
+			consumption: `[{"date":"2025-10-01","hours":[{"hour":0,"active_energy":1}]}]`,
+			wantErr:     true,
+			wantErrIs:   ErrConsumptionShape,
+		},
+		{name: "date and hour entry without hours", groupBy: GroupByDateAndHour, consumption: `[{"date":"2025-10-01"}]`, wantErr: true, wantErrIs: ErrConsumptionShape},
+		{name: "not an array", groupBy: GroupByDay, consumption: `{"date":"2025-10-08"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"webhook_id":1,"data_type":"consumption","group_by":"` + tt.groupBy +
+				`","data":{"contract_id":1001,"consumption":` + tt.consumption + `}}`
+
+			var payload WebhookPayload
+			err := json.Unmarshal([]byte(body), &payload)
+			if tt.wantErr {
+				var decodeErr *ConsumptionDecodeError
+				if !errors.As(err, &decodeErr) || decodeErr.GroupBy != tt.groupBy {
+					t.Fatalf("Unmarshal() error = %v, want a ConsumptionDecodeError for %q", err, tt.groupBy)
+				}
+				if tt.wantErrIs != nil && !errors.Is(err, tt.wantErrIs) {
+					t.Errorf("Unmarshal() error = %v, want %v", err, tt.wantErrIs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			if payload.Data.ContractID != 1001 {
+				t.Errorf("contract_id = %d, want 1001", payload.Data.ContractID)
+			}
+			if got := payload.Data.ConsumptionEntries(); got != tt.wantEntries {
+				t.Errorf("ConsumptionEntries() = %d, want %d", got, tt.wantEntries)
+			}
+			if tt.check != nil {
+				tt.check(t, payload.Data)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package dto
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+)
+
+// decimalPattern acepta la sintaxis de números JSON (signo, parte entera, fracción y exponente
+// opcionales). El exponente se limita a 3 dígitos para que big.Rat no reserve memoria sin límite.
+var decimalPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]{1,3})?$`)
+
+// Decimal es un valor monetario exacto. Conserva el texto original del número (por ejemplo
+// "1250.750") en lugar de convertirlo a float64, así los montos en pesos no pierden precisión.
+// En JSON se decodifica desde un número o un string y se codifica como número.
+// El valor cero (Decimal{}) representa 0.
+type Decimal struct {
+	literal string
+}
+
+// ParseDecimal crea un Decimal a partir de su representación en texto
+func ParseDecimal(s string) (Decimal, error) {
+	if !decimalPattern.MatchString(s) {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	return Decimal{literal: s}, nil
+}
+
+// MustParseDecimal es como ParseDecimal pero entra en pánico si s no es válido
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// String retorna el texto original del número
+func (d Decimal) String() string {
+	if d.literal == "" {
+		return "0"
+	}
+	return d.literal
+}
+
+// Rat retorna el valor exacto como número racional
+func (d Decimal) Rat() *big.Rat {
+	r, _ := new(big.Rat).SetString(d.String())
+	return r
+}
+
+// Cmp compara d con other por su valor numérico (1250.75 y 1250.750 son iguales):
+// retorna -1, 0 o +1
+func (d Decimal) Cmp(other Decimal) int {
+	return d.Rat().Cmp(other.Rat())
+}
+
+// Equal indica si d y other tienen el mismo valor numérico
+func (d Decimal) Equal(other Decimal) bool {
+	return d.Cmp(other) == 0
+}
+
+// Sign retorna -1, 0 o +1 según el signo de d
+func (d Decimal) Sign() int {
+	return d.Rat().Sign()
+}
+
+// MarshalJSON codifica el decimal como número JSON con su texto original
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON acepta un número JSON (formato actual de bia-consumptions) o un string
+// con el número. null deja el valor en cero.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*d = Decimal{}
+		return nil
+	}
+
+	literal := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &literal); err != nil {
+			return err
+		}
+	}
+
+	parsed, err := ParseDecimal(literal)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Value implementa driver.Valuer: el decimal se guarda como texto para no perder precisión
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// Scan implementa sql.Scanner
+func (d *Decimal) Scan(src interface{}) error {
+	var literal string
+	switch v := src.(type) {
+	case string:
+		literal = v
+	case []byte:
+		literal = string(v)
+	case int64:
+		literal = fmt.Sprint(v)
+	case nil:
+		*d = Decimal{}
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into Decimal", src)
+	}
+
+	parsed, err := ParseDecimal(literal)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
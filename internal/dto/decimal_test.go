@@ -0,0 +1,116 @@
+package dto
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		input   string
+		wantErr bool
+	}{
+		{input: "0"},
+		{input: "1250.75"},
+		{input: "1250.750"},
+		{input: "-3.5"},
+		{input: "1e3"},
+		{input: "1.5E-2"},
+		{input: "", wantErr: true},
+		{input: "01", wantErr: true},
+		{input: "1.", wantErr: true},
+		{input: ".5", wantErr: true},
+		{input: "+1", wantErr: true},
+		{input: "1,5", wantErr: true},
+		{input: "NaN", wantErr: true},
+		{input: "1e1000", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			d, err := ParseDecimal(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDecimal(%q) error = %v, want error %v", tt.input, err, tt.wantErr)
+			}
+			if err == nil && d.String() != tt.input {
+				t.Errorf("String() = %q, want the original literal %q", d.String(), tt.input)
+			}
+		})
+	}
+}
+
+func TestDecimalUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		want    string
+		wantErr bool
+	}{
+		{name: "number", json: `1250.75`, want: "1250.75"},
+		{name: "keeps trailing zeros", json: `1250.750`, want: "1250.750"},
+		{name: "beyond float64 precision", json: `12345678901234567.89`, want: "12345678901234567.89"},
+		{name: "string", json: `"1250.75"`, want: "1250.75"},
+		{name: "null", json: `null`, want: "0"},
+		{name: "invalid string", json: `"12,50"`, wantErr: true},
+		{name: "boolean", json: `true`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d Decimal
+			err := json.Unmarshal([]byte(tt.json), &d)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal(%s) error = %v, want error %v", tt.json, err, tt.wantErr)
+			}
+			if err == nil && d.String() != tt.want {
+				t.Errorf("Unmarshal(%s) = %s, want %s", tt.json, d, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecimalCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "1250.75", b: "1250.750", want: 0},
+		{a: "1e2", b: "100", want: 0},
+		{a: "0.1", b: "0.10000000000000001", want: -1},
+		{a: "-1", b: "0", want: -1},
+		{a: "2", b: "1.99", want: 1},
+	}
+	for _, tt := range tests {
+		if got := MustParseDecimal(tt.a).Cmp(MustParseDecimal(tt.b)); got != tt.want {
+			t.Errorf("Cmp(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+	if !(Decimal{}).Equal(MustParseDecimal("0.00")) {
+		t.Error("the zero Decimal is not equal to 0.00")
+	}
+}
+
+func TestDecimalScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     interface{}
+		want    string
+		wantErr bool
+	}{
+		{name: "string", src: "1250.750", want: "1250.750"},
+		{name: "bytes", src: []byte("99.9"), want: "99.9"},
+		{name: "integer", src: int64(42), want: "42"},
+		{name: "null", src: nil, want: "0"},
+		{name: "float", src: 1.5, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d Decimal
+			err := d.Scan(tt.src)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Scan(%v) error = %v, want error %v", tt.src, err, tt.wantErr)
+			}
+			if err == nil && d.String() != tt.want {
+				t.Errorf("Scan(%v) = %s, want %s", tt.src, d, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package dto
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Valores soportados de send_interval en webhooks de consumo
+const (
+	SendIntervalHourly  = "hourly"
+	SendIntervalDaily   = "daily"
+	SendIntervalMonthly = "monthly"
+)
+
+// Formatos de fecha y mes usados en los payloads
+const (
+	DateLayout  = "2006-01-02"
+	MonthLayout = "2006-01"
+)
+
+// FieldError describe un campo del payload que no pasó la validación
+type FieldError struct {
+	Field   string `json:"field"`   // Ruta del campo, por ejemplo "data.consumption[3].hour"
+	Message string `json:"message"` // Descripción del problema
+}
+
+// ValidationError agrupa todos los errores de campo de un payload
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, fieldErr := range e.Errors {
+		messages[i] = fieldErr.Field + ": " + fieldErr.Message
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+// validator acumula errores de campo
+type validator struct {
+	errors []FieldError
+}
+
+func (v *validator) add(field, format string, args ...interface{}) {
+	v.errors = append(v.errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// oneOf valida que value sea uno de los valores permitidos
+func (v *validator) oneOf(field, value string, allowed ...string) {
+	if value == "" {
+		v.add(field, "is required")
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.add(field, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+}
+
+// layout valida que value tenga el formato de fecha indicado y retorna la fecha
+func (v *validator) layout(field, value, layout, name string) (time.Time, bool) {
+	if value == "" {
+		v.add(field, "is required")
+		return time.Time{}, false
+	}
+	parsed, err := time.Parse(layout, value)
+	if err != nil {
+		v.add(field, "must be %s (%s), got %q", name, layout, value)
+		return time.Time{}, false
+	}
+	return parsed, true
+}
+
+// metrics valida que las métricas de energía no sean negativas
+func (v *validator) metrics(field string, m WebhookEnergyMetrics) {
+	values := []struct {
+		name  string
+		value *float64
+	}{
+		{"active_energy", m.ActiveEnergy},
+		{"active_export", m.ActiveExport},
+		{"inductive_penalized", m.InductivePenalized},
+		{"reactive_capacitive", m.ReactiveCapacitive},
+	}
+	for _, metric := range values {
+		if metric.value != nil && *metric.value < 0 {
+			v.add(field+"."+metric.name, "must be non-negative, got %g", *metric.value)
+		}
+	}
+}
+
+// hours valida que cada hora esté entre 0 y 23 y que no se repita
+func (v *validator) hours(field string, hours []WebhookHourlyConsumptionSummary) {
+	seen := make(map[int]bool, len(hours))
+	for i, h := range hours {
+		entry := fmt.Sprintf("%s[%d]", field, i)
+		switch {
+		case h.Hour < 0 || h.Hour > 23:
+			v.add(entry+".hour", "must be between 0 and 23, got %d", h.Hour)
+		case seen[h.Hour]:
+			v.add(entry+".hour", "duplicate hour %d", h.Hour)
+		}
+		seen[h.Hour] = true
+		v.metrics(entry, h.WebhookEnergyMetrics)
+	}
+}
+
+// url valida que value sea una URL absoluta http o https
+func (v *validator) url(field, value string) {
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		v.add(field, "must be an absolute http or https URL, got %q", value)
+	}
+}
+
+// err retorna un *ValidationError si hubo errores, o nil
+func (v *validator) err() error {
+	if len(v.errors) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: v.errors}
+}
+
+// Validate valida la semántica del payload de consumo: valores de group_by y send_interval,
+// período con fechas ISO y start_date anterior a end_date, horas 0-23 sin duplicados,
+// 24 horas por fecha en date_and_hour, meses bien formados y métricas no negativas.
+// Retorna un *ValidationError con todos los campos inválidos, o nil.
+func (p WebhookPayload) Validate() error {
+	v := &validator{}
+
+	if p.WebhookID == 0 {
+		v.add("webhook_id", "is required")
+	}
+	v.oneOf("group_by", p.GroupBy, GroupByHour, GroupByDay, GroupByMonth, GroupByDateAndHour)
+	v.oneOf("send_interval", p.SendInterval, SendIntervalHourly, SendIntervalDaily, SendIntervalMonthly)
+	if p.Timestamp.IsZero() {
+		v.add("timestamp", "is required")
+	}
+
+	start, okStart := v.layout("period.start_date", p.Period.StartDate, DateLayout, "an ISO date")
+	end, okEnd := v.layout("period.end_date", p.Period.EndDate, DateLayout, "an ISO date")
+	if okStart && okEnd && !start.Before(end) {
+		v.add("period.start_date", "must be before period.end_date (%s), got %s", p.Period.EndDate, p.Period.StartDate)
+	}
+
+	const field = "data.consumption"
+	switch p.GroupBy {
+	case GroupByHour:
+		hours, _ := p.Data.HourlyConsumption()
+		v.hours(field, hours)
+	case GroupByDay:
+		days, _ := p.Data.DailyConsumption()
+		seen := make(map[string]bool, len(days))
+		for i, d := range days {
+			entry := fmt.Sprintf("%s[%d]", field, i)
+			if _, ok := v.layout(entry+".date", d.Date, DateLayout, "an ISO date"); ok && seen[d.Date] {
+				v.add(entry+".date", "duplicate date %s", d.Date)
+			}
+			seen[d.Date] = true
+			v.metrics(entry, d.WebhookEnergyMetrics)
+		}
+	case GroupByMonth:
+		months, _ := p.Data.MonthlyConsumption()
+		seen := make(map[string]bool, len(months))
+		for i, m := range months {
+			entry := fmt.Sprintf("%s[%d]", field, i)
+			if _, ok := v.layout(entry+".month", m.Month, MonthLayout, "a month"); ok && seen[m.Month] {
+				v.add(entry+".month", "duplicate month %s", m.Month)
+			}
+			seen[m.Month] = true
+			v.metrics(entry, m.WebhookEnergyMetrics)
+		}
+	case GroupByDateAndHour:
+		dates, _ := p.Data.DateAndHourlyConsumption()
+		seen := make(map[string]bool, len(dates))
+		for i, d := range dates {
+			entry := fmt.Sprintf("%s[%d]", field, i)
+			if _, ok := v.layout(entry+".date", d.Date, DateLayout, "an ISO date"); ok && seen[d.Date] {
+				v.add(entry+".date", "duplicate date %s", d.Date)
+			}
+			seen[d.Date] = true
+			if len(d.Hours) != 24 {
+				v.add(entry+".hours", "must contain 24 hours, got %d", len(d.Hours))
+			}
+			v.hours(entry+".hours", d.Hours)
+		}
+	}
+
+	return v.err()
+}
+
+// Validate valida el payload de facturas según su trigger_type: "paid" requiere payment con
+// payment_date y "available" no lo admite. También valida el formato de bill.period, que
+// bill.total no sea negativo y que bill.xml_url, si viene, sea una URL válida. bill.status no
+// tiene valores documentados y se guarda tal como llega. Retorna un *ValidationError con
+// todos los campos inválidos, o nil.
+func (p BillWebhookPayload) Validate() error {
+	v := &validator{}
+
+	if p.WebhookID == 0 {
+		v.add("webhook_id", "is required")
+	}
+	v.oneOf("trigger_type", p.TriggerType, TriggerTypeAvailable, TriggerTypePaid)
+	if p.Timestamp.IsZero() {
+		v.add("timestamp", "is required")
+	}
+
+	if p.Bill.BillID == 0 {
+		v.add("bill.bill_id", "is required")
+	}
+	if p.Bill.ContractID == 0 {
+		v.add("bill.contract_id", "is required")
+	}
+	v.layout("bill.period", p.Bill.Period, MonthLayout, "a month")
+	if p.Bill.Total.Sign() < 0 {
+		v.add("bill.total", "must be non-negative, got %s", p.Bill.Total)
+	}
+	if p.Bill.XmlUrl != "" {
+		v.url("bill.xml_url", p.Bill.XmlUrl)
+	}
+
+	switch p.TriggerType {
+	case TriggerTypePaid:
+		if p.Payment == nil {
+			v.add("payment", "is required for trigger_type %q", TriggerTypePaid)
+		} else if p.Payment.PaymentDate.IsZero() {
+			v.add("payment.payment_date", "is required for trigger_type %q", TriggerTypePaid)
+		}
+	case TriggerTypeAvailable:
+		if p.Payment != nil {
+			v.add("payment", "must not be present for trigger_type %q", TriggerTypeAvailable)
+		}
+	}
+
+	return v.err()
+}
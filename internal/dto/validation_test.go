@@ -0,0 +1,229 @@
+package dto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// consumptionBody arma un webhook de consumo válido salvo por los valores que recibe
+func consumptionBody(groupBy, sendInterval, startDate, endDate, consumption string) string {
+	return `{"webhook_id":7,"data_type":"consumption","group_by":"` + groupBy + `","send_interval":"` + sendInterval + `",` +
+		`"period":{"start_date":"` + startDate + `","end_date":"` + endDate + `"},` +
+		`"data":{"contract_id":1001,"consumption":` + consumption + `},"timestamp":"2025-10-09T05:00:00Z"}`
+}
+
+// dayHours retorna las 24 horas de una fecha de date_and_hour
+func dayHours(date string) string {
+	hours := make([]string, 24)
+	for hour := range hours {
+		hours[hour] = fmt.Sprintf(`{"hour":%d,"active_energy":1}`, hour)
+	}
+	return `{"date":"` + date + `","hours":[` + strings.Join(hours, ",") + `]}`
+}
+
+// fields retorna las rutas de los campos de un *ValidationError
+func fields(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Validate() error = %v, want a *ValidationError", err)
+	}
+	result := make([]string, len(validationErr.Errors))
+	for i, fieldErr := range validationErr.Errors {
+		if fieldErr.Message == "" {
+			t.Errorf("%s has no message", fieldErr.Field)
+		}
+		result[i] = fieldErr.Field
+	}
+	return result
+}
+
+func TestWebhookPayloadValidate(t *testing.T) {
+	hours := `[{"hour":0,"active_energy":1},{"hour":23,"active_energy":2}]`
+
+	tests := []struct {
+		name       string
+		body       string
+		payload    *WebhookPayload // Payload que no se puede decodificar, como un group_by desconocido
+		wantFields []string
+	}{
+		{name: "valid hour", body: consumptionBody(GroupByHour, SendIntervalDaily, "2025-10-08", "2025-10-09", hours)},
+		{
+			name: "valid day",
+			body: consumptionBody(GroupByDay, SendIntervalDaily, "2025-10-01", "2025-10-03", `[{"date":"2025-10-01","active_energy":0},{"date":"2025-10-02"}]`),
+		},
+		{
+			name: "valid month",
+			body: consumptionBody(GroupByMonth, SendIntervalMonthly, "2025-09-01", "2025-11-01", `[{"month":"2025-09","active_energy":1},{"month":"2025-10","active_energy":2}]`),
+		},
+		{
+			name: "valid date and hour",
+			body: consumptionBody(GroupByDateAndHour, SendIntervalHourly, "2025-10-01", "2025-10-03", "["+dayHours("2025-10-01")+","+dayHours("2025-10-02")+"]"),
+		},
+		{
+			name:       "unknown group_by",
+			payload:    &WebhookPayload{WebhookID: 7, GroupBy: "week", SendInterval: SendIntervalDaily},
+			wantFields: []string{"group_by", "timestamp", "period.start_date", "period.end_date"},
+		},
+		{
+			name:       "unknown send_interval",
+			body:       consumptionBody(GroupByHour, "weekly", "2025-10-08", "2025-10-09", hours),
+			wantFields: []string{"send_interval"},
+		},
+		{
+			name:       "missing send_interval",
+			body:       consumptionBody(GroupByHour, "", "2025-10-08", "2025-10-09", hours),
+			wantFields: []string{"send_interval"},
+		},
+		{
+			name:       "start_date equal to end_date",
+			body:       consumptionBody(GroupByHour, SendIntervalDaily, "2025-10-08", "2025-10-08", hours),
+			wantFields: []string{"period.start_date"},
+		},
+		{
+			name:       "start_date after end_date",
+			body:       consumptionBody(GroupByHour, SendIntervalDaily, "2025-10-09", "2025-10-08", hours),
+			wantFields: []string{"period.start_date"},
+		},
+		{
+			name:       "period dates are not ISO",
+			body:       consumptionBody(GroupByHour, SendIntervalDaily, "08/10/2025", "2025-10-09T00:00:00Z", hours),
+			wantFields: []string{"period.start_date", "period.end_date"},
+		},
+		{
+			name:       "hours out of range",
+			body:       consumptionBody(GroupByHour, SendIntervalDaily, "2025-10-08", "2025-10-09", `[{"hour":-1},{"hour":24},{"hour":23}]`),
+			wantFields: []string{"data.consumption[0].hour", "data.consumption[1].hour"},
+		},
+		{
+			name:       "duplicate hour",
+			body:       consumptionBody(GroupByHour, SendIntervalDaily, "2025-10-08", "2025-10-09", `[{"hour":5},{"hour":6},{"hour":5}]`),
+			wantFields: []string{"data.consumption[2].hour"},
+		},
+		{
+			name: "date and hour without 24 hours",
+			body: consumptionBody(GroupByDateAndHour, SendIntervalDaily, "2025-10-01", "2025-10-03",
+				`[`+dayHours("2025-10-01")+`,{"date":"2025-10-02","hours":[{"hour":0},{"hour":1}]}]`),
+			wantFields: []string{"data.consumption[1].hours"},
+		},
+		{
+			name: "date and hour with a duplicate hour",
+			body: consumptionBody(GroupByDateAndHour, SendIntervalDaily, "2025-10-01", "2025-10-02",
+				strings.Replace("["+dayHours("2025-10-01")+"]", `"hour":7,`, `"hour":6,`, 1)),
+			wantFields: []string{"data.consumption[0].hours[7].hour"},
+		},
+		{
+			name:       "negative metrics",
+			body:       consumptionBody(GroupByHour, SendIntervalDaily, "2025-10-08", "2025-10-09", `[{"hour":0,"active_energy":-1,"reactive_capacitive":-0.5},{"hour":1,"active_export":0}]`),
+			wantFields: []string{"data.consumption[0].active_energy", "data.consumption[0].reactive_capacitive"},
+		},
+		{
+			name: "malformed and duplicate months",
+			body: consumptionBody(GroupByMonth, SendIntervalMonthly, "2025-09-01", "2025-11-01",
+				`[{"month":"2025-9"},{"month":"2025-13"},{"month":"2025-10"},{"month":"2025-10"}]`),
+			wantFields: []string{"data.consumption[0].month", "data.consumption[1].month", "data.consumption[3].month"},
+		},
+		{
+			name:       "malformed day",
+			body:       consumptionBody(GroupByDay, SendIntervalDaily, "2025-10-01", "2025-10-03", `[{"date":"2025-10-32"}]`),
+			wantFields: []string{"data.consumption[0].date"},
+		},
+		{
+			name:       "every error is reported",
+			body:       strings.Replace(consumptionBody(GroupByHour, "weekly", "2025-10-09", "2025-10-08", `[{"hour":30}]`), `"webhook_id":7`, `"webhook_id":0`, 1),
+			wantFields: []string{"webhook_id", "send_interval", "period.start_date", "data.consumption[0].hour"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := tt.payload
+			if payload == nil {
+				payload = &WebhookPayload{}
+				if err := json.Unmarshal([]byte(tt.body), payload); err != nil {
+					t.Fatalf("Unmarshal() error = %v", err)
+				}
+			}
+
+			if got := fields(t, payload.Validate()); !reflect.DeepEqual(got, tt.wantFields) {
+				t.Errorf("Validate() fields = %v, want %v", got, tt.wantFields)
+			}
+		})
+	}
+}
+
+// billBody arma un webhook de facturas con el trigger y el payment indicados; payment vacío lo omite
+func billBody(trigger, bill, payment string) string {
+	if bill == "" {
+		bill = `{"bill_id":1001,"contract_id":2001,"period":"2025-10","total":1250.75,"status":"pending","xml_url":"https://example.com/1001.xml"}`
+	}
+	body := `{"webhook_id":9,"data_type":"bills","trigger_type":"` + trigger + `","bill":` + bill
+	if payment != "" {
+		body += `,"payment":` + payment
+	}
+	return body + `,"timestamp":"2025-10-09T05:00:00Z"}`
+}
+
+func TestBillWebhookPayloadValidate(t *testing.T) {
+	const payment = `{"payment_date":"2025-10-08T15:00:00Z","transaction_id":99,"payment_method":"PSE"}`
+
+	tests := []struct {
+		name       string
+		body       string
+		wantFields []string
+	}{
+		{name: "valid available", body: billBody(TriggerTypeAvailable, "", "")},
+		{name: "valid paid", body: billBody(TriggerTypePaid, "", payment)},
+		{
+			name: "status is stored as it arrives",
+			body: billBody(TriggerTypeAvailable, `{"bill_id":1001,"contract_id":2001,"period":"2025-10","total":0,"status":"any-status"}`, ""),
+		},
+		{name: "paid without payment", body: billBody(TriggerTypePaid, "", ""), wantFields: []string{"payment"}},
+		{name: "paid with a zero payment_date", body: billBody(TriggerTypePaid, "", `{"transaction_id":99}`), wantFields: []string{"payment.payment_date"}},
+		{name: "available with payment", body: billBody(TriggerTypeAvailable, "", payment), wantFields: []string{"payment"}},
+		{name: "unknown trigger_type", body: billBody("cancelled", "", ""), wantFields: []string{"trigger_type"}},
+		{
+			name:       "negative total",
+			body:       billBody(TriggerTypeAvailable, `{"bill_id":1001,"contract_id":2001,"period":"2025-10","total":-0.01}`, ""),
+			wantFields: []string{"bill.total"},
+		},
+		{
+			name:       "malformed period",
+			body:       billBody(TriggerTypeAvailable, `{"bill_id":1001,"contract_id":2001,"period":"2025-10-01","total":1}`, ""),
+			wantFields: []string{"bill.period"},
+		},
+		{
+			name:       "relative xml_url",
+			body:       billBody(TriggerTypeAvailable, `{"bill_id":1001,"contract_id":2001,"period":"2025-10","total":1,"xml_url":"/bills/1001.xml"}`, ""),
+			wantFields: []string{"bill.xml_url"},
+		},
+		{
+			name:       "xml_url with another scheme",
+			body:       billBody(TriggerTypeAvailable, `{"bill_id":1001,"contract_id":2001,"period":"2025-10","total":1,"xml_url":"ftp://example.com/1001.xml"}`, ""),
+			wantFields: []string{"bill.xml_url"},
+		},
+		{
+			name:       "missing ids and period",
+			body:       billBody(TriggerTypePaid, `{"total":1}`, payment),
+			wantFields: []string{"bill.bill_id", "bill.contract_id", "bill.period"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var payload BillWebhookPayload
+			if err := json.Unmarshal([]byte(tt.body), &payload); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			if got := fields(t, payload.Validate()); !reflect.DeepEqual(got, tt.wantFields) {
+				t.Errorf("Validate() fields = %v, want %v", got, tt.wantFields)
+			}
+		})
+	}
+}
@@ -25,7 +25,7 @@ type WebhookContractData struct {
 	ContractID   int         `json:"contract_id"`
 	ContractName string      `json:"contract_name"`
 	SIC          string      `json:"sic"`
-	Consumption  interface{} `json:"consumption"` // Puede ser WebhookHourlyConsumptionSummary, WebhookDailyConsumptionSummary, etc.
+	Consumption  interface{} `json:"consumption"` // Slice tipado según group_by (ver DecodeConsumption)
 }
 
 // WebhookEnergyMetrics contiene las métricas de consumo de energía
@@ -60,6 +60,12 @@ type WebhookDateAndHourlyConsumptionSummary struct {
 	Hours []WebhookHourlyConsumptionSummary `json:"hours"` // Array de 24 horas (0-23)
 }
 
+// Valores soportados de trigger_type en webhooks de facturas
+const (
+	TriggerTypeAvailable = "available"
+	TriggerTypePaid      = "paid"
+)
+
 // BillWebhookPayload representa el payload para eventos de FACTURAS
 // Este es el formato real que envía bia-consumptions para webhooks de facturas
 type BillWebhookPayload struct {
@@ -76,7 +82,7 @@ type BillWebhookData struct {
 	BillID     int     `json:"bill_id"`
 	ContractID int     `json:"contract_id"`
 	Period     string  `json:"period"`
-	Total      float64 `json:"total"`
+	Total      Decimal `json:"total"` // Monto exacto; en JSON sigue siendo un número
 	Status     string  `json:"status"`
 	XmlUrl     string  `json:"xml_url"`
 }
@@ -90,12 +96,28 @@ type PaymentWebhookData struct {
 
 // WebhookResponse representa la respuesta del webhook receiver
 type WebhookResponse struct {
-	Success   bool      `json:"success"`
-	Message   string    `json:"message,omitempty"`
-	Processed bool      `json:"processed"`
-	Timestamp time.Time `json:"timestamp"`
+	Success   bool         `json:"success"`
+	Message   string       `json:"message,omitempty"`
+	ErrorCode string       `json:"error_code,omitempty"` // Solo presente cuando success=false
+	Errors    []FieldError `json:"errors,omitempty"`     // Campos inválidos cuando error_code=INVALID_PAYLOAD
+	Processed bool         `json:"processed"`
+	Timestamp time.Time    `json:"timestamp"`
 }
 
+// Códigos de error legibles por máquina de WebhookResponse.ErrorCode
+const (
+	ErrorCodeUnreadableBody    = "UNREADABLE_BODY"    // 400: no se pudo leer el body
+	ErrorCodeMalformedJSON     = "MALFORMED_JSON"     // 400: el body no es JSON válido
+	ErrorCodeUnknownDataType   = "UNKNOWN_DATA_TYPE"  // 400: data_type sin procesador registrado
+	ErrorCodeInvalidPayload    = "INVALID_PAYLOAD"    // 422: el payload no cumple la estructura de su data_type
+	ErrorCodeRejected          = "REJECTED"           // 422: el procesador rechazó el evento de forma definitiva
+	ErrorCodeProcessingFailed  = "PROCESSING_FAILED"  // 500: error inesperado del procesador, se puede reintentar
+	ErrorCodeTemporarilyFailed = "TEMPORARILY_FAILED" // 503: error transitorio del procesador, se debe reintentar
+	ErrorCodeInboxUnavailable  = "INBOX_UNAVAILABLE"  // 503: no se pudo persistir el webhook, se debe reintentar
+	ErrorCodeQueueFull         = "QUEUE_FULL"         // 503: la cola asíncrona está llena, se debe reintentar
+	ErrorCodeShuttingDown      = "SHUTTING_DOWN"      // 503: el receptor se está deteniendo, se debe reintentar
+)
+
 // WebhookHeaders representa los headers importantes del webhook
 type WebhookHeaders struct {
 	Signature string `json:"signature"`
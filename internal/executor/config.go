@@ -0,0 +1,146 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"webhook_receiver/internal/processor"
+)
+
+// DefaultTimeout es el tiempo máximo de ejecución de un comando sin timeout configurado
+const DefaultTimeout = 30 * time.Second
+
+// DefaultConcurrency es el número máximo de comandos ejecutándose a la vez
+const DefaultConcurrency = 4
+
+// envName valida los nombres de las variables de entorno configuradas
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Hook es un comando que se ejecuta con cada evento que pasa sus filtros. Los filtros
+// vacíos no filtran.
+type Hook struct {
+	Name string `yaml:"name"`
+	// Command se ejecuta con /bin/sh -c, así que admite pipelines y redirecciones
+	Command      string   `yaml:"command"`
+	DataTypes    []string `yaml:"data_types"`
+	TriggerTypes []string `yaml:"trigger_types"`
+	ContractIDs  []int    `yaml:"contract_ids"`
+	// Timeout del comando, por ejemplo "10s" (por defecto DefaultTimeout)
+	Timeout time.Duration `yaml:"timeout"`
+	// Env define variables de entorno con campos del payload: nombre -> ruta separada por
+	// puntos (por ejemplo BILL_TOTAL: bill.total o FIRST_HOUR: data.consumption.0.hour)
+	Env map[string]string `yaml:"env"`
+	// Dir es el directorio de trabajo del comando (por defecto el del receptor)
+	Dir string `yaml:"dir"`
+}
+
+// Matches indica si event pasa los filtros del hook
+func (h Hook) Matches(event processor.Event) bool {
+	return matchString(h.DataTypes, event.DataType) &&
+		matchString(h.TriggerTypes, event.TriggerType) &&
+		matchInt(h.ContractIDs, event.ContractID)
+}
+
+func matchString(allowed []string, value string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if a == value {
+			return true
+		}
+	}
+	return false
+}
+
+func matchInt(allowed []int, value int) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if a == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Config es el formato del archivo HOOKS_FILE:
+//
+//	concurrency: 4
+//	hooks:
+//	  - name: on-paid
+//	    command: /opt/scripts/on-paid.sh
+//	    data_types: [bills]
+//	    trigger_types: [paid]
+//	    timeout: 10s
+//	    env:
+//	      BILL_ID: bill.bill_id
+//	      BILL_TOTAL: bill.total
+//	  - name: archive-consumption
+//	    command: gzip -c > /var/archive/consumption-$WEBHOOK_CONTRACT_ID-$(date +%s).json.gz
+//	    data_types: [consumption]
+type Config struct {
+	// Concurrency es el número máximo de comandos ejecutándose a la vez (por defecto DefaultConcurrency)
+	Concurrency int    `yaml:"concurrency"`
+	Hooks       []Hook `yaml:"hooks"`
+}
+
+// LoadConfig lee y valida la configuración de un archivo YAML
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read hooks file: %w", err)
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return Config{}, fmt.Errorf("failed to parse hooks file: %w", err)
+	}
+
+	if len(config.Hooks) == 0 {
+		return Config{}, errors.New("at least one hook is required")
+	}
+	if config.Concurrency < 0 {
+		return Config{}, fmt.Errorf("invalid concurrency %d", config.Concurrency)
+	}
+	names := make(map[string]bool, len(config.Hooks))
+	for i := range config.Hooks {
+		hook := &config.Hooks[i]
+		if err := hook.validate(); err != nil {
+			return Config{}, fmt.Errorf("hook %d: %w", i, err)
+		}
+		if names[hook.Name] {
+			return Config{}, fmt.Errorf("duplicate hook name %q", hook.Name)
+		}
+		names[hook.Name] = true
+	}
+	return config, nil
+}
+
+// validate verifica el hook
+func (h *Hook) validate() error {
+	if h.Name == "" {
+		return errors.New("name is required")
+	}
+	if h.Command == "" {
+		return fmt.Errorf("%s: command is required", h.Name)
+	}
+	if h.Timeout < 0 {
+		return fmt.Errorf("%s: invalid timeout %s", h.Name, h.Timeout)
+	}
+	for name, path := range h.Env {
+		if !envName.MatchString(name) {
+			return fmt.Errorf("%s: invalid environment variable name %q", h.Name, name)
+		}
+		if path == "" {
+			return fmt.Errorf("%s: environment variable %s needs a payload field", h.Name, name)
+		}
+	}
+	return nil
+}
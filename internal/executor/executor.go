@@ -0,0 +1,325 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"webhook_receiver/internal/processor"
+	"webhook_receiver/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxOutput es el máximo de bytes de la salida de un comando que se guardan en su registro
+const maxOutput = 4 << 10
+
+// Run es el registro de una ejecución de un hook
+type Run struct {
+	Hook           string
+	DataType       string
+	TriggerType    string
+	WebhookID      int
+	ContractID     int
+	IdempotencyKey string
+	// ExitCode es el código de salida del comando; -1 si no terminó (timeout, error al iniciarlo, ...)
+	ExitCode  int
+	Error     string
+	Output    string // últimos bytes de stdout y stderr
+	StartedAt time.Time
+	Duration  time.Duration
+}
+
+// Recorder guarda el registro de cada ejecución (por ejemplo en la base de datos)
+type Recorder interface {
+	RecordRun(ctx context.Context, run Run) error
+}
+
+// Executor ejecuta los comandos configurados con cada evento procesado. El payload se
+// envía al comando por stdin y los campos seleccionados como variables de entorno.
+type Executor struct {
+	hooks    []Hook
+	slots    chan struct{}
+	recorder Recorder
+
+	// ctx es el contexto base de los comandos; Close lo cancela si vence su plazo
+	ctx    context.Context
+	cancel context.CancelFunc
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// Option configura opciones adicionales del Executor
+type Option func(*Executor)
+
+// WithRecorder guarda el resultado de cada ejecución en recorder, además de los logs
+func WithRecorder(recorder Recorder) Option {
+	return func(e *Executor) {
+		e.recorder = recorder
+	}
+}
+
+// NewExecutor crea una nueva instancia del Executor
+func NewExecutor(config Config, opts ...Option) *Executor {
+	concurrency := config.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &Executor{
+		hooks:  config.Hooks,
+		slots:  make(chan struct{}, concurrency),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Run lanza en segundo plano los hooks que aceptan event. Implementa processor.Hook.
+// Espera a que haya un cupo libre para cada comando, así que si los comandos no dan abasto
+// el procesamiento de los webhooks se frena en lugar de acumular procesos. El resultado
+// de los comandos no afecta la respuesta del webhook: queda en el registro de ejecuciones.
+// Después de Close no lanza más comandos y retorna un error reintentable.
+func (e *Executor) Run(ctx context.Context, event processor.Event) error {
+	for _, hook := range e.hooks {
+		if !hook.Matches(event) {
+			continue
+		}
+
+		select {
+		case e.slots <- struct{}{}:
+		case <-ctx.Done():
+			return processor.Retryable(fmt.Errorf("no slot available to run hook %s: %w", hook.Name, ctx.Err()))
+		}
+
+		e.mu.Lock()
+		if e.closed {
+			e.mu.Unlock()
+			<-e.slots
+			return processor.Retryable(fmt.Errorf("cannot run hook %s: executor is closed", hook.Name))
+		}
+		e.wg.Add(1)
+		e.mu.Unlock()
+
+		// El comando sigue corriendo después de responder el webhook: su span conserva la
+		// traza pero no el contexto (ni la cancelación) de la petición
+		spanCtx := trace.ContextWithSpanContext(e.ctx, trace.SpanContextFromContext(ctx))
+		go func(hook Hook) {
+			defer e.wg.Done()
+			defer func() { <-e.slots }()
+			e.record(e.execute(spanCtx, hook, event))
+		}(hook)
+	}
+	return nil
+}
+
+// Close deja de lanzar comandos y espera los que están corriendo, con su registro; si ctx
+// expira los termina y espera a que se registren
+func (e *Executor) Close(ctx context.Context) error {
+	e.mu.Lock()
+	e.closed = true
+	e.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		e.cancel()
+		return nil
+	case <-ctx.Done():
+		e.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// execute ejecuta el comando del hook y retorna su registro
+func (e *Executor) execute(ctx context.Context, hook Hook, event processor.Event) (run Run) {
+	ctx, span := tracing.Start(ctx, "exec "+hook.Name,
+		trace.WithAttributes(tracing.EventAttributes(event.DataType, event.TriggerType, event.WebhookID, event.ContractID)...))
+	defer func() {
+		span.SetAttributes(attribute.Int("process.exit.code", run.ExitCode))
+		if run.Error != "" {
+			span.SetStatus(codes.Error, run.Error)
+		}
+		span.End()
+	}()
+
+	run = Run{
+		Hook:           hook.Name,
+		DataType:       event.DataType,
+		TriggerType:    event.TriggerType,
+		WebhookID:      event.WebhookID,
+		ContractID:     event.ContractID,
+		IdempotencyKey: event.Headers.IDKey,
+		ExitCode:       -1,
+		StartedAt:      time.Now(),
+	}
+
+	timeout := hook.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	env, err := environment(hook, event)
+	if err != nil {
+		run.Error = err.Error()
+		return run
+	}
+
+	output := &tailBuffer{limit: maxOutput}
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", hook.Command)
+	cmd.Dir = hook.Dir
+	cmd.Env = env
+	cmd.Stdin = bytes.NewReader(event.Body)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	killGroup(cmd)
+	// No esperar indefinidamente a procesos que hereden stdout y sobrevivan al timeout
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	run.Duration = time.Since(run.StartedAt)
+	run.Output = output.String()
+	if cmd.ProcessState != nil {
+		run.ExitCode = cmd.ProcessState.ExitCode()
+	}
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		run.Error = fmt.Sprintf("timed out after %s", timeout)
+	case errors.Is(ctx.Err(), context.Canceled):
+		run.Error = "canceled on shutdown"
+	case err != nil:
+		run.Error = err.Error()
+	}
+	return run
+}
+
+// record deja el resultado de la ejecución en los logs y en el Recorder
+func (e *Executor) record(run Run) {
+	attrs := []any{"hook", run.Hook, "data_type", run.DataType, "webhook_id", run.WebhookID,
+		"contract_id", run.ContractID, "exit_code", run.ExitCode, "duration", run.Duration}
+	if run.Error == "" {
+		slog.Info("Hook finished", attrs...)
+	} else {
+		slog.Warn("Hook failed", append(attrs, "error", run.Error, "output", run.Output)...)
+	}
+
+	if e.recorder == nil {
+		return
+	}
+	if err := e.recorder.RecordRun(context.Background(), run); err != nil {
+		slog.Error("Failed to record hook run", "hook", run.Hook, "error", err)
+	}
+}
+
+// inheritedEnv son las únicas variables del receptor que reciben los comandos; el resto
+// (WEBHOOK_SECRET_KEY, ADMIN_TOKEN, credenciales de los brokers, ...) no se expone
+var inheritedEnv = []string{"PATH", "HOME", "USER", "LOGNAME", "SHELL", "LANG", "LC_ALL", "LC_CTYPE", "TZ", "TMPDIR"}
+
+// environment arma las variables de entorno del comando: las de inheritedEnv, las del evento
+// (WEBHOOK_*) y los campos del payload configurados en el hook
+func environment(hook Hook, event processor.Event) ([]string, error) {
+	var env []string
+	for _, name := range inheritedEnv {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	env = append(env,
+		"WEBHOOK_NAME="+hook.Name,
+		"WEBHOOK_DATA_TYPE="+event.DataType,
+		"WEBHOOK_TRIGGER_TYPE="+event.TriggerType,
+		"WEBHOOK_ID="+strconv.Itoa(event.WebhookID),
+		"WEBHOOK_CONTRACT_ID="+strconv.Itoa(event.ContractID),
+		"WEBHOOK_IDEMPOTENCY_KEY="+event.Headers.IDKey,
+	)
+	if len(hook.Env) == 0 {
+		return env, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(event.Body))
+	decoder.UseNumber()
+	var payload interface{}
+	if err := decoder.Decode(&payload); err != nil {
+		return nil, fmt.Errorf("failed to decode payload: %w", err)
+	}
+	for name, path := range hook.Env {
+		env = append(env, name+"="+field(payload, path))
+	}
+	return env, nil
+}
+
+// field busca path (claves e índices separados por puntos) en el payload decodificado.
+// Los campos que no existen quedan vacíos; los objetos y arreglos se entregan como JSON.
+func field(payload interface{}, path string) string {
+	value := payload
+	for _, key := range strings.Split(path, ".") {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			value = node[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return ""
+			}
+			value = node[i]
+		default:
+			return ""
+		}
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+// tailBuffer conserva solo los últimos limit bytes escritos
+type tailBuffer struct {
+	limit int
+	buf   []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.limit {
+		b.buf = b.buf[len(b.buf)-b.limit:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	return string(b.buf)
+}
@@ -0,0 +1,144 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"webhook_receiver/internal/dto"
+	"webhook_receiver/internal/processor"
+)
+
+func TestEnvironment(t *testing.T) {
+	t.Setenv("PATH", "/usr/bin:/bin")
+	t.Setenv("WEBHOOK_SECRET_KEY", "super-secret")
+	t.Setenv("ADMIN_TOKEN", "admin-token")
+
+	hook := Hook{
+		Name: "notify",
+		Env:  map[string]string{"BILL_ID": "bill.bill_id", "MISSING": "bill.nope"},
+	}
+	event := processor.Event{
+		DataType:    "bills",
+		TriggerType: "paid",
+		WebhookID:   67890,
+		ContractID:  2001,
+		Body:        []byte(`{"bill":{"bill_id":1001}}`),
+		Headers:     dto.WebhookHeaders{IDKey: "key-1"},
+	}
+
+	env, err := environment(hook, event)
+	if err != nil {
+		t.Fatalf("environment() error = %v", err)
+	}
+	vars := make(map[string]string, len(env))
+	for _, entry := range env {
+		name, value, _ := strings.Cut(entry, "=")
+		vars[name] = value
+	}
+
+	tests := []struct {
+		name    string
+		want    string
+		present bool
+	}{
+		{name: "PATH", want: "/usr/bin:/bin", present: true},
+		{name: "WEBHOOK_SECRET_KEY"},
+		{name: "ADMIN_TOKEN"},
+		{name: "WEBHOOK_NAME", want: "notify", present: true},
+		{name: "WEBHOOK_DATA_TYPE", want: "bills", present: true},
+		{name: "WEBHOOK_TRIGGER_TYPE", want: "paid", present: true},
+		{name: "WEBHOOK_ID", want: "67890", present: true},
+		{name: "WEBHOOK_CONTRACT_ID", want: "2001", present: true},
+		{name: "WEBHOOK_IDEMPOTENCY_KEY", want: "key-1", present: true},
+		{name: "BILL_ID", want: "1001", present: true},
+		{name: "MISSING", want: "", present: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, ok := vars[tt.name]
+			if ok != tt.present || value != tt.want {
+				t.Errorf("%s = %q (present %v), want %q (present %v)", tt.name, value, ok, tt.want, tt.present)
+			}
+		})
+	}
+}
+
+// fakeRecorder guarda las ejecuciones registradas
+type fakeRecorder struct {
+	mu   sync.Mutex
+	runs []Run
+}
+
+func (r *fakeRecorder) RecordRun(_ context.Context, run Run) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runs = append(r.runs, run)
+	return nil
+}
+
+func (r *fakeRecorder) recorded() []Run {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Run(nil), r.runs...)
+}
+
+func TestExecutorClose(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("/bin/sh is not available")
+	}
+	event := processor.Event{DataType: "bills", TriggerType: "paid", WebhookID: 67890, ContractID: 2001, Body: []byte(`{}`)}
+
+	tests := []struct {
+		name       string
+		command    string
+		timeout    time.Duration
+		wantErrIs  error
+		wantExit   int
+		wantError  string
+		wantOutput string
+	}{
+		{name: "waits for the running command", command: "sleep 0.2; echo done", timeout: 5 * time.Second, wantOutput: "done\n"},
+		{name: "kills the command when ctx expires", command: "sleep 30", timeout: 100 * time.Millisecond, wantErrIs: context.DeadlineExceeded, wantExit: -1, wantError: "canceled on shutdown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &fakeRecorder{}
+			e := NewExecutor(Config{Hooks: []Hook{{Name: "notify", Command: tt.command}}}, WithRecorder(recorder))
+			if err := e.Run(context.Background(), event); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			started := time.Now()
+			if err := e.Close(ctx); !errors.Is(err, tt.wantErrIs) {
+				t.Fatalf("Close() error = %v, want %v", err, tt.wantErrIs)
+			}
+			if elapsed := time.Since(started); elapsed > 5*time.Second {
+				t.Errorf("Close() took %s", elapsed)
+			}
+
+			// Close retorna después de registrar la ejecución
+			runs := recorder.recorded()
+			if len(runs) != 1 {
+				t.Fatalf("recorded %d runs, want 1", len(runs))
+			}
+			if run := runs[0]; run.ExitCode != tt.wantExit || run.Error != tt.wantError || run.Output != tt.wantOutput {
+				t.Errorf("run = exit %d, error %q, output %q, want exit %d, error %q, output %q",
+					run.ExitCode, run.Error, run.Output, tt.wantExit, tt.wantError, tt.wantOutput)
+			}
+
+			if err := e.Run(context.Background(), event); !processor.IsRetryable(err) {
+				t.Errorf("Run() after Close error = %v, want a retryable error", err)
+			}
+			if len(recorder.recorded()) != 1 {
+				t.Error("Run() after Close started a command")
+			}
+		})
+	}
+}
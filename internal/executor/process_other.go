@@ -0,0 +1,8 @@
+//go:build !unix
+
+package executor
+
+import "os/exec"
+
+// killGroup no hace nada fuera de unix: al vencer el timeout solo se termina el shell
+func killGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,64 @@
+package forwarder
+
+import (
+	"sync"
+	"time"
+
+	"webhook_receiver/internal/idempotency"
+	"webhook_receiver/internal/processor"
+)
+
+// DefaultDeliveryTTL es cuánto se recuerda que un target ya recibió un evento; cubre las
+// reentregas de bia-consumptions y los reintentos del modo asíncrono
+const DefaultDeliveryTTL = 24 * time.Hour
+
+// deliveryKey identifica el evento frente a los targets: X-Idempotency-Key o, si no viene,
+// el SHA-256 del body (las reentregas conservan el body)
+func deliveryKey(event processor.Event) string {
+	if event.Headers.IDKey != "" {
+		return event.Headers.IDKey
+	}
+	return idempotency.HashBody(event.Body)
+}
+
+// deliveries recuerda los targets que ya recibieron (o rechazaron con 4xx) cada evento, para
+// que al reentregar un fan-out parcial solo se reintenten los targets que fallaron
+type deliveries struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	entries   map[string]time.Time // target + clave del evento -> vencimiento
+	lastSweep time.Time
+}
+
+func newDeliveries(ttl time.Duration) *deliveries {
+	return &deliveries{
+		ttl:     ttl,
+		entries: make(map[string]time.Time),
+	}
+}
+
+// done indica si target ya recibió el evento key
+func (d *deliveries) done(target, key string, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	expiresAt, ok := d.entries[target+"\x00"+key]
+	return ok && now.Before(expiresAt)
+}
+
+// record registra que target recibió el evento key y descarta las entradas vencidas
+func (d *deliveries) record(target, key string, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.entries[target+"\x00"+key] = now.Add(d.ttl)
+	if now.Sub(d.lastSweep) < time.Minute {
+		return
+	}
+	d.lastSweep = now
+	for entry, expiresAt := range d.entries {
+		if !now.Before(expiresAt) {
+			delete(d.entries, entry)
+		}
+	}
+}
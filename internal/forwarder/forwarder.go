@@ -0,0 +1,273 @@
+package forwarder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"webhook_receiver/internal/cloudevents"
+	"webhook_receiver/internal/processor"
+	"webhook_receiver/internal/signature"
+	"webhook_receiver/internal/tracing"
+	"webhook_receiver/internal/worker"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Valores por defecto del Forwarder
+const (
+	DefaultTimeout          = 10 * time.Second
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 30 * time.Second
+)
+
+// DefaultRetryPolicy reintenta rápido: el reenvío ocurre mientras se procesa el webhook
+var DefaultRetryPolicy = worker.RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+}
+
+// StatusError es una respuesta no exitosa de un target
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d", e.StatusCode)
+}
+
+// Temporary indica si el status justifica reintentar (408, 429 y 5xx)
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode >= http.StatusInternalServerError
+}
+
+// Forwarder reenvía los eventos verificados a los targets configurados, firmando cada
+// envío con el secreto del target
+type Forwarder struct {
+	targets     []Target
+	client      *http.Client
+	retryPolicy worker.RetryPolicy
+	breakers    map[string]*breaker
+	deliveries  *deliveries
+}
+
+// Option configura opciones adicionales del Forwarder
+type Option func(*Forwarder)
+
+// WithRetryPolicy define los reintentos de cada target ante errores de red, 408, 429 o 5xx
+func WithRetryPolicy(policy worker.RetryPolicy) Option {
+	return func(f *Forwarder) {
+		f.retryPolicy = policy.WithDefaults()
+	}
+}
+
+// WithCircuitBreaker define cuántos fallos consecutivos abren el circuito de un target
+// y cuánto tiempo permanece abierto
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(f *Forwarder) {
+		if threshold <= 0 {
+			threshold = DefaultBreakerThreshold
+		}
+		if cooldown <= 0 {
+			cooldown = DefaultBreakerCooldown
+		}
+		for name := range f.breakers {
+			f.breakers[name] = newBreaker(threshold, cooldown)
+		}
+	}
+}
+
+// WithHTTPClient reemplaza el cliente HTTP (por ejemplo para usar un transporte propio)
+func WithHTTPClient(client *http.Client) Option {
+	return func(f *Forwarder) {
+		if client != nil {
+			f.client = client
+		}
+	}
+}
+
+// NewForwarder crea una nueva instancia del Forwarder
+func NewForwarder(targets []Target, opts ...Option) *Forwarder {
+	f := &Forwarder{
+		targets:     targets,
+		client:      &http.Client{},
+		retryPolicy: DefaultRetryPolicy,
+		breakers:    make(map[string]*breaker, len(targets)),
+		deliveries:  newDeliveries(DefaultDeliveryTTL),
+	}
+	for _, target := range targets {
+		f.breakers[target.Name] = newBreaker(DefaultBreakerThreshold, DefaultBreakerCooldown)
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// Forward envía event a todos los targets que lo aceptan, en paralelo. Implementa processor.Hook.
+// Si algún target falla por un error transitorio se retorna un error reintentable para que el
+// webhook se vuelva a entregar; en la reentrega solo se envía a los targets que aún no lo
+// recibieron. Un 4xx indica que el target rechazó el evento: se registra en los logs y no se
+// reintenta ni hace fallar el webhook.
+func (f *Forwarder) Forward(ctx context.Context, event processor.Event) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	key := deliveryKey(event)
+	for _, target := range f.targets {
+		if !target.Matches(event) || f.deliveries.done(target.Name, key, time.Now()) {
+			continue
+		}
+
+		wg.Add(1)
+		go func(target Target) {
+			defer wg.Done()
+			err := f.send(ctx, target, event, key)
+			if err != nil && temporary(err) {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+				return
+			}
+			if err != nil {
+				slog.ErrorContext(ctx, "Forward rejected by target, not retrying", "target", target.Name, "error", err)
+			}
+			f.deliveries.record(target.Name, key, time.Now())
+		}(target)
+	}
+	wg.Wait()
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return processor.Retryable(fmt.Errorf("forwarding failed: %w", errors.Join(errs...)))
+}
+
+// send envía el evento a un target respetando su circuit breaker y reintentando los errores transitorios
+func (f *Forwarder) send(ctx context.Context, target Target, event processor.Event, key string) (err error) {
+	ctx, span := tracing.Start(ctx, "forward "+target.Name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(tracing.EventAttributes(event.DataType, event.TriggerType, event.WebhookID, event.ContractID)...),
+		trace.WithAttributes(attribute.String("forward.target", target.Name), attribute.String("url.full", target.URL)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	b := f.breakers[target.Name]
+
+	for attempt := 1; attempt <= f.retryPolicy.MaxAttempts; attempt++ {
+		span.SetAttributes(attribute.Int("forward.attempts", attempt))
+		if !b.allow(time.Now()) {
+			return fmt.Errorf("target %s: %w", target.Name, ErrCircuitOpen)
+		}
+
+		err = f.post(ctx, target, event, key)
+		// Un 4xx indica que el target está disponible pero rechazó el evento: no abre el circuito
+		b.record(err == nil || !temporary(err), time.Now())
+		if err == nil {
+			return nil
+		}
+		if !temporary(err) || attempt == f.retryPolicy.MaxAttempts {
+			break
+		}
+
+		slog.WarnContext(ctx, "Forward failed, retrying", "target", target.Name, "attempt", attempt,
+			"max_attempts", f.retryPolicy.MaxAttempts, "error", err)
+		select {
+		case <-time.After(f.retryPolicy.Backoff(attempt)):
+		case <-ctx.Done():
+			return fmt.Errorf("target %s: %w", target.Name, ctx.Err())
+		}
+	}
+	return fmt.Errorf("target %s: %w", target.Name, err)
+}
+
+// post hace un intento de envío firmado con el secreto del target; key se envía como X-Idempotency-Key
+func (f *Forwarder) post(ctx context.Context, target Target, event processor.Event, key string) error {
+	timeout := target.timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	body, contentType, ceHeaders, err := encode(target, event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	// La firma cubre el body que se envía, sea el webhook original o el CloudEvent
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+	sig, err := signature.Header([]byte(target.Secret), timestamp, body, target.Schemes...)
+	if err != nil {
+		return err
+	}
+
+	webhookID := event.Headers.WebhookID
+	if webhookID == "" {
+		webhookID = strconv.Itoa(event.WebhookID)
+	}
+	req.Header.Set("Content-Type", contentType)
+	for name, value := range ceHeaders {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("X-Webhook-Signature", sig)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-ID", webhookID)
+	req.Header.Set("X-Idempotency-Key", key)
+	// Propagar la traza al servicio interno (traceparent)
+	tracing.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Consumir el body para reutilizar la conexión
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &StatusError{StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+// encode retorna el body, el Content-Type y los headers de CloudEvents con los que se envía
+// event a target
+func encode(target Target, event processor.Event) ([]byte, string, map[string]string, error) {
+	if target.mode == cloudevents.ModeNone {
+		return event.Body, "application/json", nil, nil
+	}
+	msg, err := cloudevents.FromEvent(event).Encode(target.mode, cloudevents.HTTPHeaderPrefix)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return msg.Body, msg.ContentType, msg.Headers, nil
+}
+
+// temporary indica si vale la pena reintentar el envío más tarde
+func temporary(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Temporary()
+	}
+	// Errores de red, timeouts y circuito abierto
+	return true
+}
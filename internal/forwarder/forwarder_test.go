@@ -0,0 +1,143 @@
+package forwarder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"webhook_receiver/internal/dto"
+	"webhook_receiver/internal/idempotency"
+	"webhook_receiver/internal/processor"
+	"webhook_receiver/internal/signature"
+	"webhook_receiver/internal/worker"
+)
+
+// recorder es un target de prueba que responde los status indicados en orden y guarda las
+// claves de idempotencia recibidas
+type recorder struct {
+	mu       sync.Mutex
+	statuses []int
+	keys     []string
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := http.StatusOK
+	if len(r.keys) < len(r.statuses) {
+		status = r.statuses[len(r.keys)]
+	}
+	r.keys = append(r.keys, req.Header.Get("X-Idempotency-Key"))
+	w.WriteHeader(status)
+}
+
+func (r *recorder) received() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.keys...)
+}
+
+func TestForward(t *testing.T) {
+	body := []byte(`{"webhook_id":1,"data_type":"bills"}`)
+
+	tests := []struct {
+		name       string
+		idKey      string
+		statuses   map[string][]int // status de cada intento por target
+		deliveries int              // veces que se entrega el webhook
+		wantErr    []bool           // resultado de cada entrega
+		wantSent   map[string]int   // envíos recibidos por target
+	}{
+		{
+			name:       "all targets succeed",
+			idKey:      "key-1",
+			statuses:   map[string][]int{"a": nil, "b": nil},
+			deliveries: 2,
+			wantErr:    []bool{false, false},
+			wantSent:   map[string]int{"a": 1, "b": 1},
+		},
+		{
+			name:       "partial fan-out only resends to the failed target",
+			idKey:      "key-2",
+			statuses:   map[string][]int{"a": nil, "b": {http.StatusServiceUnavailable}},
+			deliveries: 2,
+			wantErr:    []bool{true, false},
+			wantSent:   map[string]int{"a": 1, "b": 2},
+		},
+		{
+			name:       "4xx is not retried and does not fail the webhook",
+			idKey:      "key-3",
+			statuses:   map[string][]int{"a": nil, "b": {http.StatusBadRequest}},
+			deliveries: 2,
+			wantErr:    []bool{false, false},
+			wantSent:   map[string]int{"a": 1, "b": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var targets []Target
+			recorders := make(map[string]*recorder)
+			for name, statuses := range tt.statuses {
+				rec := &recorder{statuses: statuses}
+				server := httptest.NewServer(rec)
+				defer server.Close()
+				recorders[name] = rec
+				targets = append(targets, Target{Name: name, URL: server.URL, Secret: "s", Schemes: []string{signature.SchemeV1}})
+			}
+			fwd := NewForwarder(targets, WithRetryPolicy(worker.RetryPolicy{MaxAttempts: 1}))
+
+			event := processor.Event{DataType: "bills", Body: body, Headers: dto.WebhookHeaders{IDKey: tt.idKey}}
+			for i := 0; i < tt.deliveries; i++ {
+				err := fwd.Forward(context.Background(), event)
+				if (err != nil) != tt.wantErr[i] {
+					t.Fatalf("delivery %d: Forward() error = %v, want error %v", i+1, err, tt.wantErr[i])
+				}
+				if err != nil && !processor.IsRetryable(err) {
+					t.Errorf("delivery %d: Forward() error = %v, want retryable", i+1, err)
+				}
+			}
+			for name, want := range tt.wantSent {
+				if got := len(recorders[name].received()); got != want {
+					t.Errorf("target %s received %d requests, want %d", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestForwardDerivesIdempotencyKey(t *testing.T) {
+	rec := &recorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	body := []byte(`{"webhook_id":2,"data_type":"consumption"}`)
+	fwd := NewForwarder([]Target{{Name: "a", URL: server.URL, Secret: "s", Schemes: []string{signature.SchemeV1}}})
+	if err := fwd.Forward(context.Background(), processor.Event{DataType: "consumption", Body: body}); err != nil {
+		t.Fatalf("Forward() error = %v", err)
+	}
+
+	keys := rec.received()
+	if len(keys) != 1 || keys[0] != idempotency.HashBody(body) {
+		t.Fatalf("X-Idempotency-Key = %v, want the body hash %s", keys, idempotency.HashBody(body))
+	}
+}
+
+func TestDeliveriesExpire(t *testing.T) {
+	d := newDeliveries(time.Minute)
+	now := time.Now()
+	d.record("a", "key", now)
+
+	if !d.done("a", "key", now.Add(30*time.Second)) {
+		t.Error("done() = false before the TTL")
+	}
+	if d.done("b", "key", now) {
+		t.Error("done() = true for another target")
+	}
+	if d.done("a", "key", now.Add(time.Minute)) {
+		t.Error("done() = true after the TTL")
+	}
+}
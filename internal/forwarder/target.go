@@ -0,0 +1,141 @@
+package forwarder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"webhook_receiver/internal/cloudevents"
+	"webhook_receiver/internal/processor"
+	"webhook_receiver/internal/signature"
+)
+
+// Target es un servicio interno al que se reenvían los webhooks verificados.
+// Los filtros vacíos no filtran: un target sin filtros recibe todos los eventos.
+type Target struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+	// Schemes son los esquemas de firma a enviar (por defecto solo v1)
+	Schemes      []string `json:"schemes,omitempty"`
+	DataTypes    []string `json:"data_types,omitempty"`
+	TriggerTypes []string `json:"trigger_types,omitempty"`
+	ContractIDs  []int    `json:"contract_ids,omitempty"`
+	// Timeout de cada intento, por ejemplo "5s" (por defecto DefaultTimeout)
+	Timeout string `json:"timeout,omitempty"`
+	// CloudEvents envía el evento como CloudEvent en modo "structured" o "binary"
+	// (vacío = el webhook original)
+	CloudEvents string `json:"cloudevents,omitempty"`
+
+	timeout time.Duration
+	mode    cloudevents.Mode
+}
+
+// Matches indica si event pasa los filtros del target
+func (t Target) Matches(event processor.Event) bool {
+	return matchString(t.DataTypes, event.DataType) &&
+		matchString(t.TriggerTypes, event.TriggerType) &&
+		matchInt(t.ContractIDs, event.ContractID)
+}
+
+func matchString(allowed []string, value string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if a == value {
+			return true
+		}
+	}
+	return false
+}
+
+func matchInt(allowed []int, value int) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if a == value {
+			return true
+		}
+	}
+	return false
+}
+
+// targetsFile es el formato del archivo FORWARD_TARGETS_FILE:
+//
+//	{
+//	  "targets": [
+//	    {"name": "billing", "url": "http://billing/webhooks", "secret": "...", "data_types": ["bills"], "trigger_types": ["paid"]},
+//	    {"name": "analytics", "url": "http://analytics/ingest", "secret": "...", "contract_ids": [1001, 1002]}
+//	  ]
+//	}
+type targetsFile struct {
+	Targets []Target `json:"targets"`
+}
+
+// LoadTargets lee y valida los targets de un archivo JSON
+func LoadTargets(path string) ([]Target, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read forward targets file: %w", err)
+	}
+
+	var file targetsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse forward targets file: %w", err)
+	}
+
+	if len(file.Targets) == 0 {
+		return nil, errors.New("at least one forward target is required")
+	}
+	names := make(map[string]bool, len(file.Targets))
+	for i := range file.Targets {
+		target := &file.Targets[i]
+		if err := target.validate(); err != nil {
+			return nil, fmt.Errorf("forward target %d: %w", i, err)
+		}
+		if names[target.Name] {
+			return nil, fmt.Errorf("duplicate forward target name %q", target.Name)
+		}
+		names[target.Name] = true
+	}
+	return file.Targets, nil
+}
+
+// validate verifica el target y completa los valores por defecto
+func (t *Target) validate() error {
+	if t.Name == "" {
+		return errors.New("name is required")
+	}
+	parsed, err := url.Parse(t.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%s: url must be an absolute http or https URL", t.Name)
+	}
+	if t.Secret == "" {
+		return fmt.Errorf("%s: secret is required", t.Name)
+	}
+
+	if len(t.Schemes) == 0 {
+		t.Schemes = []string{signature.SchemeV1}
+	}
+	for _, scheme := range t.Schemes {
+		if scheme != signature.SchemeLegacy && scheme != signature.SchemeV1 {
+			return fmt.Errorf("%s: unsupported signature scheme %q", t.Name, scheme)
+		}
+	}
+
+	if t.mode, err = cloudevents.ParseMode(t.CloudEvents); err != nil {
+		return fmt.Errorf("%s: %w", t.Name, err)
+	}
+
+	if t.Timeout != "" {
+		if t.timeout, err = time.ParseDuration(t.Timeout); err != nil || t.timeout <= 0 {
+			return fmt.Errorf("%s: invalid timeout %q", t.Name, t.Timeout)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"webhook_receiver/internal/deadletter"
+	"webhook_receiver/internal/dto"
+	"webhook_receiver/internal/logging"
+	"webhook_receiver/internal/metrics"
+	"webhook_receiver/internal/processor"
+	"webhook_receiver/internal/worker"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// enqueue decodifica el webhook y lo encola para procesamiento asíncrono.
+// Los errores de payload se responden de inmediato; una cola llena responde 503.
+func (h *WebhookHandler) enqueue(c *gin.Context, recordID string, receivedAt time.Time, body []byte, headers dto.WebhookHeaders) {
+	ctx := c.Request.Context()
+	event, err := h.decode(ctx, recordID, receivedAt, body, headers)
+	if err != nil {
+		h.respondProcessingError(c, err)
+		return
+	}
+
+	job := worker.Job{
+		RecordID:    recordID,
+		Event:       event,
+		SpanContext: trace.SpanContextFromContext(ctx),
+		RequestID:   c.GetString(logging.RequestIDKey),
+	}
+	if err := h.pool.Enqueue(job); err != nil {
+		// bia-consumptions reintentará la entrega, así que el registro no se reprocesa desde el inbox
+		h.markInboxReturned(recordID, err)
+		h.metrics.ObserveOutcome(event.DataType, event.TriggerType, metrics.OutcomeQueueFull)
+
+		c.Header("Retry-After", retryAfterSeconds)
+		code := dto.ErrorCodeQueueFull
+		if errors.Is(err, worker.ErrPoolClosed) {
+			code = dto.ErrorCodeShuttingDown
+		}
+		h.respondError(c, http.StatusServiceUnavailable, code, "Failed to enqueue "+event.DataType+" webhook: "+err.Error())
+		return
+	}
+
+	// Log de la recepción del webhook
+	c.Header("X-Webhook-Received", "true")
+
+	c.JSON(http.StatusAccepted, dto.WebhookResponse{
+		Success:   true,
+		Message:   "Webhook accepted for asynchronous processing",
+		Processed: false,
+		Timestamp: time.Now(),
+	})
+}
+
+// runJob procesa un webhook encolado, reintentando con backoff exponencial los errores
+// no definitivos. Si se agotan los reintentos el evento pasa al dead-letter queue.
+func (h *WebhookHandler) runJob(ctx context.Context, job worker.Job) {
+	if job.SpanContext.IsValid() {
+		ctx = trace.ContextWithSpanContext(ctx, job.SpanContext)
+	}
+	if job.RequestID != "" {
+		ctx = logging.With(ctx, "request_id", job.RequestID)
+	}
+
+	var err error
+	attempts := 0
+	for attempts < h.retryPolicy.MaxAttempts {
+		attempts++
+		if _, err = h.process(logging.With(ctx, "attempt", attempts), job.Event); err == nil || processor.IsPermanent(err) {
+			break
+		}
+		if attempts == h.retryPolicy.MaxAttempts {
+			break
+		}
+
+		select {
+		case <-time.After(h.retryPolicy.Backoff(attempts)):
+		case <-ctx.Done():
+			// El receptor se está deteniendo: el registro queda pendiente en el inbox
+			h.markInbox(job.RecordID, err)
+			return
+		}
+	}
+
+	if err != nil && ctx.Err() != nil {
+		// El último intento se canceló por el apagado: no es un fallo del evento
+		h.markInbox(job.RecordID, err)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to process queued webhook", append(logging.EventAttrs(job.Event),
+			"record_id", job.RecordID, "attempts", attempts, "error", err)...)
+	}
+	h.settle(ctx, job, attempts, err, true)
+}
+
+// Shutdown deja de aceptar webhooks asíncronos y espera a que terminen los encolados. Si ctx
+// expira cancela los que están en curso y espera a que los workers se detengan: al retornar
+// ningún webhook usa ya el inbox, la base de datos ni los sinks.
+func (h *WebhookHandler) Shutdown(ctx context.Context) error {
+	if h.pool == nil {
+		return nil
+	}
+	return h.pool.Shutdown(ctx)
+}
+
+// deadLetter guarda el evento de job en el dead-letter queue. Si el job es un redrive
+// se actualiza la entrada original acumulando los intentos. Retorna false si no se pudo guardar.
+func (h *WebhookHandler) deadLetter(ctx context.Context, job worker.Job, attempts int, cause error) bool {
+	if h.deadLetters == nil {
+		return false
+	}
+
+	now := time.Now()
+	entry := deadletter.Entry{
+		ID:            job.DeadLetterID,
+		DataType:      job.Event.DataType,
+		TriggerType:   job.Event.TriggerType,
+		WebhookID:     job.Event.WebhookID,
+		ContractID:    job.Event.ContractID,
+		InboxRecordID: job.RecordID,
+		Headers:       job.Event.Headers,
+		Payload:       job.Event.Body,
+		ReceivedAt:    job.Event.ReceivedAt,
+		Attempts:      attempts,
+		LastError:     cause.Error(),
+		FirstFailedAt: now,
+		LastFailedAt:  now,
+	}
+
+	if entry.ID == "" {
+		entry.ID = deadletter.NewID()
+	} else if previous, err := h.deadLetters.Get(ctx, entry.ID); err == nil {
+		entry.Attempts += previous.Attempts
+		entry.FirstFailedAt = previous.FirstFailedAt
+		if entry.InboxRecordID == "" {
+			entry.InboxRecordID = previous.InboxRecordID
+		}
+	}
+
+	if err := h.deadLetters.Put(context.WithoutCancel(ctx), entry); err != nil {
+		slog.ErrorContext(ctx, "Failed to store dead letter", append(logging.EventAttrs(job.Event), "error", err)...)
+		return false
+	}
+	slog.WarnContext(ctx, "Webhook moved to dead-letter queue", append(logging.EventAttrs(job.Event),
+		"dead_letter_id", entry.ID, "attempts", entry.Attempts, "error", entry.LastError)...)
+	return true
+}
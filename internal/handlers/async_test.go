@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"webhook_receiver/internal/deadletter"
+	"webhook_receiver/internal/dto"
+	"webhook_receiver/internal/processor"
+	"webhook_receiver/internal/worker"
+)
+
+// scriptedProcessor retorna los errores de errs en orden (nil al agotarse) y puede quedar
+// bloqueado hasta que se cierre block
+type scriptedProcessor struct {
+	mu    sync.Mutex
+	errs  []error
+	calls int
+	block chan struct{}
+}
+
+func (p *scriptedProcessor) OnConsumption(ctx context.Context, payload dto.WebhookPayload) error {
+	p.mu.Lock()
+	p.calls++
+	var err error
+	if len(p.errs) > 0 {
+		err, p.errs = p.errs[0], p.errs[1:]
+	}
+	p.mu.Unlock()
+
+	if p.block != nil {
+		select {
+		case <-p.block:
+		case <-ctx.Done():
+			return processor.Retryable(ctx.Err())
+		}
+	}
+	return err
+}
+
+func (p *scriptedProcessor) OnBill(ctx context.Context, payload dto.BillWebhookPayload) error {
+	return nil
+}
+
+func (p *scriptedProcessor) callCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls
+}
+
+func newAsyncHandler(p processor.Processor, deadLetters deadletter.Store, queue worker.QueueOptions) *WebhookHandler {
+	return NewWebhookHandler(processor.NewDefaultRegistry(p),
+		WithAsync(worker.Options{Default: queue}),
+		WithDeadLetters(deadLetters),
+		WithRetryPolicy(worker.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}),
+	)
+}
+
+func TestReceiveWebhookAsyncBackpressure(t *testing.T) {
+	p := &scriptedProcessor{block: make(chan struct{})}
+	h := newAsyncHandler(p, deadletter.NewMemoryStore(), worker.QueueOptions{Workers: 1, QueueSize: 1})
+
+	// El primero ocupa el worker y el segundo la cola: el tercero no tiene lugar
+	wantStatuses := []int{http.StatusAccepted, http.StatusAccepted, http.StatusServiceUnavailable}
+	for i, want := range wantStatuses {
+		w, response := serveWebhook(t, h, testConsumptionBody)
+		if w.Code != want {
+			t.Fatalf("delivery %d: status = %d, want %d (%s)", i+1, w.Code, want, w.Body.String())
+		}
+		if i == 0 {
+			// Esperar a que el worker tome el primero para que el segundo quede en la cola
+			waitFor(t, func() bool { return p.callCount() == 1 })
+		}
+		if want == http.StatusServiceUnavailable {
+			if response.ErrorCode != dto.ErrorCodeQueueFull || w.Header().Get("Retry-After") == "" {
+				t.Errorf("error_code = %q, Retry-After = %q, want QUEUE_FULL with Retry-After", response.ErrorCode, w.Header().Get("Retry-After"))
+			}
+		}
+	}
+
+	close(p.block)
+	if err := h.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if calls := p.callCount(); calls != 2 {
+		t.Errorf("processed %d webhooks, want the 2 accepted", calls)
+	}
+
+	w, response := serveWebhook(t, h, testConsumptionBody)
+	if w.Code != http.StatusServiceUnavailable || response.ErrorCode != dto.ErrorCodeShuttingDown {
+		t.Errorf("after Shutdown: status = %d, error_code = %q, want 503 SHUTTING_DOWN", w.Code, response.ErrorCode)
+	}
+}
+
+func TestRunJobRetries(t *testing.T) {
+	errDown := processor.Retryable(errors.New("database is down"))
+
+	tests := []struct {
+		name           string
+		errs           []error
+		wantCalls      int
+		wantDeadLetter bool
+		wantAttempts   int
+	}{
+		{name: "succeeds after retrying", errs: []error{errDown, errDown}, wantCalls: 3},
+		{name: "exhausted retries go to the dead-letter queue", errs: []error{errDown, errDown, errDown}, wantCalls: 3, wantDeadLetter: true, wantAttempts: 3},
+		{
+			name:           "permanent error is not retried",
+			errs:           []error{processor.Permanent(errors.New("contract is closed"))},
+			wantCalls:      1,
+			wantDeadLetter: true,
+			wantAttempts:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &scriptedProcessor{errs: tt.errs}
+			deadLetters := deadletter.NewMemoryStore()
+			h := newAsyncHandler(p, deadLetters, worker.QueueOptions{Workers: 1, QueueSize: 1})
+
+			if w, _ := serveWebhook(t, h, testConsumptionBody); w.Code != http.StatusAccepted {
+				t.Fatalf("status = %d, want 202", w.Code)
+			}
+			if err := h.Shutdown(context.Background()); err != nil {
+				t.Fatalf("Shutdown() error = %v", err)
+			}
+
+			if calls := p.callCount(); calls != tt.wantCalls {
+				t.Errorf("processor calls = %d, want %d", calls, tt.wantCalls)
+			}
+			entries, err := deadLetters.List(context.Background(), deadletter.Filter{})
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if got := len(entries) > 0; got != tt.wantDeadLetter {
+				t.Fatalf("dead letters = %+v, want dead letter = %v", entries, tt.wantDeadLetter)
+			}
+			if tt.wantDeadLetter && (entries[0].Attempts != tt.wantAttempts || entries[0].ContractID != 1001) {
+				t.Errorf("dead letter = %+v, want %d attempts for contract 1001", entries[0], tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestShutdownWaitsForRunningJobs(t *testing.T) {
+	p := &scriptedProcessor{block: make(chan struct{})}
+	deadLetters := deadletter.NewMemoryStore()
+	h := newAsyncHandler(p, deadLetters, worker.QueueOptions{Workers: 1, QueueSize: 5})
+
+	for i := 0; i < 2; i++ {
+		if w, _ := serveWebhook(t, h, testConsumptionBody); w.Code != http.StatusAccepted {
+			t.Fatalf("status = %d, want 202", w.Code)
+		}
+	}
+
+	waitFor(t, func() bool { return p.callCount() == 1 })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := h.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown() error = %v, want context.DeadlineExceeded", err)
+	}
+
+	// El job en curso se canceló y el encolado no se procesó: ninguno es un fallo del evento
+	if depth := h.QueueDepth()["consumption"]; depth != 0 {
+		t.Errorf("QueueDepth() = %d after Shutdown, want 0", depth)
+	}
+	if calls := p.callCount(); calls != 1 {
+		t.Errorf("processor calls = %d, want only the running job", calls)
+	}
+	if entries, _ := deadLetters.List(context.Background(), deadletter.Filter{}); len(entries) != 0 {
+		t.Errorf("dead letters = %+v, want none for jobs cancelled by the shutdown", entries)
+	}
+}
+
+// waitFor espera hasta que cond se cumpla
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"webhook_receiver/internal/deadletter"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeadLetterHandler expone la API de administración del dead-letter queue
+type DeadLetterHandler struct {
+	store   deadletter.Store
+	webhook *WebhookHandler
+}
+
+// NewDeadLetterHandler crea una nueva instancia del handler. webhook se usa para reenviar
+// las entradas a los procesadores.
+func NewDeadLetterHandler(store deadletter.Store, webhook *WebhookHandler) *DeadLetterHandler {
+	return &DeadLetterHandler{
+		store:   store,
+		webhook: webhook,
+	}
+}
+
+// List lista las entradas del dead-letter queue
+// @Summary Lista dead letters
+// @Tags admin
+// @Produce json
+// @Param data_type query string false "Filtrar por data_type"
+// @Param webhook_id query int false "Filtrar por webhook_id"
+// @Param contract_id query int false "Filtrar por contract_id"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/dead-letters [get]
+func (h *DeadLetterHandler) List(c *gin.Context) {
+	filter, ok := h.filter(c)
+	if !ok {
+		return
+	}
+
+	entries, err := h.store.List(c.Request.Context(), filter)
+	if err != nil {
+		h.respondStoreError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"dead_letters": entries,
+		"count":        len(entries),
+		"timestamp":    time.Now(),
+	})
+}
+
+// Get retorna una entrada del dead-letter queue con su payload
+// @Summary Inspecciona un dead letter
+// @Tags admin
+// @Produce json
+// @Param id path string true "ID del dead letter"
+// @Success 200 {object} deadletter.Entry
+// @Failure 404 {object} map[string]interface{}
+// @Router /admin/dead-letters/{id} [get]
+func (h *DeadLetterHandler) Get(c *gin.Context) {
+	entry, err := h.store.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.respondStoreError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
+
+// Redrive reenvía una entrada del dead-letter queue a los procesadores
+// @Summary Reenvía un dead letter
+// @Tags admin
+// @Produce json
+// @Param id path string true "ID del dead letter"
+// @Success 200 {object} RedriveResult
+// @Failure 404 {object} map[string]interface{}
+// @Failure 502 {object} RedriveResult
+// @Router /admin/dead-letters/{id}/redrive [post]
+func (h *DeadLetterHandler) Redrive(c *gin.Context) {
+	entry, err := h.store.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.respondStoreError(c, err)
+		return
+	}
+
+	result := h.webhook.Redrive(c.Request.Context(), entry)
+	status := http.StatusOK
+	if result.Error != "" {
+		status = http.StatusBadGateway
+	}
+	c.JSON(status, result)
+}
+
+// RedriveAll reenvía todas las entradas que cumplen los filtros
+// @Summary Reenvía dead letters filtrados
+// @Tags admin
+// @Produce json
+// @Param data_type query string false "Filtrar por data_type"
+// @Param webhook_id query int false "Filtrar por webhook_id"
+// @Param contract_id query int false "Filtrar por contract_id"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/dead-letters/redrive [post]
+func (h *DeadLetterHandler) RedriveAll(c *gin.Context) {
+	filter, ok := h.filter(c)
+	if !ok {
+		return
+	}
+
+	entries, err := h.store.List(c.Request.Context(), filter)
+	if err != nil {
+		h.respondStoreError(c, err)
+		return
+	}
+
+	results := make([]RedriveResult, 0, len(entries))
+	failed := 0
+	for _, entry := range entries {
+		result := h.webhook.Redrive(c.Request.Context(), entry)
+		if result.Error != "" {
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results":   results,
+		"count":     len(results),
+		"failed":    failed,
+		"timestamp": time.Now(),
+	})
+}
+
+// Delete elimina una entrada del dead-letter queue
+// @Summary Elimina un dead letter
+// @Tags admin
+// @Produce json
+// @Param id path string true "ID del dead letter"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /admin/dead-letters/{id} [delete]
+func (h *DeadLetterHandler) Delete(c *gin.Context) {
+	if err := h.store.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		h.respondStoreError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deleted":   1,
+		"timestamp": time.Now(),
+	})
+}
+
+// Purge elimina todas las entradas que cumplen los filtros
+// @Summary Purga dead letters filtrados
+// @Tags admin
+// @Produce json
+// @Param data_type query string false "Filtrar por data_type"
+// @Param webhook_id query int false "Filtrar por webhook_id"
+// @Param contract_id query int false "Filtrar por contract_id"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/dead-letters [delete]
+func (h *DeadLetterHandler) Purge(c *gin.Context) {
+	filter, ok := h.filter(c)
+	if !ok {
+		return
+	}
+
+	purged, err := h.store.Purge(c.Request.Context(), filter)
+	if err != nil {
+		h.respondStoreError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deleted":   purged,
+		"timestamp": time.Now(),
+	})
+}
+
+// filter lee los filtros data_type, webhook_id y contract_id del query string
+func (h *DeadLetterHandler) filter(c *gin.Context) (deadletter.Filter, bool) {
+	filter := deadletter.Filter{DataType: c.Query("data_type")}
+
+	for param, target := range map[string]*int{
+		"webhook_id":  &filter.WebhookID,
+		"contract_id": &filter.ContractID,
+	} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "BAD_REQUEST",
+				"message": "Invalid " + param + ": " + value,
+			})
+			return filter, false
+		}
+		*target = parsed
+	}
+	return filter, true
+}
+
+// respondStoreError traduce los errores del store a respuestas HTTP
+func (h *DeadLetterHandler) respondStoreError(c *gin.Context, err error) {
+	if errors.Is(err, deadletter.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "NOT_FOUND",
+			"message": "Dead letter not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error":   "INTERNAL_ERROR",
+		"message": "Dead letter store failed: " + err.Error(),
+	})
+}
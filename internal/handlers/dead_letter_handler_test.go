@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"webhook_receiver/internal/deadletter"
+	"webhook_receiver/internal/processor"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newDeadLetterEngine arma las rutas de administración como el router, sobre un store con
+// tres entradas: dos de consumo (contratos 1001 y 2002) y una de facturas
+func newDeadLetterEngine(t *testing.T, processErr error) (*gin.Engine, deadletter.Store) {
+	t.Helper()
+	store := deadletter.NewMemoryStore()
+	for _, entry := range []deadletter.Entry{
+		{ID: "dl-1", DataType: "consumption", WebhookID: 12345, ContractID: 1001, Payload: consumptionBody(1001), Attempts: 5},
+		{ID: "dl-2", DataType: "consumption", WebhookID: 12345, ContractID: 2002, Payload: consumptionBody(2002), Attempts: 5},
+		{ID: "dl-3", DataType: "bills", WebhookID: 67890, ContractID: 2002, Payload: []byte(`{"data_type":"bills"}`), Attempts: 1},
+	} {
+		if err := store.Put(context.Background(), entry); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+	}
+
+	webhook := NewWebhookHandler(processor.NewDefaultRegistry(fakeProcessor{err: processErr}), WithDeadLetters(store))
+	h := NewDeadLetterHandler(store, webhook)
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/admin/dead-letters", h.List)
+	engine.DELETE("/admin/dead-letters", h.Purge)
+	engine.POST("/admin/dead-letters/redrive", h.RedriveAll)
+	engine.GET("/admin/dead-letters/:id", h.Get)
+	engine.DELETE("/admin/dead-letters/:id", h.Delete)
+	engine.POST("/admin/dead-letters/:id/redrive", h.Redrive)
+	return engine, store
+}
+
+// serveAdmin envía la petición y decodifica el body JSON en un mapa
+func serveAdmin(t *testing.T, engine *gin.Engine, method, target string) (int, map[string]any) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%s %s: response is not JSON: %v (%s)", method, target, err, w.Body.String())
+	}
+	return w.Code, body
+}
+
+// remaining retorna los IDs que siguen en el store
+func remaining(t *testing.T, store deadletter.Store) string {
+	t.Helper()
+	entries, err := store.List(context.Background(), deadletter.Filter{})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestDeadLetterList(t *testing.T) {
+	engine, _ := newDeadLetterEngine(t, nil)
+
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantCount  float64
+	}{
+		{query: "", wantStatus: http.StatusOK, wantCount: 3},
+		{query: "?data_type=consumption", wantStatus: http.StatusOK, wantCount: 2},
+		{query: "?webhook_id=67890", wantStatus: http.StatusOK, wantCount: 1},
+		{query: "?contract_id=2002", wantStatus: http.StatusOK, wantCount: 2},
+		{query: "?data_type=consumption&contract_id=2002", wantStatus: http.StatusOK, wantCount: 1},
+		{query: "?webhook_id=abc", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			status, body := serveAdmin(t, engine, http.MethodGet, "/admin/dead-letters"+tt.query)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%v)", status, tt.wantStatus, body)
+			}
+			if status == http.StatusOK && body["count"] != tt.wantCount {
+				t.Errorf("count = %v, want %v", body["count"], tt.wantCount)
+			}
+		})
+	}
+
+	if status, body := serveAdmin(t, engine, http.MethodGet, "/admin/dead-letters/dl-2"); status != http.StatusOK || body["contract_id"] != float64(2002) {
+		t.Errorf("Get(dl-2) = %d %v", status, body)
+	}
+	if status, body := serveAdmin(t, engine, http.MethodGet, "/admin/dead-letters/dl-missing"); status != http.StatusNotFound || body["error"] != "NOT_FOUND" {
+		t.Errorf("Get(dl-missing) = %d %v, want 404 NOT_FOUND", status, body)
+	}
+}
+
+func TestDeadLetterRedrive(t *testing.T) {
+	tests := []struct {
+		name          string
+		processErr    error
+		target        string
+		wantStatus    int
+		wantRemaining string
+		check         func(t *testing.T, body map[string]any, store deadletter.Store)
+	}{
+		{
+			name:          "successful redrive deletes the entry",
+			target:        "/admin/dead-letters/dl-1/redrive",
+			wantStatus:    http.StatusOK,
+			wantRemaining: "dl-2,dl-3",
+			check: func(t *testing.T, body map[string]any, store deadletter.Store) {
+				if body["processed"] != true {
+					t.Errorf("processed = %v, want true", body["processed"])
+				}
+			},
+		},
+		{
+			name:          "failed redrive keeps the entry with the new attempts",
+			processErr:    errors.New("database is down"),
+			target:        "/admin/dead-letters/dl-1/redrive",
+			wantStatus:    http.StatusBadGateway,
+			wantRemaining: "dl-1,dl-2,dl-3",
+			check: func(t *testing.T, body map[string]any, store deadletter.Store) {
+				entry, err := store.Get(context.Background(), "dl-1")
+				if err != nil || entry.Attempts != 6 || !strings.Contains(entry.LastError, "database is down") {
+					t.Errorf("dl-1 = %+v, %v, want 6 attempts and the new error", entry, err)
+				}
+			},
+		},
+		{
+			name:          "missing entry",
+			target:        "/admin/dead-letters/dl-missing/redrive",
+			wantStatus:    http.StatusNotFound,
+			wantRemaining: "dl-1,dl-2,dl-3",
+		},
+		{
+			name:          "redrive all with a filter",
+			target:        "/admin/dead-letters/redrive?data_type=consumption",
+			wantStatus:    http.StatusOK,
+			wantRemaining: "dl-3",
+			check: func(t *testing.T, body map[string]any, store deadletter.Store) {
+				if body["count"] != float64(2) || body["failed"] != float64(0) {
+					t.Errorf("count = %v, failed = %v, want 2 redriven without failures", body["count"], body["failed"])
+				}
+			},
+		},
+		{
+			name:          "redrive all reports undecodable entries",
+			target:        "/admin/dead-letters/redrive?contract_id=2002",
+			wantStatus:    http.StatusOK,
+			wantRemaining: "dl-1,dl-3",
+			check: func(t *testing.T, body map[string]any, store deadletter.Store) {
+				if body["count"] != float64(2) || body["failed"] != float64(1) {
+					t.Errorf("count = %v, failed = %v, want 2 with the bills entry failed", body["count"], body["failed"])
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, store := newDeadLetterEngine(t, tt.processErr)
+
+			status, body := serveAdmin(t, engine, http.MethodPost, tt.target)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%v)", status, tt.wantStatus, body)
+			}
+			if got := remaining(t, store); got != tt.wantRemaining {
+				t.Errorf("remaining = %s, want %s", got, tt.wantRemaining)
+			}
+			if tt.check != nil {
+				tt.check(t, body, store)
+			}
+		})
+	}
+}
+
+func TestDeadLetterDelete(t *testing.T) {
+	tests := []struct {
+		name          string
+		target        string
+		wantStatus    int
+		wantDeleted   float64
+		wantRemaining string
+	}{
+		{name: "delete one", target: "/admin/dead-letters/dl-2", wantStatus: http.StatusOK, wantDeleted: 1, wantRemaining: "dl-1,dl-3"},
+		{name: "delete missing", target: "/admin/dead-letters/dl-missing", wantStatus: http.StatusNotFound, wantRemaining: "dl-1,dl-2,dl-3"},
+		{name: "purge with a filter", target: "/admin/dead-letters?contract_id=2002", wantStatus: http.StatusOK, wantDeleted: 2, wantRemaining: "dl-1"},
+		{name: "purge all", target: "/admin/dead-letters", wantStatus: http.StatusOK, wantDeleted: 3, wantRemaining: ""},
+		{name: "purge with an invalid filter", target: "/admin/dead-letters?contract_id=x", wantStatus: http.StatusBadRequest, wantRemaining: "dl-1,dl-2,dl-3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, store := newDeadLetterEngine(t, nil)
+
+			status, body := serveAdmin(t, engine, http.MethodDelete, tt.target)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%v)", status, tt.wantStatus, body)
+			}
+			if status == http.StatusOK && body["deleted"] != tt.wantDeleted {
+				t.Errorf("deleted = %v, want %v", body["deleted"], tt.wantDeleted)
+			}
+			if got := remaining(t, store); got != tt.wantRemaining {
+				t.Errorf("remaining = %s, want %s", got, tt.wantRemaining)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"webhook_receiver/internal/dto"
+	"webhook_receiver/internal/processor"
+)
+
+// retryAfterSeconds es el valor sugerido en Retry-After para errores transitorios
+const retryAfterSeconds = "30"
+
+// classifyError traduce un error de decodificación o procesamiento a status HTTP y código de error:
+//   - 4xx para errores definitivos del payload (bia-consumptions no debe reintentar)
+//   - 503 para errores marcados con processor.Retryable
+//   - 500 para cualquier otro error del procesador (se puede reintentar)
+func classifyError(err error) (int, string) {
+	switch {
+	case errors.Is(err, processor.ErrMalformedJSON):
+		return http.StatusBadRequest, dto.ErrorCodeMalformedJSON
+	case errors.Is(err, processor.ErrUnknownDataType):
+		return http.StatusBadRequest, dto.ErrorCodeUnknownDataType
+	case errors.Is(err, processor.ErrInvalidPayload):
+		return http.StatusUnprocessableEntity, dto.ErrorCodeInvalidPayload
+	case processor.IsRetryable(err):
+		return http.StatusServiceUnavailable, dto.ErrorCodeTemporarilyFailed
+	case processor.IsPermanent(err):
+		return http.StatusUnprocessableEntity, dto.ErrorCodeRejected
+	default:
+		return http.StatusInternalServerError, dto.ErrorCodeProcessingFailed
+	}
+}
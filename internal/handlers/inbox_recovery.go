@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"webhook_receiver/internal/inbox"
+	"webhook_receiver/internal/worker"
+)
+
+// recoverRetryInterval es la espera antes de volver a encolar un registro recuperado cuando
+// la cola de su data_type está llena
+const recoverRetryInterval = 100 * time.Millisecond
+
+// PendingInbox retorna los webhooks del inbox que quedaron pendientes o fallaron con un error
+// reintentable (por ejemplo por una caída antes de terminar). Se llama antes de empezar a
+// recibir peticiones, para que la recuperación no tome registros de webhooks que están en curso.
+func (h *WebhookHandler) PendingInbox() []inbox.Record {
+	if h.inbox == nil {
+		return nil
+	}
+	return h.inbox.Pending()
+}
+
+// RecoverInbox vuelve a procesar records, obtenidos con PendingInbox. En modo asíncrono
+// los encola en el pool de workers, esperando si la cola está llena; en modo síncrono los
+// procesa en orden. Retorna la cantidad de registros encolados o procesados correctamente.
+func (h *WebhookHandler) RecoverInbox(ctx context.Context, records []inbox.Record) int {
+	if len(records) > 0 {
+		slog.InfoContext(ctx, "Recovering pending webhooks from inbox", "count", len(records))
+	}
+
+	recovered := 0
+	for _, record := range records {
+		if ctx.Err() != nil {
+			break
+		}
+
+		if err := h.recover(ctx, record); err != nil {
+			slog.WarnContext(ctx, "Failed to recover inbox record", "record_id", record.ID, "error", err)
+			continue
+		}
+		recovered++
+	}
+	return recovered
+}
+
+// recover procesa o encola un registro recuperado. Nadie espera la respuesta: un fallo
+// reintentable deja el registro pendiente.
+func (h *WebhookHandler) recover(ctx context.Context, record inbox.Record) error {
+	if h.pool == nil {
+		_, err := h.handle(ctx, record.ID, record.ReceivedAt, record.Body, record.Headers, false)
+		return err
+	}
+
+	event, err := h.decode(ctx, record.ID, record.ReceivedAt, record.Body, record.Headers)
+	if err != nil {
+		return err
+	}
+	job := worker.Job{RecordID: record.ID, Event: event}
+	for {
+		err := h.pool.Enqueue(job)
+		if !errors.Is(err, worker.ErrQueueFull) {
+			return err
+		}
+		select {
+		case <-time.After(recoverRetryInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"webhook_receiver/internal/dto"
+	"webhook_receiver/internal/inbox"
+	"webhook_receiver/internal/processor"
+	"webhook_receiver/internal/worker"
+)
+
+// countingProcessor cuenta los webhooks de consumo procesados por contract_id
+type countingProcessor struct {
+	mu        sync.Mutex
+	contracts map[int]int
+}
+
+func (p *countingProcessor) OnConsumption(ctx context.Context, payload dto.WebhookPayload) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.contracts == nil {
+		p.contracts = make(map[int]int)
+	}
+	p.contracts[payload.Data.ContractID]++
+	return nil
+}
+
+func (p *countingProcessor) OnBill(ctx context.Context, payload dto.BillWebhookPayload) error {
+	return nil
+}
+
+func (p *countingProcessor) processed(contractID int) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.contracts[contractID]
+}
+
+// consumptionBody retorna testConsumptionBody con otro contract_id
+func consumptionBody(contractID int) []byte {
+	return []byte(strings.Replace(testConsumptionBody, `"contract_id": 1001`, `"contract_id": `+strconv.Itoa(contractID), 1))
+}
+
+func TestRecoverInbox(t *testing.T) {
+	tests := []struct {
+		name  string
+		async bool
+	}{
+		{name: "sync processes the snapshot"},
+		{name: "async enqueues the snapshot into a full pool", async: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ib, err := inbox.Open(t.TempDir(), inbox.Options{})
+			if err != nil {
+				t.Fatalf("inbox.Open() error = %v", err)
+			}
+			defer ib.Close()
+
+			p := &countingProcessor{}
+			opts := []HandlerOption{WithInbox(ib)}
+			if tt.async {
+				// Una cola de un solo lugar obliga a esperar para encolar los recuperados
+				opts = append(opts, WithAsync(worker.Options{Default: worker.QueueOptions{Workers: 1, QueueSize: 1}}))
+			}
+			h := NewWebhookHandler(processor.NewDefaultRegistry(p), opts...)
+
+			for contractID := 1; contractID <= 3; contractID++ {
+				if _, err := ib.Append(dto.WebhookHeaders{}, consumptionBody(contractID)); err != nil {
+					t.Fatalf("Append() error = %v", err)
+				}
+			}
+			pending := h.PendingInbox()
+
+			// Un webhook que llega después de leer los pendientes es del handler, no de la recuperación
+			live, err := ib.Append(dto.WebhookHeaders{}, consumptionBody(99))
+			if err != nil {
+				t.Fatalf("Append() error = %v", err)
+			}
+
+			if got := h.RecoverInbox(context.Background(), pending); got != 3 {
+				t.Errorf("RecoverInbox() = %d, want 3", got)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := h.Shutdown(ctx); err != nil {
+				t.Fatalf("Shutdown() error = %v", err)
+			}
+
+			for contractID := 1; contractID <= 3; contractID++ {
+				if got := p.processed(contractID); got != 1 {
+					t.Errorf("contract %d processed %d times, want 1", contractID, got)
+				}
+			}
+			if got := p.processed(99); got != 0 {
+				t.Errorf("live webhook processed %d times by the recovery, want 0", got)
+			}
+			remaining := ib.Pending()
+			if len(remaining) != 1 || remaining[0].ID != live.ID {
+				t.Errorf("Pending() = %+v, want only the live record", remaining)
+			}
+		})
+	}
+}
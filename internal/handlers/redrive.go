@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+
+	"webhook_receiver/internal/deadletter"
+	"webhook_receiver/internal/worker"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RedriveResult es el resultado de reenviar una entrada del dead-letter queue a los procesadores
+type RedriveResult struct {
+	ID        string `json:"id"`
+	Queued    bool   `json:"queued"`    // true en modo asíncrono: el evento se encoló
+	Processed bool   `json:"processed"` // true en modo síncrono si se procesó correctamente
+	Message   string `json:"message,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Redrive vuelve a procesar una entrada del dead-letter queue. En modo asíncrono la encola;
+// en modo síncrono la procesa de inmediato. Si tiene éxito la entrada se elimina; si vuelve
+// a fallar se actualiza con el nuevo error y el total de intentos.
+func (h *WebhookHandler) Redrive(ctx context.Context, entry deadletter.Entry) RedriveResult {
+	result := RedriveResult{ID: entry.ID}
+
+	event, err := h.registry.Decode(entry.Payload, entry.Headers)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if !entry.ReceivedAt.IsZero() {
+		event.ReceivedAt = entry.ReceivedAt
+	}
+	job := worker.Job{DeadLetterID: entry.ID, Event: event, SpanContext: trace.SpanContextFromContext(ctx)}
+
+	if h.pool != nil {
+		if err := h.pool.Enqueue(job); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		result.Queued = true
+		return result
+	}
+
+	message, err := h.process(ctx, event)
+	h.settle(ctx, job, 1, err, true)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Processed = true
+	result.Message = message
+	return result
+}
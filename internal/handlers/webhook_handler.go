@@ -1,22 +1,90 @@
 package handlers
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
 
+	"webhook_receiver/internal/deadletter"
 	"webhook_receiver/internal/dto"
+	"webhook_receiver/internal/inbox"
+	"webhook_receiver/internal/logging"
+	"webhook_receiver/internal/metrics"
+	"webhook_receiver/internal/processor"
+	"webhook_receiver/internal/tracing"
+	"webhook_receiver/internal/worker"
 
 	"github.com/gin-gonic/gin"
 )
 
 // WebhookHandler maneja las peticiones de webhooks
-type WebhookHandler struct{}
+type WebhookHandler struct {
+	registry    *processor.Registry
+	inbox       *inbox.Inbox
+	pool        *worker.Pool
+	retryPolicy worker.RetryPolicy
+	deadLetters deadletter.Store
+	metrics     *metrics.Metrics
+}
+
+// HandlerOption configura opciones adicionales del handler
+type HandlerOption func(*WebhookHandler)
+
+// WithInbox persiste cada webhook verificado en ib antes de procesarlo y responder
+func WithInbox(ib *inbox.Inbox) HandlerOption {
+	return func(h *WebhookHandler) {
+		h.inbox = ib
+	}
+}
+
+// WithAsync activa el modo asíncrono: el webhook se valida, se encola y se responde 202
+// de inmediato; un pool de workers ejecuta los procesadores en segundo plano
+func WithAsync(opts worker.Options) HandlerOption {
+	return func(h *WebhookHandler) {
+		h.pool = worker.NewPool(opts, h.runJob)
+	}
+}
+
+// WithRetryPolicy define los reintentos del modo asíncrono antes de enviar un evento al dead-letter queue
+func WithRetryPolicy(policy worker.RetryPolicy) HandlerOption {
+	return func(h *WebhookHandler) {
+		h.retryPolicy = policy.WithDefaults()
+	}
+}
 
-// NewWebhookHandler crea una nueva instancia del handler
-func NewWebhookHandler() *WebhookHandler {
-	return &WebhookHandler{}
+// WithDeadLetters guarda en store los eventos rechazados o que agotaron sus reintentos. En modo
+// síncrono el emisor recibe el error y es el responsable de reintentar, así que el router solo
+// lo configura junto con WithAsync.
+func WithDeadLetters(store deadletter.Store) HandlerOption {
+	return func(h *WebhookHandler) {
+		h.deadLetters = store
+	}
+}
+
+// WithMetrics registra en m el resultado, el tamaño y la duración del procesamiento de cada webhook
+func WithMetrics(m *metrics.Metrics) HandlerOption {
+	return func(h *WebhookHandler) {
+		h.metrics = m
+	}
+}
+
+// NewWebhookHandler crea una nueva instancia del handler.
+// Si registry es nil se usa el registry por defecto sin lógica de negocio.
+func NewWebhookHandler(registry *processor.Registry, opts ...HandlerOption) *WebhookHandler {
+	if registry == nil {
+		registry = processor.NewDefaultRegistry(processor.NoopProcessor{})
+	}
+	h := &WebhookHandler{
+		registry:    registry,
+		retryPolicy: worker.RetryPolicy{}.WithDefaults(),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 // ReceiveWebhook maneja la recepción de webhooks (consumo y facturas)
@@ -31,9 +99,12 @@ func NewWebhookHandler() *WebhookHandler {
 // @Param X-Idempotency-Key header string false "Clave de idempotencia"
 // @Param payload body dto.WebhookPayload true "Payload del webhook"
 // @Success 200 {object} dto.WebhookResponse
-// @Failure 400 {object} map[string]interface{}
+// @Success 202 {object} dto.WebhookResponse
+// @Failure 400 {object} dto.WebhookResponse
 // @Failure 401 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 422 {object} dto.WebhookResponse
+// @Failure 500 {object} dto.WebhookResponse
+// @Failure 503 {object} dto.WebhookResponse
 // @Router /webhook [post]
 func (h *WebhookHandler) ReceiveWebhook(c *gin.Context) {
 	// Obtener headers para logging
@@ -44,121 +115,222 @@ func (h *WebhookHandler) ReceiveWebhook(c *gin.Context) {
 		IDKey:     c.GetHeader("X-Idempotency-Key"),
 	}
 
-	// Primero, detectar el tipo de webhook leyendo solo el campo data_type
-	var basePayload struct {
-		DataType string `json:"data_type"`
-	}
-
 	// Leer el body completo
 	bodyBytes, err := c.GetRawData()
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success":   false,
-			"message":   "Failed to read request body: " + err.Error(),
-			"timestamp": time.Now(),
-		})
+		h.respondError(c, http.StatusBadRequest, dto.ErrorCodeUnreadableBody, "Failed to read request body: "+err.Error())
 		return
 	}
 
-	// Parsear para obtener el data_type
-	if err := json.Unmarshal(bodyBytes, &basePayload); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success":   false,
-			"message":   "Invalid JSON payload: " + err.Error(),
-			"timestamp": time.Now(),
-		})
-		return
+	// Persistir el webhook verificado antes de procesarlo y confirmarlo
+	recordID := ""
+	receivedAt := time.Now()
+	if h.inbox != nil {
+		record, err := h.inbox.Append(headers, bodyBytes)
+		if err != nil {
+			c.Header("Retry-After", retryAfterSeconds)
+			h.respondError(c, http.StatusServiceUnavailable, dto.ErrorCodeInboxUnavailable, "Failed to persist webhook: "+err.Error())
+			return
+		}
+		recordID = record.ID
+		receivedAt = record.ReceivedAt
 	}
 
-	// Procesar según el tipo de webhook
-	var processed bool
-	var message string
+	// Modo asíncrono: validar, encolar y responder 202
+	if h.pool != nil {
+		h.enqueue(c, recordID, receivedAt, bodyBytes, headers)
+		return
+	}
 
-	switch basePayload.DataType {
-	case "consumption":
-		processed, message = h.processConsumptionWebhookType(bodyBytes, headers)
-	case "bills":
-		processed, message = h.processBillsWebhookType(bodyBytes, headers)
-	default:
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success":   false,
-			"message":   "Unknown data_type: " + basePayload.DataType,
-			"timestamp": time.Now(),
-		})
+	// Decodificar y procesar según el tipo de webhook
+	message, err := h.handle(c.Request.Context(), recordID, receivedAt, bodyBytes, headers, true)
+	if err != nil {
+		h.respondProcessingError(c, err)
 		return
 	}
 
 	// Log de la recepción del webhook
 	c.Header("X-Webhook-Received", "true")
 
-	response := dto.WebhookResponse{
+	c.JSON(http.StatusOK, dto.WebhookResponse{
 		Success:   true,
 		Message:   message,
-		Processed: processed,
+		Processed: true,
 		Timestamp: time.Now(),
+	})
+}
+
+// handle detecta el data_type, decodifica y procesa el body. Si el webhook está en el
+// inbox (recordID no vacío) registra el resultado del procesamiento. senderRetries indica
+// que el error se responde al emisor, que reintenta la entrega ante un error no definitivo.
+func (h *WebhookHandler) handle(ctx context.Context, recordID string, receivedAt time.Time, body []byte, headers dto.WebhookHeaders, senderRetries bool) (string, error) {
+	event, err := h.decode(ctx, recordID, receivedAt, body, headers)
+	if err != nil {
+		return "", err
 	}
 
-	c.JSON(http.StatusOK, response)
+	job := worker.Job{RecordID: recordID, Event: event}
+	message, err := h.process(ctx, event)
+	final := processor.IsPermanent(err)
+	if err != nil && !final && senderRetries {
+		// bia-consumptions reintentará la entrega, así que el registro no se reprocesa desde el inbox
+		h.metrics.ObserveOutcome(event.DataType, event.TriggerType, outcome(err))
+		h.markInboxReturned(recordID, err)
+		return message, err
+	}
+	h.settle(ctx, job, 1, err, final)
+	return message, err
 }
 
-// processConsumptionWebhookType procesa webhooks de tipo CONSUMO
-func (h *WebhookHandler) processConsumptionWebhookType(bodyBytes []byte, headers dto.WebhookHeaders) (bool, string) {
-	var payload dto.WebhookPayload
-
-	// Parsear el payload específico de consumo
-	if err := json.Unmarshal(bodyBytes, &payload); err != nil {
-		return false, "Failed to parse consumption payload: " + err.Error()
+// decode detecta el data_type y decodifica con el decoder registrado
+func (h *WebhookHandler) decode(ctx context.Context, recordID string, receivedAt time.Time, body []byte, headers dto.WebhookHeaders) (processor.Event, error) {
+	_, span := tracing.Start(ctx, "webhook.decode")
+	event, err := h.registry.Decode(body, headers)
+	span.SetAttributes(tracing.EventAttributes(event.DataType, event.TriggerType, event.WebhookID, event.ContractID)...)
+	tracing.End(span, err)
+	if err != nil {
+		slog.WarnContext(ctx, "Webhook payload rejected", "data_type", event.DataType, "error", err)
+		h.metrics.ObserveOutcome(event.DataType, "", metrics.OutcomeInvalid)
+		h.markInbox(recordID, err)
+		return event, err
 	}
+	event.ReceivedAt = receivedAt
+	h.metrics.ObserveReceived(event.DataType, event.WebhookID, len(body), receivedAt)
+	return event, nil
+}
+
+// process ejecuta el procesador registrado para el evento. Los logs de los procesadores
+// y hooks incluyen los campos del evento (webhook_id, contract_id, ...).
+func (h *WebhookHandler) process(ctx context.Context, event processor.Event) (string, error) {
+	ctx = logging.With(ctx, logging.EventAttrs(event)...)
 
-	// Validar campos requeridos
-	if payload.GroupBy == "" || payload.SendInterval == "" {
-		return false, "Missing required fields for consumption webhook"
+	start := time.Now()
+	message, err := h.registry.Process(ctx, event)
+	duration := time.Since(start)
+	h.metrics.ObserveProcessing(event.DataType, outcome(err), duration)
+	if err != nil {
+		err = fmt.Errorf("failed to process %s webhook: %w", event.DataType, err)
+		slog.WarnContext(ctx, "Webhook processing failed", "outcome", outcome(err), "duration", duration, "error", err)
+		return message, err
 	}
 
-	// Aquí implementarías la lógica específica para datos de consumo
-	// Por ejemplo:
-	// - Guardar datos de consumo en base de datos
-	// - Enviar notificaciones a usuarios
-	// - Procesar métricas de energía según el tipo de agrupación
+	slog.InfoContext(ctx, "Webhook processed", "duration", duration)
+	return message, nil
+}
 
-	// Log del procesamiento (descomentado para producción)
-	// log.Printf("Processing consumption webhook: ID=%d, Contract=%d, GroupBy=%s, Interval=%s",
-	//     payload.WebhookID, payload.Data.ContractID, payload.GroupBy, payload.SendInterval)
+// settle registra el resultado final de un intento de procesamiento. Si el error es final
+// (rechazo definitivo o reintentos agotados) el evento pasa al dead-letter queue.
+func (h *WebhookHandler) settle(ctx context.Context, job worker.Job, attempts int, cause error, final bool) {
+	h.metrics.ObserveOutcome(job.Event.DataType, job.Event.TriggerType, outcome(cause))
 
-	message := fmt.Sprintf("Consumption webhook processed successfully for contract %d (%s)",
-		payload.Data.ContractID, payload.Data.ContractName)
+	switch {
+	case cause == nil:
+		h.markInbox(job.RecordID, nil)
+		if job.DeadLetterID != "" && h.deadLetters != nil {
+			if err := h.deadLetters.Delete(ctx, job.DeadLetterID); err != nil && !errors.Is(err, deadletter.ErrNotFound) {
+				slog.ErrorContext(ctx, "Failed to delete dead letter", "dead_letter_id", job.DeadLetterID, "error", err)
+			}
+		}
+	case final && h.deadLetter(ctx, job, attempts, cause):
+		// El dead-letter queue es ahora el responsable del evento: no reprocesar desde el inbox
+		h.markInboxRejected(job.RecordID, cause)
+	default:
+		h.markInbox(job.RecordID, cause)
+	}
+}
 
-	return true, message
+// outcome retorna el resultado de procesamiento que corresponde a err, para las métricas
+func outcome(err error) string {
+	switch {
+	case err == nil:
+		return metrics.OutcomeProcessed
+	case processor.IsPermanent(err):
+		return metrics.OutcomeRejected
+	default:
+		return metrics.OutcomeFailed
+	}
 }
 
-// processBillsWebhookType procesa webhooks de tipo FACTURAS
-func (h *WebhookHandler) processBillsWebhookType(bodyBytes []byte, headers dto.WebhookHeaders) (bool, string) {
-	var payload dto.BillWebhookPayload
+// QueueDepth retorna la cantidad de eventos encolados por data_type (vacío en modo síncrono)
+func (h *WebhookHandler) QueueDepth() map[string]int {
+	if h.pool == nil {
+		return nil
+	}
+	return h.pool.Depth()
+}
+
+// markInbox registra en el inbox el resultado del procesamiento de recordID
+func (h *WebhookHandler) markInbox(recordID string, cause error) {
+	if h.inbox == nil || recordID == "" {
+		return
+	}
 
-	// Parsear el payload específico de facturas
-	if err := json.Unmarshal(bodyBytes, &payload); err != nil {
-		return false, "Failed to parse bills payload: " + err.Error()
+	var err error
+	switch {
+	case cause == nil:
+		err = h.inbox.MarkProcessed(recordID)
+	case processor.IsPermanent(cause):
+		err = h.inbox.MarkRejected(recordID, cause)
+	default:
+		err = h.inbox.MarkFailed(recordID, cause)
+	}
+	if err != nil {
+		// El registro queda pendiente y se vuelve a procesar al reiniciar
+		slog.Error("Failed to update inbox record", "record_id", recordID, "error", err)
 	}
+}
 
-	// Validar campos requeridos
-	if payload.TriggerType == "" {
-		return false, "Missing required fields for bills webhook"
+// markInboxRejected marca recordID como terminado sin éxito, para que no se reprocese al reiniciar
+func (h *WebhookHandler) markInboxRejected(recordID string, cause error) {
+	if h.inbox == nil || recordID == "" {
+		return
+	}
+	if err := h.inbox.MarkRejected(recordID, cause); err != nil {
+		slog.Error("Failed to update inbox record", "record_id", recordID, "error", err)
 	}
+}
 
-	// Aquí implementarías la lógica específica para eventos de facturas
-	// Por ejemplo:
-	// - Notificar a usuarios sobre nuevas facturas (trigger_type="available")
-	// - Procesar confirmación de pagos (trigger_type="paid")
-	// - Actualizar estados de facturas en tu sistema
+// markInboxReturned marca recordID como devuelto al emisor, que lo vuelve a entregar, para
+// que no se reprocese al reiniciar
+func (h *WebhookHandler) markInboxReturned(recordID string, cause error) {
+	if h.inbox == nil || recordID == "" {
+		return
+	}
+	if err := h.inbox.MarkReturned(recordID, cause); err != nil {
+		slog.Error("Failed to update inbox record", "record_id", recordID, "error", err)
+	}
+}
 
-	// Log del procesamiento (descomentado para producción)
-	// log.Printf("Processing bills webhook: ID=%d, TriggerType=%s, BillID=%d",
-	//     payload.WebhookID, payload.TriggerType, payload.Bill.BillID)
+// respondProcessingError responde con el status y código de error que corresponden a err
+func (h *WebhookHandler) respondProcessingError(c *gin.Context, err error) {
+	status, code := classifyError(err)
+	if status == http.StatusServiceUnavailable {
+		c.Header("Retry-After", retryAfterSeconds)
+	}
 
-	message := fmt.Sprintf("Bills webhook processed successfully: %s event for bill %d",
-		payload.TriggerType, payload.Bill.BillID)
+	response := dto.WebhookResponse{
+		Success:   false,
+		Message:   err.Error(),
+		ErrorCode: code,
+		Processed: false,
+		Timestamp: time.Now(),
+	}
+	var validationErr *dto.ValidationError
+	if errors.As(err, &validationErr) {
+		response.Errors = validationErr.Errors
+	}
+	c.JSON(status, response)
+}
 
-	return true, message
+// respondError escribe una respuesta de error con el mismo formato que las respuestas exitosas
+func (h *WebhookHandler) respondError(c *gin.Context, status int, code, message string) {
+	c.JSON(status, dto.WebhookResponse{
+		Success:   false,
+		Message:   message,
+		ErrorCode: code,
+		Processed: false,
+		Timestamp: time.Now(),
+	})
 }
 
 // HealthCheck endpoint de salud
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"webhook_receiver/internal/dto"
+	"webhook_receiver/internal/processor"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testConsumptionBody = `{
+	"webhook_id": 12345,
+	"data_type": "consumption",
+	"group_by": "hour",
+	"send_interval": "daily",
+	"period": {"start_date": "2024-01-15", "end_date": "2024-01-16"},
+	"data": {"contract_id": 1001, "consumption": [{"hour": 0, "active_energy": 150.5}]},
+	"timestamp": "2024-01-15T10:30:00Z"
+}`
+
+// fakeProcessor retorna err para cada evento procesado
+type fakeProcessor struct {
+	err error
+}
+
+func (p fakeProcessor) OnConsumption(ctx context.Context, payload dto.WebhookPayload) error {
+	return p.err
+}
+
+func (p fakeProcessor) OnBill(ctx context.Context, payload dto.BillWebhookPayload) error {
+	return p.err
+}
+
+// serveWebhook envía body a ReceiveWebhook y decodifica la respuesta
+func serveWebhook(t *testing.T, h *WebhookHandler, body string) (*httptest.ResponseRecorder, dto.WebhookResponse) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/webhook", h.ReceiveWebhook)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body)))
+
+	var response dto.WebhookResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("response is not a WebhookResponse: %v (%s)", err, w.Body.String())
+	}
+	return w, response
+}
+
+func TestReceiveWebhookStatus(t *testing.T) {
+	errDatabase := errors.New("database is down")
+
+	tests := []struct {
+		name           string
+		body           string
+		processErr     error
+		wantStatus     int
+		wantCode       string
+		wantRetryAfter bool
+		wantFieldErrs  bool
+		wantField      string // Campo que debe venir en errors, con su ruta y mensaje
+	}{
+		{name: "processed", body: testConsumptionBody, wantStatus: http.StatusOK},
+		{name: "malformed JSON", body: `{"data_type":`, wantStatus: http.StatusBadRequest, wantCode: dto.ErrorCodeMalformedJSON},
+		{name: "unknown data_type", body: `{"data_type":"weather"}`, wantStatus: http.StatusBadRequest, wantCode: dto.ErrorCodeUnknownDataType},
+		{
+			name:          "undecodable payload",
+			body:          strings.Replace(testConsumptionBody, `"group_by": "hour"`, `"group_by": "week"`, 1),
+			wantStatus:    http.StatusUnprocessableEntity,
+			wantCode:      dto.ErrorCodeInvalidPayload,
+			wantFieldErrs: true,
+		},
+		{
+			name:          "semantic validation error",
+			body:          strings.Replace(testConsumptionBody, `"end_date": "2024-01-16"`, `"end_date": "2024-01-14"`, 1),
+			wantStatus:    http.StatusUnprocessableEntity,
+			wantCode:      dto.ErrorCodeInvalidPayload,
+			wantFieldErrs: true,
+			wantField:     "period.start_date",
+		},
+		{
+			name:       "permanent processor error",
+			body:       testConsumptionBody,
+			processErr: processor.Permanent(errors.New("contract is closed")),
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   dto.ErrorCodeRejected,
+		},
+		{
+			name:           "retryable processor error",
+			body:           testConsumptionBody,
+			processErr:     processor.Retryable(errDatabase),
+			wantStatus:     http.StatusServiceUnavailable,
+			wantCode:       dto.ErrorCodeTemporarilyFailed,
+			wantRetryAfter: true,
+		},
+		{
+			name:       "unexpected processor error",
+			body:       testConsumptionBody,
+			processErr: errDatabase,
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   dto.ErrorCodeProcessingFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewWebhookHandler(processor.NewDefaultRegistry(fakeProcessor{err: tt.processErr}))
+
+			w, response := serveWebhook(t, h, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if response.ErrorCode != tt.wantCode {
+				t.Errorf("error_code = %q, want %q", response.ErrorCode, tt.wantCode)
+			}
+			if response.Success != (tt.wantStatus == http.StatusOK) || response.Processed != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("success = %v, processed = %v for status %d", response.Success, response.Processed, w.Code)
+			}
+			if got := w.Header().Get("Retry-After") != ""; got != tt.wantRetryAfter {
+				t.Errorf("Retry-After present = %v, want %v", got, tt.wantRetryAfter)
+			}
+			if got := len(response.Errors) > 0; got != tt.wantFieldErrs {
+				t.Errorf("errors = %+v, want field errors = %v", response.Errors, tt.wantFieldErrs)
+			}
+			if tt.wantField != "" && !strings.Contains(w.Body.String(), `{"field":"`+tt.wantField+`","message":"`) {
+				t.Errorf("body = %s, want a field error for %s", w.Body.String(), tt.wantField)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileStore es un Store persistente que guarda un archivo JSON por clave en un directorio.
+// Sobrevive reinicios del proceso; está pensado para una sola instancia del receptor.
+type FileStore struct {
+	mu        sync.Mutex
+	dir       string
+	opts      Options
+	now       func() time.Time
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewFileStore crea (si no existe) el directorio dir y elimina los registros expirados, al
+// crearlo y luego cada opts.SweepInterval hasta llamar a Close
+func NewFileStore(dir string, opts Options) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create idempotency dir: %w", err)
+	}
+
+	s := &FileStore{
+		dir:  dir,
+		opts: opts.withDefaults(),
+		now:  time.Now,
+		stop: make(chan struct{}),
+	}
+	if _, err := s.Sweep(context.Background()); err != nil {
+		return nil, err
+	}
+	go s.sweepLoop()
+	return s, nil
+}
+
+// Close detiene la limpieza periódica de registros expirados
+func (s *FileStore) Close() error {
+	s.closeOnce.Do(func() { close(s.stop) })
+	return nil
+}
+
+func (s *FileStore) sweepLoop() {
+	ticker := time.NewTicker(s.opts.SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := s.Sweep(context.Background()); err != nil {
+				slog.Error("Failed to sweep idempotency records", "error", err)
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Begin implementa Store
+func (s *FileStore) Begin(ctx context.Context, key, bodyHash string) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	record, err := s.read(key)
+	switch {
+	case err == nil && !record.Expired(now):
+		return record, false, nil
+	case err != nil && !errors.Is(err, ErrNotFound):
+		return Record{}, false, err
+	}
+
+	record = Record{
+		Key:       key,
+		BodyHash:  bodyHash,
+		State:     StateInProgress,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.opts.LockTimeout),
+	}
+	if err := s.write(record); err != nil {
+		return Record{}, false, err
+	}
+	return record, true, nil
+}
+
+// Complete implementa Store
+func (s *FileStore) Complete(ctx context.Context, key string, response Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, err := s.read(key)
+	if err != nil {
+		return err
+	}
+
+	record.State = StateCompleted
+	record.Response = &response
+	record.ExpiresAt = s.now().Add(s.opts.TTL)
+	return s.write(record)
+}
+
+// Release implementa Store
+func (s *FileStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := os.Remove(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Sweep elimina los registros expirados y retorna cuántos se eliminaron
+func (s *FileStore) Sweep(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list idempotency dir: %w", err)
+	}
+
+	now := s.now()
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		path := filepath.Join(s.dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		var record Record
+		// Los archivos corruptos también se eliminan
+		if err := json.Unmarshal(data, &record); err == nil && !record.Expired(now) {
+			continue
+		}
+		if err := os.Remove(path); err == nil {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (s *FileStore) read(key string) (Record, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Record{}, ErrNotFound
+	}
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to read idempotency record: %w", err)
+	}
+
+	var record Record
+	if err := json.Unmarshal(data, &record); err != nil {
+		// Un registro corrupto se trata como inexistente
+		return Record{}, ErrNotFound
+	}
+	return record, nil
+}
+
+// write escribe el registro de forma atómica (archivo temporal + rename)
+func (s *FileStore) write(record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode idempotency record: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write idempotency record: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write idempotency record: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write idempotency record: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(record.Key)); err != nil {
+		return fmt.Errorf("failed to write idempotency record: %w", err)
+	}
+	return nil
+}
+
+// path retorna el archivo de key; se usa un hash para que cualquier clave sea un nombre válido
+func (s *FileStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
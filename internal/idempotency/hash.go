@@ -0,0 +1,12 @@
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// HashBody retorna el hash SHA-256 (hex) de body usado para detectar claves reutilizadas
+func HashBody(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,111 @@
+package idempotency
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultMaxEntries es la capacidad por defecto de MemoryStore
+const DefaultMaxEntries = 10000
+
+// MemoryStore es un Store en memoria con expiración por TTL y desalojo LRU
+type MemoryStore struct {
+	mu         sync.Mutex
+	opts       Options
+	maxEntries int
+	order      *list.List // Frente = usado más recientemente
+	entries    map[string]*list.Element
+	now        func() time.Time
+}
+
+// NewMemoryStore crea un MemoryStore con capacidad para maxEntries claves
+func NewMemoryStore(maxEntries int, opts Options) *MemoryStore {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	return &MemoryStore{
+		opts:       opts.withDefaults(),
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+// Begin implementa Store
+func (s *MemoryStore) Begin(ctx context.Context, key, bodyHash string) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if elem, ok := s.entries[key]; ok {
+		record := elem.Value.(Record)
+		if !record.Expired(now) {
+			s.order.MoveToFront(elem)
+			return record, false, nil
+		}
+		s.remove(elem)
+	}
+
+	record := Record{
+		Key:       key,
+		BodyHash:  bodyHash,
+		State:     StateInProgress,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.opts.LockTimeout),
+	}
+	s.entries[key] = s.order.PushFront(record)
+
+	// Desalojar las claves menos usadas si se supera la capacidad
+	for s.order.Len() > s.maxEntries {
+		s.remove(s.order.Back())
+	}
+
+	return record, true, nil
+}
+
+// Complete implementa Store
+func (s *MemoryStore) Complete(ctx context.Context, key string, response Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[key]
+	if !ok {
+		return ErrNotFound
+	}
+
+	record := elem.Value.(Record)
+	record.State = StateCompleted
+	record.Response = &response
+	record.ExpiresAt = s.now().Add(s.opts.TTL)
+	elem.Value = record
+	s.order.MoveToFront(elem)
+	return nil
+}
+
+// Release implementa Store
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[key]
+	if !ok {
+		return ErrNotFound
+	}
+	s.remove(elem)
+	return nil
+}
+
+// Len retorna la cantidad de claves almacenadas (incluidas las expiradas aún no desalojadas)
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+func (s *MemoryStore) remove(elem *list.Element) {
+	s.order.Remove(elem)
+	delete(s.entries, elem.Value.(Record).Key)
+}
@@ -0,0 +1,80 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Estados posibles de una clave de idempotencia
+const (
+	StateInProgress = "in_progress"
+	StateCompleted  = "completed"
+)
+
+// Valores por defecto de Options
+const (
+	DefaultTTL           = 24 * time.Hour
+	DefaultLockTimeout   = 5 * time.Minute
+	DefaultSweepInterval = 10 * time.Minute
+)
+
+// ErrNotFound se retorna al completar o liberar una clave que no existe
+var ErrNotFound = errors.New("idempotency key not found")
+
+// Response es la respuesta HTTP registrada para una clave
+type Response struct {
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body"`
+}
+
+// Record representa el estado de una clave de idempotencia
+type Record struct {
+	Key       string    `json:"key"`
+	BodyHash  string    `json:"body_hash"`
+	State     string    `json:"state"`
+	Response  *Response `json:"response,omitempty"` // Solo presente en StateCompleted
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Expired indica si el registro ya no es vigente en now
+func (r Record) Expired(now time.Time) bool {
+	return !r.ExpiresAt.IsZero() && !now.Before(r.ExpiresAt)
+}
+
+// Store persiste las claves de idempotencia y sus respuestas
+type Store interface {
+	// Begin reserva key en estado in_progress. Si ya existe un registro vigente
+	// lo retorna sin modificarlo y con created=false.
+	Begin(ctx context.Context, key, bodyHash string) (record Record, created bool, err error)
+	// Complete registra la respuesta final de key
+	Complete(ctx context.Context, key string, response Response) error
+	// Release elimina la reserva de key para que una nueva entrega la procese
+	Release(ctx context.Context, key string) error
+}
+
+// Options configura la vigencia de los registros
+type Options struct {
+	// TTL es el tiempo que se conserva una respuesta completada
+	TTL time.Duration
+	// LockTimeout es el tiempo máximo que una clave puede quedar in_progress
+	// (por ejemplo si el proceso se cae a mitad del procesamiento)
+	LockTimeout time.Duration
+	// SweepInterval es cada cuánto FileStore elimina los registros expirados
+	SweepInterval time.Duration
+}
+
+func (o Options) withDefaults() Options {
+	if o.TTL <= 0 {
+		o.TTL = DefaultTTL
+	}
+	if o.LockTimeout <= 0 {
+		o.LockTimeout = DefaultLockTimeout
+	}
+	if o.SweepInterval <= 0 {
+		o.SweepInterval = DefaultSweepInterval
+	}
+	return o
+}
@@ -0,0 +1,222 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testOptions = Options{TTL: time.Hour, LockTimeout: time.Minute}
+
+// testStores retorna un MemoryStore y un FileStore cuyo reloj es *now
+func testStores(t *testing.T, now *time.Time) map[string]Store {
+	t.Helper()
+	clock := func() time.Time { return *now }
+
+	memory := NewMemoryStore(10, testOptions)
+	memory.now = clock
+
+	file, err := NewFileStore(t.TempDir(), testOptions)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	t.Cleanup(func() { file.Close() })
+	file.now = clock
+
+	return map[string]Store{"memory": memory, "file": file}
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	response := Response{StatusCode: 200, ContentType: "application/json", Body: []byte(`{"success":true}`)}
+
+	type step struct {
+		advance     time.Duration
+		action      string // begin, complete o release
+		bodyHash    string
+		wantCreated bool
+		wantState   string
+		wantErr     error
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "new key is reserved in progress",
+			steps: []step{
+				{action: "begin", bodyHash: "h1", wantCreated: true, wantState: StateInProgress},
+				{action: "begin", bodyHash: "h1", wantState: StateInProgress},
+			},
+		},
+		{
+			name: "completed key returns the response",
+			steps: []step{
+				{action: "begin", bodyHash: "h1", wantCreated: true},
+				{action: "complete"},
+				{advance: 30 * time.Minute, action: "begin", bodyHash: "h1", wantState: StateCompleted},
+			},
+		},
+		{
+			name: "completed key expires after the TTL",
+			steps: []step{
+				{action: "begin", bodyHash: "h1", wantCreated: true},
+				{action: "complete"},
+				{advance: time.Hour, action: "begin", bodyHash: "h2", wantCreated: true, wantState: StateInProgress},
+			},
+		},
+		{
+			name: "stale lock is taken over after the lock timeout",
+			steps: []step{
+				{action: "begin", bodyHash: "h1", wantCreated: true},
+				{advance: time.Minute, action: "begin", bodyHash: "h1", wantCreated: true},
+			},
+		},
+		{
+			name: "released key can be reserved again",
+			steps: []step{
+				{action: "begin", bodyHash: "h1", wantCreated: true},
+				{action: "release"},
+				{action: "begin", bodyHash: "h1", wantCreated: true},
+			},
+		},
+		{
+			name: "unknown key",
+			steps: []step{
+				{action: "complete", wantErr: ErrNotFound},
+				{action: "release", wantErr: ErrNotFound},
+			},
+		},
+	}
+	for _, tt := range tests {
+		for _, name := range []string{"memory", "file"} {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				now := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+				store := testStores(t, &now)[name]
+				for i, s := range tt.steps {
+					now = now.Add(s.advance)
+					var err error
+					switch s.action {
+					case "begin":
+						var (
+							record  Record
+							created bool
+						)
+						record, created, err = store.Begin(ctx, "webhook:key", s.bodyHash)
+						if err == nil && created != s.wantCreated {
+							t.Errorf("step %d: created = %v, want %v", i, created, s.wantCreated)
+						}
+						if err == nil && s.wantState != "" && record.State != s.wantState {
+							t.Errorf("step %d: state = %s, want %s", i, record.State, s.wantState)
+						}
+						if err == nil && record.State == StateCompleted && string(record.Response.Body) != string(response.Body) {
+							t.Errorf("step %d: response = %s, want %s", i, record.Response.Body, response.Body)
+						}
+					case "complete":
+						err = store.Complete(ctx, "webhook:key", response)
+					case "release":
+						err = store.Release(ctx, "webhook:key")
+					}
+					if !errors.Is(err, s.wantErr) {
+						t.Fatalf("step %d: %s error = %v, want %v", i, s.action, err, s.wantErr)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestMemoryStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(2, testOptions)
+
+	store.Begin(ctx, "a", "h")
+	store.Begin(ctx, "b", "h")
+	store.Begin(ctx, "a", "h") // a pasa a ser la más reciente
+	store.Begin(ctx, "c", "h")
+
+	if store.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", store.Len())
+	}
+	if _, created, _ := store.Begin(ctx, "a", "h"); created {
+		t.Error("the most recently used key was evicted")
+	}
+	if _, created, _ := store.Begin(ctx, "b", "h"); !created {
+		t.Error("the least recently used key was not evicted")
+	}
+}
+
+func TestFileStoreSweep(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+
+	store, err := NewFileStore(dir, testOptions)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	defer store.Close()
+	store.now = func() time.Time { return now }
+
+	store.Begin(ctx, "in-progress", "h")
+	store.Begin(ctx, "completed", "h")
+	store.Complete(ctx, "completed", Response{StatusCode: 200})
+	if err := os.WriteFile(filepath.Join(dir, "corrupt.json"), []byte("{"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		advance     time.Duration
+		wantRemoved int
+		wantFiles   int
+	}{
+		{name: "removes corrupt records", wantRemoved: 1, wantFiles: 2},
+		{name: "removes expired locks", advance: time.Minute, wantRemoved: 1, wantFiles: 1},
+		{name: "keeps completed records within the TTL", advance: 30 * time.Minute, wantRemoved: 0, wantFiles: 1},
+		{name: "removes expired responses", advance: time.Hour, wantRemoved: 1, wantFiles: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.advance)
+			removed, err := store.Sweep(ctx)
+			if err != nil {
+				t.Fatalf("Sweep() error = %v", err)
+			}
+			if removed != tt.wantRemoved {
+				t.Errorf("Sweep() removed %d, want %d", removed, tt.wantRemoved)
+			}
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != tt.wantFiles {
+				t.Errorf("%d files left, want %d", len(entries), tt.wantFiles)
+			}
+		})
+	}
+}
+
+func TestFileStoreSurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store, err := NewFileStore(dir, testOptions)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	store.Begin(ctx, "key", "h1")
+	store.Complete(ctx, "key", Response{StatusCode: 202, Body: []byte("ok")})
+	store.Close()
+
+	reopened, err := NewFileStore(dir, testOptions)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	defer reopened.Close()
+
+	record, created, err := reopened.Begin(ctx, "key", "h1")
+	if err != nil || created || record.Response == nil || record.Response.StatusCode != 202 {
+		t.Errorf("Begin() after restart = %+v, created %v, error %v; want the recorded response", record, created, err)
+	}
+}
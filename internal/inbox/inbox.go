@@ -0,0 +1,370 @@
+package inbox
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"webhook_receiver/internal/dto"
+)
+
+// DefaultSegmentSize es el tamaño por defecto a partir del cual se rota el segmento activo
+const DefaultSegmentSize = 64 << 20
+
+// ErrNotFound se retorna al actualizar un registro que no existe
+var ErrNotFound = errors.New("inbox record not found")
+
+// Tipos de línea del log
+const (
+	entryReceived = "received"
+	entryStatus   = "status"
+)
+
+// entry es una línea del log append-only. Un registro se crea con una línea
+// "received" y cada cambio de estado agrega una línea "status".
+type entry struct {
+	Type      string    `json:"type"`
+	Record    *Record   `json:"record,omitempty"`
+	ID        string    `json:"id,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Options configura el inbox
+type Options struct {
+	// SegmentSize es el tamaño máximo en bytes de cada segmento antes de rotar
+	SegmentSize int64
+}
+
+// Inbox es un log durable y append-only de webhooks verificados, en segmentos JSONL.
+// Cada escritura hace fsync antes de retornar, así un webhook confirmado con 2xx
+// sobrevive a una caída del proceso.
+type Inbox struct {
+	mu          sync.Mutex
+	dir         string
+	segmentSize int64
+	segment     *os.File
+	segmentSeq  int
+	segmentLen  int64
+	records     map[string]*Record
+	segmentOf   map[string]int // Segmento donde está la línea "received" de cada registro
+	now         func() time.Time
+}
+
+// Open abre (o crea) el inbox en dir y reconstruye el estado leyendo todos los segmentos
+func Open(dir string, opts Options) (*Inbox, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create inbox dir: %w", err)
+	}
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = DefaultSegmentSize
+	}
+
+	ib := &Inbox{
+		dir:         dir,
+		segmentSize: opts.SegmentSize,
+		records:     make(map[string]*Record),
+		segmentOf:   make(map[string]int),
+		now:         time.Now,
+	}
+
+	seqs, err := ib.segments()
+	if err != nil {
+		return nil, err
+	}
+	for _, seq := range seqs {
+		if err := ib.replay(seq); err != nil {
+			return nil, err
+		}
+	}
+
+	// Continuar escribiendo en un segmento nuevo
+	next := 1
+	if len(seqs) > 0 {
+		next = seqs[len(seqs)-1] + 1
+	}
+	if err := ib.openSegment(next); err != nil {
+		return nil, err
+	}
+	ib.compact()
+	return ib, nil
+}
+
+// Append guarda un webhook recibido en estado pending
+func (ib *Inbox) Append(headers dto.WebhookHeaders, body []byte) (Record, error) {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+
+	now := ib.now()
+	record := Record{
+		ID:         newID(now),
+		ReceivedAt: now,
+		Headers:    headers,
+		Body:       body,
+		Status:     StatusPending,
+		UpdatedAt:  now,
+	}
+
+	if err := ib.write(entry{Type: entryReceived, Record: &record, Timestamp: now}); err != nil {
+		return Record{}, err
+	}
+
+	stored := record
+	ib.records[record.ID] = &stored
+	ib.segmentOf[record.ID] = ib.segmentSeq
+	return record, nil
+}
+
+// MarkProcessed registra que el webhook se procesó correctamente
+func (ib *Inbox) MarkProcessed(id string) error {
+	return ib.setStatus(id, StatusProcessed, "")
+}
+
+// MarkFailed registra un fallo reintentable; el registro se vuelve a procesar al reiniciar
+func (ib *Inbox) MarkFailed(id string, cause error) error {
+	return ib.setStatus(id, StatusFailed, errorString(cause))
+}
+
+// MarkRejected registra un fallo definitivo; el registro no se vuelve a procesar
+func (ib *Inbox) MarkRejected(id string, cause error) error {
+	return ib.setStatus(id, StatusRejected, errorString(cause))
+}
+
+// MarkReturned registra un fallo reintentable que se respondió al emisor (5xx o 503). El
+// emisor vuelve a entregar el webhook como un registro nuevo, así que este no se reprocesa.
+func (ib *Inbox) MarkReturned(id string, cause error) error {
+	return ib.setStatus(id, StatusReturned, errorString(cause))
+}
+
+// Get retorna un registro por ID
+func (ib *Inbox) Get(id string) (Record, bool) {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+
+	record, ok := ib.records[id]
+	if !ok {
+		return Record{}, false
+	}
+	return *record, true
+}
+
+// Pending retorna, en orden de recepción, los registros que aún deben procesarse
+func (ib *Inbox) Pending() []Record {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+
+	var pending []Record
+	for _, record := range ib.records {
+		if !record.Done() {
+			pending = append(pending, *record)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].ID < pending[j].ID
+	})
+	return pending
+}
+
+// Close cierra el segmento activo
+func (ib *Inbox) Close() error {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+	return ib.segment.Close()
+}
+
+func (ib *Inbox) setStatus(id, status, errMsg string) error {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+
+	record, ok := ib.records[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	now := ib.now()
+	if err := ib.write(entry{Type: entryStatus, ID: id, Status: status, Error: errMsg, Timestamp: now}); err != nil {
+		return err
+	}
+	applyStatus(record, status, errMsg, now)
+	return nil
+}
+
+// write agrega una línea al segmento activo y hace fsync. Si el segmento activo ya superó el
+// tamaño máximo rota antes de escribir, así la compactación ve el estado de todas las
+// líneas escritas.
+func (ib *Inbox) write(e entry) error {
+	if ib.segmentLen >= ib.segmentSize {
+		if err := ib.segment.Close(); err != nil {
+			return fmt.Errorf("failed to close inbox segment: %w", err)
+		}
+		if err := ib.openSegment(ib.segmentSeq + 1); err != nil {
+			return err
+		}
+		ib.compact()
+	}
+	return ib.appendEntry(e)
+}
+
+// appendEntry agrega una línea al segmento activo y hace fsync, sin rotar
+func (ib *Inbox) appendEntry(e entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode inbox entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	if _, err := ib.segment.Write(line); err != nil {
+		return fmt.Errorf("failed to write inbox entry: %w", err)
+	}
+	if err := ib.segment.Sync(); err != nil {
+		return fmt.Errorf("failed to sync inbox segment: %w", err)
+	}
+	ib.segmentLen += int64(len(line))
+	return nil
+}
+
+// replay aplica las líneas de un segmento al estado en memoria
+func (ib *Inbox) replay(seq int) error {
+	file, err := os.Open(ib.segmentPath(seq))
+	if err != nil {
+		return fmt.Errorf("failed to open inbox segment: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64<<20)
+	for scanner.Scan() {
+		var e entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// Una línea incompleta al final del segmento indica una caída durante la escritura
+			continue
+		}
+
+		switch e.Type {
+		case entryReceived:
+			if e.Record != nil {
+				record := *e.Record
+				ib.records[record.ID] = &record
+				ib.segmentOf[record.ID] = seq
+			}
+		case entryStatus:
+			if record, ok := ib.records[e.ID]; ok {
+				applyStatus(record, e.Status, e.Error, e.Timestamp)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read inbox segment: %w", err)
+	}
+	return nil
+}
+
+// compact elimina los segmentos anteriores al activo, del más antiguo al más nuevo. Antes
+// de borrar un segmento copia sus registros pendientes, con el estado actual, al segmento
+// activo: el estado de un registro puede estar en líneas "status" de segmentos posteriores,
+// así que ningún segmento se borra mientras uno anterior siga en disco.
+func (ib *Inbox) compact() {
+	seqs, err := ib.segments()
+	if err != nil {
+		return
+	}
+
+	now := ib.now()
+	for _, seq := range seqs {
+		if seq == ib.segmentSeq {
+			continue
+		}
+
+		var ids []string
+		for id, recordSeq := range ib.segmentOf {
+			if recordSeq == seq {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			record := ib.records[id]
+			if record.Done() {
+				continue
+			}
+			carried := *record
+			if err := ib.appendEntry(entry{Type: entryReceived, Record: &carried, Timestamp: now}); err != nil {
+				return
+			}
+			ib.segmentOf[id] = ib.segmentSeq
+		}
+
+		if err := os.Remove(ib.segmentPath(seq)); err != nil {
+			return
+		}
+		for _, id := range ids {
+			if ib.segmentOf[id] == seq {
+				delete(ib.records, id)
+				delete(ib.segmentOf, id)
+			}
+		}
+	}
+}
+
+func (ib *Inbox) openSegment(seq int) error {
+	file, err := os.OpenFile(ib.segmentPath(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open inbox segment: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat inbox segment: %w", err)
+	}
+
+	ib.segment = file
+	ib.segmentSeq = seq
+	ib.segmentLen = info.Size()
+	return nil
+}
+
+// segments retorna los números de segmento existentes en orden ascendente
+func (ib *Inbox) segments() ([]int, error) {
+	entries, err := os.ReadDir(ib.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list inbox dir: %w", err)
+	}
+
+	var seqs []int
+	for _, e := range entries {
+		var seq int
+		if _, err := fmt.Sscanf(e.Name(), "inbox-%06d.jsonl", &seq); err == nil && strings.HasSuffix(e.Name(), ".jsonl") {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Ints(seqs)
+	return seqs, nil
+}
+
+func (ib *Inbox) segmentPath(seq int) string {
+	return filepath.Join(ib.dir, fmt.Sprintf("inbox-%06d.jsonl", seq))
+}
+
+func applyStatus(record *Record, status, errMsg string, at time.Time) {
+	record.Status = status
+	record.LastError = errMsg
+	record.UpdatedAt = at
+	if status != StatusPending {
+		record.Attempts++
+	}
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
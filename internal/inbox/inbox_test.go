@@ -0,0 +1,162 @@
+package inbox
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"webhook_receiver/internal/dto"
+)
+
+func TestRecovery(t *testing.T) {
+	cause := errors.New("database unavailable")
+
+	tests := []struct {
+		name        string
+		mark        func(ib *Inbox, id string) error
+		wantPending bool
+		wantStatus  string
+		wantError   string
+	}{
+		{name: "pending", wantPending: true, wantStatus: StatusPending},
+		{name: "processed", mark: func(ib *Inbox, id string) error { return ib.MarkProcessed(id) }},
+		{name: "failed", mark: func(ib *Inbox, id string) error { return ib.MarkFailed(id, cause) },
+			wantPending: true, wantStatus: StatusFailed, wantError: cause.Error()},
+		{name: "rejected", mark: func(ib *Inbox, id string) error { return ib.MarkRejected(id, cause) }},
+		{name: "returned", mark: func(ib *Inbox, id string) error { return ib.MarkReturned(id, cause) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			ib, err := Open(dir, Options{})
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			record, err := ib.Append(dto.WebhookHeaders{IDKey: "key-1"}, []byte(`{"webhook_id":1}`))
+			if err != nil {
+				t.Fatalf("Append() error = %v", err)
+			}
+			if tt.mark != nil {
+				if err := tt.mark(ib, record.ID); err != nil {
+					t.Fatalf("mark error = %v", err)
+				}
+			}
+			ib.Close()
+
+			reopened, err := Open(dir, Options{})
+			if err != nil {
+				t.Fatalf("Open() after restart error = %v", err)
+			}
+			defer reopened.Close()
+
+			pending := reopened.Pending()
+			if got := len(pending) == 1 && pending[0].ID == record.ID; got != tt.wantPending {
+				t.Errorf("pending = %+v, want pending %v", pending, tt.wantPending)
+			}
+			// Al abrir se compactan los segmentos anteriores: solo se conservan los registros pendientes
+			got, ok := reopened.Get(record.ID)
+			if ok != tt.wantPending {
+				t.Fatalf("record kept after restart = %v, want %v", ok, tt.wantPending)
+			}
+			if !ok {
+				return
+			}
+			if got.Status != tt.wantStatus || got.LastError != tt.wantError {
+				t.Errorf("record = %s (%q), want %s (%q)", got.Status, got.LastError, tt.wantStatus, tt.wantError)
+			}
+			if string(got.Body) != `{"webhook_id":1}` || got.Headers.IDKey != "key-1" {
+				t.Errorf("record body = %s, headers = %+v", got.Body, got.Headers)
+			}
+		})
+	}
+}
+
+func TestRecoveryIgnoresTruncatedLine(t *testing.T) {
+	dir := t.TempDir()
+	ib, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	record, _ := ib.Append(dto.WebhookHeaders{}, []byte(`{"webhook_id":1}`))
+	ib.Close()
+
+	// Simular una caída a mitad de una escritura
+	file, err := os.OpenFile(filepath.Join(dir, "inbox-000001.jsonl"), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"type":"status","id":"` + record.ID + `","sta`)
+	file.Close()
+
+	reopened, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer reopened.Close()
+	if pending := reopened.Pending(); len(pending) != 1 || pending[0].Status != StatusPending {
+		t.Errorf("pending = %+v, want the record still pending", pending)
+	}
+}
+
+func TestMarkUnknownRecord(t *testing.T) {
+	ib, err := Open(t.TempDir(), Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer ib.Close()
+	if err := ib.MarkProcessed("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("MarkProcessed() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestCompaction(t *testing.T) {
+	const segmentSize = 400
+	dir := t.TempDir()
+	ib, err := Open(dir, Options{SegmentSize: segmentSize})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	processed, _ := ib.Append(dto.WebhookHeaders{}, []byte(`{"a":1}`))
+	failed, _ := ib.Append(dto.WebhookHeaders{}, []byte(`{"b":1}`))
+	pending, _ := ib.Append(dto.WebhookHeaders{}, []byte(`{"c":1}`))
+	// Llenar varios segmentos con registros terminados para forzar rotaciones
+	for i := 0; i < 10; i++ {
+		record, _ := ib.Append(dto.WebhookHeaders{}, []byte(`{"x":1}`))
+		ib.MarkProcessed(record.ID)
+	}
+	// Los cambios de estado quedan en segmentos posteriores al de sus registros
+	ib.MarkProcessed(processed.ID)
+	ib.MarkFailed(failed.ID, errors.New("boom"))
+	for i := 0; i < 10; i++ {
+		record, _ := ib.Append(dto.WebhookHeaders{}, []byte(`{"y":1}`))
+		ib.MarkReturned(record.ID, errors.New("returned"))
+	}
+
+	segments, _ := ib.segments()
+	if len(segments) > 2 {
+		t.Errorf("segments = %v, want old segments removed", segments)
+	}
+	ib.Close()
+
+	// Reabrir dos veces: la compactación al abrir no debe perder los pendientes
+	for i := 0; i < 2; i++ {
+		reopened, err := Open(dir, Options{SegmentSize: segmentSize})
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+
+		got := reopened.Pending()
+		if len(got) != 2 || got[0].ID != failed.ID || got[1].ID != pending.ID {
+			t.Fatalf("reopen %d: pending = %+v, want the failed and the pending records", i+1, got)
+		}
+		if got[0].Status != StatusFailed || got[0].Attempts != 1 || got[0].LastError != "boom" {
+			t.Errorf("reopen %d: failed record = %+v, want its state carried over", i+1, got[0])
+		}
+		if _, ok := reopened.Get(processed.ID); ok {
+			t.Errorf("reopen %d: processed record was not compacted", i+1)
+		}
+		reopened.Close()
+	}
+}
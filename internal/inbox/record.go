@@ -0,0 +1,45 @@
+package inbox
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"webhook_receiver/internal/dto"
+)
+
+// Estados de procesamiento de un registro del inbox
+const (
+	StatusPending   = "pending"   // Recibido, aún sin procesar
+	StatusProcessed = "processed" // Procesado correctamente
+	StatusFailed    = "failed"    // Falló con un error reintentable; se vuelve a procesar al reiniciar
+	StatusRejected  = "rejected"  // Falló de forma definitiva (payload inválido o rechazado)
+	StatusReturned  = "returned"  // Falló con un error reintentable que se respondió al emisor, que lo vuelve a entregar
+)
+
+// Record es un webhook verificado guardado en el inbox
+type Record struct {
+	ID         string             `json:"id"`
+	ReceivedAt time.Time          `json:"received_at"`
+	Headers    dto.WebhookHeaders `json:"headers"`
+	Body       []byte             `json:"body"`
+	Status     string             `json:"status"`
+	Attempts   int                `json:"attempts"`
+	LastError  string             `json:"last_error,omitempty"`
+	UpdatedAt  time.Time          `json:"updated_at"`
+}
+
+// Done indica si el registro ya no necesita procesarse
+func (r Record) Done() bool {
+	return r.Status == StatusProcessed || r.Status == StatusRejected || r.Status == StatusReturned
+}
+
+// newID genera un ID ordenable por tiempo de recepción
+func newID(now time.Time) string {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Sprintf("%020d", now.UnixNano())
+	}
+	return fmt.Sprintf("%020d-%s", now.UnixNano(), hex.EncodeToString(suffix))
+}
@@ -0,0 +1,228 @@
+package invoice
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"webhook_receiver/internal/worker"
+)
+
+// Valores por defecto del Fetcher
+const (
+	DefaultTimeout     = 10 * time.Second
+	DefaultMaxSize     = 5 << 20 // 5 MB
+	DefaultMaxAttempts = 3
+)
+
+// DefaultMaxRedirects es la cantidad máxima de redirecciones que se siguen al descargar el XML
+const DefaultMaxRedirects = 5
+
+// DefaultRetryPolicy reintenta rápido para no acumular descargas pendientes
+var DefaultRetryPolicy = worker.RetryPolicy{
+	MaxAttempts:    DefaultMaxAttempts,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+}
+
+var (
+	// ErrTooLarge se retorna cuando el XML supera el tamaño máximo configurado
+	ErrTooLarge = errors.New("invoice XML exceeds size limit")
+	// ErrHostNotAllowed se retorna cuando xml_url apunta a un host fuera de la lista permitida
+	ErrHostNotAllowed = errors.New("invoice host not allowed")
+	// ErrInvalidURL se retorna cuando xml_url no es una URL http o https
+	ErrInvalidURL = errors.New("invalid invoice URL")
+)
+
+// StatusError es una respuesta HTTP no exitosa al descargar el XML
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d fetching invoice XML", e.StatusCode)
+}
+
+// Temporary indica si el status justifica reintentar (429 y 5xx)
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// Fetcher descarga y decodifica el XML de facturas electrónicas
+type Fetcher struct {
+	client       *http.Client
+	maxSize      int64
+	retryPolicy  worker.RetryPolicy
+	allowedHosts map[string]bool
+}
+
+// FetcherOption configura opciones adicionales del Fetcher
+type FetcherOption func(*Fetcher)
+
+// WithTimeout define el tiempo máximo de cada intento de descarga
+func WithTimeout(timeout time.Duration) FetcherOption {
+	return func(f *Fetcher) {
+		if timeout > 0 {
+			f.client.Timeout = timeout
+		}
+	}
+}
+
+// WithMaxSize define el tamaño máximo en bytes del XML
+func WithMaxSize(maxSize int64) FetcherOption {
+	return func(f *Fetcher) {
+		if maxSize > 0 {
+			f.maxSize = maxSize
+		}
+	}
+}
+
+// WithRetryPolicy define los reintentos ante errores de red, 429 o 5xx
+func WithRetryPolicy(policy worker.RetryPolicy) FetcherOption {
+	return func(f *Fetcher) {
+		f.retryPolicy = policy.WithDefaults()
+	}
+}
+
+// WithAllowedHosts define los hosts desde los que se descarga el XML (sin lista no se descarga ninguno)
+func WithAllowedHosts(hosts ...string) FetcherOption {
+	return func(f *Fetcher) {
+		for _, host := range hosts {
+			f.allowedHosts[host] = true
+		}
+	}
+}
+
+// WithHTTPClient reemplaza el cliente HTTP (por ejemplo para usar un transporte propio).
+// Las redirecciones siempre se validan contra la lista de hosts permitidos.
+func WithHTTPClient(client *http.Client) FetcherOption {
+	return func(f *Fetcher) {
+		if client != nil {
+			f.client = client
+		}
+	}
+}
+
+// NewFetcher crea una nueva instancia del Fetcher
+func NewFetcher(opts ...FetcherOption) *Fetcher {
+	f := &Fetcher{
+		client:       &http.Client{Timeout: DefaultTimeout},
+		maxSize:      DefaultMaxSize,
+		retryPolicy:  DefaultRetryPolicy,
+		allowedHosts: make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	// Copiar el cliente para no modificar el de WithHTTPClient
+	client := *f.client
+	client.CheckRedirect = f.checkRedirect
+	f.client = &client
+	return f
+}
+
+// Fetch descarga el XML de rawURL, reintentando los errores transitorios, y lo decodifica
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Invoice, error) {
+	if err := f.checkURL(rawURL); err != nil {
+		return nil, err
+	}
+
+	var err error
+	for attempt := 1; attempt <= f.retryPolicy.MaxAttempts; attempt++ {
+		var data []byte
+		if data, err = f.download(ctx, rawURL); err == nil {
+			return Parse(bytes.NewReader(data))
+		}
+		if !temporary(err) || attempt == f.retryPolicy.MaxAttempts {
+			break
+		}
+
+		select {
+		case <-time.After(f.retryPolicy.Backoff(attempt)):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return nil, err
+}
+
+// checkURL valida el esquema y el host de rawURL
+func (f *Fetcher) checkURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %q", ErrInvalidURL, rawURL)
+	}
+	return f.checkTarget(parsed)
+}
+
+// checkTarget valida que target sea http o https y apunte a un host permitido
+func (f *Fetcher) checkTarget(target *url.URL) error {
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return fmt.Errorf("%w: %q", ErrInvalidURL, target.String())
+	}
+	if !f.allowedHosts[target.Hostname()] {
+		return fmt.Errorf("%w: %s", ErrHostNotAllowed, target.Hostname())
+	}
+	return nil
+}
+
+// checkRedirect aplica a cada redirección las mismas reglas que a xml_url, para que un
+// host permitido no pueda redirigir la descarga a la red interna
+func (f *Fetcher) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= DefaultMaxRedirects {
+		return fmt.Errorf("stopped after %d redirects", DefaultMaxRedirects)
+	}
+	return f.checkTarget(req.URL)
+}
+
+// download hace un intento de descarga respetando el tamaño máximo
+func (f *Fetcher) download(ctx context.Context, rawURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/xml, text/xml")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+	if resp.ContentLength > f.maxSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrTooLarge, resp.ContentLength)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, f.maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > f.maxSize {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, f.maxSize)
+	}
+	return data, nil
+}
+
+// temporary indica si vale la pena reintentar la descarga
+func temporary(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Temporary()
+	}
+	// Errores de red y timeouts; el tamaño excedido no cambia al reintentar
+	return !errors.Is(err, ErrTooLarge) && !errors.Is(err, context.Canceled)
+}
+
+// IsTemporary indica si err, retornado por Fetch, es transitorio y el evento se puede reintentar más tarde
+func IsTemporary(err error) bool {
+	return !errors.Is(err, ErrInvalidInvoice) && !errors.Is(err, ErrHostNotAllowed) && !errors.Is(err, ErrInvalidURL) && temporary(err)
+}
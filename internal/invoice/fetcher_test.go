@@ -0,0 +1,67 @@
+package invoice
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"webhook_receiver/internal/worker"
+)
+
+func TestFetcherAllowedHosts(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the fetcher reached a host outside the allowlist")
+	}))
+	defer internal.Close()
+	// El mismo servidor por otro nombre: localhost no está en la lista de hosts permitidos
+	internalURL := strings.Replace(internal.URL, "127.0.0.1", "localhost", 1)
+
+	public := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, internalURL+"/secret", http.StatusFound)
+		case "/scheme":
+			http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer public.Close()
+
+	tests := []struct {
+		name    string
+		hosts   []string
+		url     string
+		wantErr error
+	}{
+		{name: "empty allowlist", url: public.URL + "/missing", wantErr: ErrHostNotAllowed},
+		{name: "host not allowed", hosts: []string{"127.0.0.1"}, url: internalURL + "/secret", wantErr: ErrHostNotAllowed},
+		{name: "redirect to host not allowed", hosts: []string{"127.0.0.1"}, url: public.URL + "/redirect", wantErr: ErrHostNotAllowed},
+		{name: "redirect to other scheme", hosts: []string{"127.0.0.1"}, url: public.URL + "/scheme", wantErr: ErrInvalidURL},
+		{name: "invalid scheme", hosts: []string{"127.0.0.1"}, url: "ftp://127.0.0.1/bill.xml", wantErr: ErrInvalidURL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetcher := NewFetcher(WithAllowedHosts(tt.hosts...), WithRetryPolicy(worker.RetryPolicy{MaxAttempts: 1}))
+			_, err := fetcher.Fetch(context.Background(), tt.url)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Fetch() error = %v, want %v", err, tt.wantErr)
+			}
+			if IsTemporary(err) {
+				t.Errorf("IsTemporary(%v) = true, want false", err)
+			}
+		})
+	}
+
+	t.Run("allowed host", func(t *testing.T) {
+		fetcher := NewFetcher(WithAllowedHosts("127.0.0.1"), WithRetryPolicy(worker.RetryPolicy{MaxAttempts: 1}))
+		_, err := fetcher.Fetch(context.Background(), public.URL+"/missing")
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+			t.Fatalf("Fetch() error = %v, want status 404", err)
+		}
+	})
+}
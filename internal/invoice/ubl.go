@@ -0,0 +1,232 @@
+package invoice
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"webhook_receiver/internal/dto"
+)
+
+// ErrInvalidInvoice se retorna cuando el XML no es una factura UBL 2.1 reconocible
+var ErrInvalidInvoice = errors.New("invalid UBL invoice")
+
+// Invoice contiene los datos extraídos de una factura electrónica UBL 2.1 / DIAN
+type Invoice struct {
+	Number    string `json:"number"`     // cbc:ID
+	CUFE      string `json:"cufe"`       // cbc:UUID (código único de factura electrónica)
+	IssueDate string `json:"issue_date"` // cbc:IssueDate
+	DueDate   string `json:"due_date"`   // cbc:DueDate o cac:PaymentMeans/cbc:PaymentDueDate
+	Currency  string `json:"currency"`   // cbc:DocumentCurrencyCode
+	Lines     []Line `json:"lines"`
+	Taxes     []Tax  `json:"taxes"`
+	// Total es el valor a pagar (cac:LegalMonetaryTotal/cbc:PayableAmount)
+	Total dto.Decimal `json:"total"`
+}
+
+// Line es un ítem de la factura (cac:InvoiceLine)
+type Line struct {
+	ID          string      `json:"id"`
+	Description string      `json:"description"`
+	Quantity    dto.Decimal `json:"quantity"`
+	UnitCode    string      `json:"unit_code,omitempty"`
+	UnitPrice   dto.Decimal `json:"unit_price"`
+	Amount      dto.Decimal `json:"amount"` // cbc:LineExtensionAmount
+	Taxes       []Tax       `json:"taxes,omitempty"`
+}
+
+// Tax es un impuesto de la factura o de una línea (cac:TaxSubtotal)
+type Tax struct {
+	SchemeID      string      `json:"scheme_id"` // "01" IVA, "03" ICA, "04" INC, ...
+	Name          string      `json:"name"`
+	Percent       dto.Decimal `json:"percent"`
+	TaxableAmount dto.Decimal `json:"taxable_amount"`
+	Amount        dto.Decimal `json:"amount"`
+}
+
+// Estructuras XML. encoding/xml compara solo el nombre local cuando el tag no incluye el
+// namespace, así que los prefijos cbc:/cac: no necesitan declararse.
+type ublInvoice struct {
+	XMLName      xml.Name         `xml:"Invoice"`
+	ID           string           `xml:"ID"`
+	UUID         string           `xml:"UUID"`
+	IssueDate    string           `xml:"IssueDate"`
+	DueDate      string           `xml:"DueDate"`
+	Currency     string           `xml:"DocumentCurrencyCode"`
+	PaymentMeans []ublPayment     `xml:"PaymentMeans"`
+	TaxTotals    []ublTaxTotal    `xml:"TaxTotal"`
+	Monetary     ublMonetary      `xml:"LegalMonetaryTotal"`
+	Lines        []ublInvoiceLine `xml:"InvoiceLine"`
+}
+
+type ublPayment struct {
+	PaymentDueDate string `xml:"PaymentDueDate"`
+}
+
+type ublTaxTotal struct {
+	TaxAmount    string           `xml:"TaxAmount"`
+	TaxSubtotals []ublTaxSubtotal `xml:"TaxSubtotal"`
+}
+
+type ublTaxSubtotal struct {
+	TaxableAmount string `xml:"TaxableAmount"`
+	TaxAmount     string `xml:"TaxAmount"`
+	Percent       string `xml:"TaxCategory>Percent"`
+	SchemeID      string `xml:"TaxCategory>TaxScheme>ID"`
+	SchemeName    string `xml:"TaxCategory>TaxScheme>Name"`
+}
+
+type ublMonetary struct {
+	PayableAmount string `xml:"PayableAmount"`
+}
+
+type ublQuantity struct {
+	Value    string `xml:",chardata"`
+	UnitCode string `xml:"unitCode,attr"`
+}
+
+type ublInvoiceLine struct {
+	ID          string        `xml:"ID"`
+	Quantity    ublQuantity   `xml:"InvoicedQuantity"`
+	Amount      string        `xml:"LineExtensionAmount"`
+	Description []string      `xml:"Item>Description"`
+	PriceAmount string        `xml:"Price>PriceAmount"`
+	TaxTotals   []ublTaxTotal `xml:"TaxTotal"`
+}
+
+// ublAttachedDocument es el contenedor que envía la DIAN: la factura viaja como texto
+// dentro de cac:Attachment/cac:ExternalReference/cbc:Description
+type ublAttachedDocument struct {
+	XMLName     xml.Name `xml:"AttachedDocument"`
+	Description string   `xml:"Attachment>ExternalReference>Description"`
+}
+
+// Parse decodifica una factura UBL 2.1 (raíz Invoice) o un AttachedDocument de la DIAN
+// que la contenga
+func Parse(r io.Reader) (*Invoice, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read invoice XML: %w", err)
+	}
+
+	root, err := rootElement(data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch root {
+	case "Invoice":
+	case "AttachedDocument":
+		var attached ublAttachedDocument
+		if err := xml.Unmarshal(data, &attached); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidInvoice, err)
+		}
+		embedded := []byte(strings.TrimSpace(attached.Description))
+		if inner, err := rootElement(embedded); err != nil || inner != "Invoice" {
+			return nil, fmt.Errorf("%w: AttachedDocument does not contain an Invoice", ErrInvalidInvoice)
+		}
+		data = embedded
+	default:
+		return nil, fmt.Errorf("%w: unexpected root element %q", ErrInvalidInvoice, root)
+	}
+
+	var doc ublInvoice
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInvoice, err)
+	}
+	if doc.ID == "" {
+		return nil, fmt.Errorf("%w: missing invoice number (cbc:ID)", ErrInvalidInvoice)
+	}
+	if doc.Monetary.PayableAmount == "" {
+		return nil, fmt.Errorf("%w: missing cac:LegalMonetaryTotal/cbc:PayableAmount", ErrInvalidInvoice)
+	}
+
+	inv, err := doc.toInvoice()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInvoice, err)
+	}
+	return inv, nil
+}
+
+// rootElement retorna el nombre local del elemento raíz del documento
+func rootElement(data []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidInvoice, err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+// amounts convierte los montos del XML a dto.Decimal y conserva el primer error
+type amounts struct {
+	err error
+}
+
+func (a *amounts) parse(field, value string) dto.Decimal {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return dto.Decimal{}
+	}
+	d, err := dto.ParseDecimal(value)
+	if err != nil && a.err == nil {
+		a.err = fmt.Errorf("%s: %w", field, err)
+	}
+	return d
+}
+
+func (doc ublInvoice) toInvoice() (*Invoice, error) {
+	a := &amounts{}
+	inv := &Invoice{
+		Number:    strings.TrimSpace(doc.ID),
+		CUFE:      strings.TrimSpace(doc.UUID),
+		IssueDate: strings.TrimSpace(doc.IssueDate),
+		DueDate:   strings.TrimSpace(doc.DueDate),
+		Currency:  strings.TrimSpace(doc.Currency),
+		Taxes:     a.taxes(doc.TaxTotals),
+		Total:     a.parse("PayableAmount", doc.Monetary.PayableAmount),
+	}
+
+	// Las facturas DIAN suelen traer el vencimiento en los medios de pago
+	for _, payment := range doc.PaymentMeans {
+		if inv.DueDate == "" && payment.PaymentDueDate != "" {
+			inv.DueDate = strings.TrimSpace(payment.PaymentDueDate)
+		}
+	}
+
+	for _, l := range doc.Lines {
+		inv.Lines = append(inv.Lines, Line{
+			ID:          strings.TrimSpace(l.ID),
+			Description: strings.TrimSpace(strings.Join(l.Description, " ")),
+			Quantity:    a.parse("InvoicedQuantity", l.Quantity.Value),
+			UnitCode:    l.Quantity.UnitCode,
+			UnitPrice:   a.parse("PriceAmount", l.PriceAmount),
+			Amount:      a.parse("LineExtensionAmount", l.Amount),
+			Taxes:       a.taxes(l.TaxTotals),
+		})
+	}
+	return inv, a.err
+}
+
+func (a *amounts) taxes(totals []ublTaxTotal) []Tax {
+	var result []Tax
+	for _, total := range totals {
+		for _, sub := range total.TaxSubtotals {
+			result = append(result, Tax{
+				SchemeID:      strings.TrimSpace(sub.SchemeID),
+				Name:          strings.TrimSpace(sub.SchemeName),
+				Percent:       a.parse("Percent", sub.Percent),
+				TaxableAmount: a.parse("TaxableAmount", sub.TaxableAmount),
+				Amount:        a.parse("TaxAmount", sub.TaxAmount),
+			})
+		}
+	}
+	return result
+}
@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"webhook_receiver/internal/dto"
+	"webhook_receiver/internal/processor"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Formatos de salida
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Setup crea el logger con el nivel (debug, info, warn, error) y el formato (text o json)
+// indicados y lo instala como logger por defecto de slog y del paquete log
+func Setup(level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q (expected debug, info, warn or error)", level)
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", FormatText:
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(os.Stdout, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q (expected text or json)", format)
+	}
+
+	logger := slog.New(NewHandler(handler))
+	slog.SetDefault(logger)
+	return logger, nil
+}
+
+// NewHandler envuelve handler para que cada registro incluya los campos guardados con With
+// y el trace_id del span activo. Setup lo usa con la salida estándar.
+func NewHandler(handler slog.Handler) slog.Handler {
+	return &contextHandler{Handler: handler}
+}
+
+type attrsKey struct{}
+
+// With retorna un contexto cuyos logs (slog.*Context) incluyen attrs, además de los que ya tenía
+func With(ctx context.Context, attrs ...any) context.Context {
+	previous, _ := ctx.Value(attrsKey{}).([]any)
+	merged := make([]any, 0, len(previous)+len(attrs))
+	merged = append(merged, previous...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, attrsKey{}, merged)
+}
+
+// EventAttrs retorna los campos que identifican un webhook en los logs: data_type,
+// webhook_id, contract_id y, según el tipo, trigger_type y bill_id
+func EventAttrs(event processor.Event) []any {
+	attrs := []any{
+		slog.String("data_type", event.DataType),
+		slog.Int("webhook_id", event.WebhookID),
+		slog.Int("contract_id", event.ContractID),
+	}
+	if event.TriggerType != "" {
+		attrs = append(attrs, slog.String("trigger_type", event.TriggerType))
+	}
+	if payload, ok := event.Payload.(dto.BillWebhookPayload); ok {
+		attrs = append(attrs, slog.Int("bill_id", payload.Bill.BillID))
+	}
+	return attrs
+}
+
+// contextHandler agrega a cada registro los campos guardados con With y el trace_id del span activo
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs, ok := ctx.Value(attrsKey{}).([]any); ok {
+		record.Add(attrs...)
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(slog.String("trace_id", spanContext.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader es el header con el que se recibe (o se genera) y se responde el ID de la petición
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDKey es la clave del ID de la petición en el contexto de Gin
+const RequestIDKey = "request_id"
+
+// Middleware asigna un request_id a cada petición (el del header X-Request-ID si viene),
+// lo agrega a los logs de la petición y escribe un log de acceso, con los campos que los
+// middlewares y handlers agregaron al contexto de la petición, y el nivel según el status:
+// error para 5xx, warn para 4xx, info para el resto y debug para /health y /metrics.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" || len(requestID) > 128 {
+			requestID = newRequestID()
+		}
+		c.Set(RequestIDKey, requestID)
+		c.Header(RequestIDHeader, requestID)
+
+		ctx := With(c.Request.Context(), slog.String("request_id", requestID))
+		c.Request = c.Request.WithContext(ctx)
+
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		case c.FullPath() == "/health" || c.FullPath() == "/metrics":
+			level = slog.LevelDebug
+		}
+
+		attrs := []any{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("response_size", c.Writer.Size()),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("errors", c.Errors.String()))
+		}
+		// El contexto de la petición incluye los campos agregados más adelante, como secret_id
+		slog.Log(c.Request.Context(), level, "HTTP request", attrs...)
+	}
+}
+
+// newRequestID genera un ID aleatorio de 16 caracteres hexadecimales
+func newRequestID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(id)
+}
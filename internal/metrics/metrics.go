@@ -0,0 +1,179 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Resultados de un webhook (label outcome)
+const (
+	OutcomeProcessed = "processed" // procesado correctamente
+	OutcomeFailed    = "failed"    // error reintentable
+	OutcomeRejected  = "rejected"  // rechazo definitivo del procesador
+	OutcomeInvalid   = "invalid"   // payload inválido o data_type desconocido
+	OutcomeQueueFull = "queue_full"
+)
+
+// unknownDataType se usa como label cuando el data_type no está registrado, para no
+// crear series nuevas por cada valor recibido
+const unknownDataType = "unknown"
+
+// Metrics agrupa los collectors de Prometheus del receptor. Todos los métodos aceptan un
+// *Metrics nil y en ese caso no hacen nada, así que las métricas son opcionales.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requestDuration *prometheus.HistogramVec
+	requestSize     *prometheus.HistogramVec
+	events          *prometheus.CounterVec
+	signatureErrors *prometheus.CounterVec
+	bodySize        *prometheus.HistogramVec
+	processing      *prometheus.HistogramVec
+	lastReceived    *prometheus.GaugeVec
+}
+
+// New crea las métricas en un registry propio, con las métricas del runtime de Go y del proceso
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "webhook_http_request_duration_seconds",
+			Help:    "Latency of HTTP requests by method, route and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		requestSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "webhook_http_request_size_bytes",
+			Help:    "Size of HTTP request bodies by method and route.",
+			Buckets: prometheus.ExponentialBuckets(256, 4, 8), // 256 B .. 4 MiB
+		}, []string{"method", "route"}),
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "webhook_events_total",
+			Help: "Verified webhooks by data_type, trigger_type and outcome.",
+		}, []string{"data_type", "trigger_type", "outcome"}),
+		signatureErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "webhook_signature_failures_total",
+			Help: "Requests rejected by signature verification, by reason.",
+		}, []string{"reason"}),
+		bodySize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "webhook_body_size_bytes",
+			Help:    "Size of verified webhook bodies by data_type.",
+			Buckets: prometheus.ExponentialBuckets(256, 4, 8),
+		}, []string{"data_type"}),
+		processing: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "webhook_processing_duration_seconds",
+			Help:    "Duration of the processors and hooks by data_type and outcome.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"data_type", "outcome"}),
+		lastReceived: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "webhook_last_received_timestamp_seconds",
+			Help: "Unix time of the last verified webhook by webhook_id.",
+		}, []string{"webhook_id"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requestDuration, m.requestSize, m.events, m.signatureErrors, m.bodySize, m.processing, m.lastReceived,
+	)
+	return m
+}
+
+// Handler expone las métricas en el formato de Prometheus
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware mide la latencia y el tamaño del body de cada petición. Las rutas se
+// etiquetan con su patrón (c.FullPath()) para no crear una serie por URL.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if m == nil {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		m.requestDuration.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+		if c.Request.ContentLength >= 0 {
+			m.requestSize.WithLabelValues(c.Request.Method, route).Observe(float64(c.Request.ContentLength))
+		}
+	}
+}
+
+// ObserveReceived registra un webhook verificado y decodificado
+func (m *Metrics) ObserveReceived(dataType string, webhookID, bodySize int, at time.Time) {
+	if m == nil {
+		return
+	}
+	m.bodySize.WithLabelValues(dataType).Observe(float64(bodySize))
+	m.lastReceived.WithLabelValues(strconv.Itoa(webhookID)).Set(float64(at.UnixNano()) / 1e9)
+}
+
+// ObserveOutcome cuenta el resultado de un webhook
+func (m *Metrics) ObserveOutcome(dataType, triggerType, outcome string) {
+	if m == nil {
+		return
+	}
+	if dataType == "" {
+		dataType = unknownDataType
+	}
+	m.events.WithLabelValues(dataType, triggerType, outcome).Inc()
+}
+
+// ObserveProcessing registra la duración de los procesadores de un evento
+func (m *Metrics) ObserveProcessing(dataType, outcome string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.processing.WithLabelValues(dataType, outcome).Observe(duration.Seconds())
+}
+
+// ObserveSignatureFailure cuenta un rechazo de la verificación de firma
+func (m *Metrics) ObserveSignatureFailure(reason string) {
+	if m == nil {
+		return
+	}
+	m.signatureErrors.WithLabelValues(reason).Inc()
+}
+
+// WatchQueues expone como gauge la profundidad de las colas que retorna depth en cada scrape
+func (m *Metrics) WatchQueues(depth func() map[string]int) {
+	if m == nil {
+		return
+	}
+	m.registry.MustRegister(&queueCollector{depth: depth})
+}
+
+// queueCollector lee la profundidad de las colas al momento del scrape
+type queueCollector struct {
+	depth func() map[string]int
+}
+
+var queueDepthDesc = prometheus.NewDesc(
+	"webhook_queue_depth",
+	"Jobs waiting in the asynchronous queue of each data_type.",
+	[]string{"data_type"}, nil,
+)
+
+func (q *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueDepthDesc
+}
+
+func (q *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	for dataType, depth := range q.depth() {
+		ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(depth), dataType)
+	}
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuthMiddleware protege los endpoints de administración con un token Bearer
+type AdminAuthMiddleware struct {
+	token string
+}
+
+// NewAdminAuthMiddleware crea una nueva instancia del middleware
+func NewAdminAuthMiddleware(token string) *AdminAuthMiddleware {
+	return &AdminAuthMiddleware{
+		token: token,
+	}
+}
+
+// RequireToken exige el header "Authorization: Bearer <token>"
+func (m *AdminAuthMiddleware) RequireToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !found || m.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(m.token)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":   "UNAUTHORIZED",
+				"message": "Invalid or missing admin token",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireToken(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		wantStatus    int
+	}{
+		{name: "valid token", token: "admin-token", authorization: "Bearer admin-token", wantStatus: http.StatusOK},
+		{name: "missing header", token: "admin-token", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", token: "admin-token", authorization: "Bearer other-token", wantStatus: http.StatusUnauthorized},
+		{name: "token prefix", token: "admin-token", authorization: "Bearer admin", wantStatus: http.StatusUnauthorized},
+		{name: "not a bearer token", token: "admin-token", authorization: "Basic admin-token", wantStatus: http.StatusUnauthorized},
+		{name: "bare token", token: "admin-token", authorization: "admin-token", wantStatus: http.StatusUnauthorized},
+		{name: "empty configured token", token: "", authorization: "Bearer ", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			engine := gin.New()
+			engine.GET("/admin/dead-letters", NewAdminAuthMiddleware(tt.token).RequireToken(), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/dead-letters", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
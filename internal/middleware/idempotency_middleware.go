@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"webhook_receiver/internal/idempotency"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyMiddleware evita procesar dos veces una entrega con el mismo X-Idempotency-Key
+type IdempotencyMiddleware struct {
+	store idempotency.Store
+}
+
+// NewIdempotencyMiddleware crea una nueva instancia del middleware
+func NewIdempotencyMiddleware(store idempotency.Store) *IdempotencyMiddleware {
+	return &IdempotencyMiddleware{
+		store: store,
+	}
+}
+
+// Handle aplica la idempotencia. Debe ir después de VerifySignature para que
+// solo se registren claves de peticiones autenticadas. Solo se registran las respuestas del
+// handler por debajo de 500: X-Idempotency-Key no está firmado, así que un rechazo de la firma
+// o del control de replay libera la clave en lugar de guardarla.
+func (m *IdempotencyMiddleware) Handle() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idKey := c.GetHeader("X-Idempotency-Key")
+		if idKey == "" {
+			c.Next()
+			return
+		}
+
+		// 1. Leer el body para calcular su hash y restaurarlo para el handler
+		payload, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "BAD_REQUEST",
+				"message": "Failed to read request body",
+			})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(payload))
+
+		// Las claves se aíslan por webhook para que dos suscripciones no colisionen
+		key := c.GetHeader("X-Webhook-ID") + ":" + idKey
+		bodyHash := idempotency.HashBody(payload)
+
+		// 2. Reservar la clave o recuperar el registro existente
+		ctx := c.Request.Context()
+		record, created, err := m.store.Begin(ctx, key, bodyHash)
+		if err != nil {
+			c.Header("Retry-After", "30")
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error":   "IDEMPOTENCY_UNAVAILABLE",
+				"message": "Failed to check idempotency key",
+			})
+			c.Abort()
+			return
+		}
+
+		if !created {
+			m.respondExisting(c, record, bodyHash)
+			return
+		}
+
+		// 3. Procesar capturando la respuesta
+		writer := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		// 4. Los errores 5xx son reintentables y los rechazos de RejectReplays no son la respuesta
+		// del handler: liberar la clave para la siguiente entrega
+		status := writer.Status()
+		if status >= http.StatusInternalServerError || c.GetBool(rejectedContextKey) {
+			if err := m.store.Release(ctx, key); err != nil && !errors.Is(err, idempotency.ErrNotFound) {
+				slog.ErrorContext(ctx, "Failed to release idempotency key", "idempotency_key", key, "error", err)
+			}
+			return
+		}
+
+		response := idempotency.Response{
+			StatusCode:  status,
+			ContentType: writer.Header().Get("Content-Type"),
+			Body:        writer.body.Bytes(),
+		}
+		if err := m.store.Complete(ctx, key, response); err != nil {
+			slog.ErrorContext(ctx, "Failed to record idempotency key", "idempotency_key", key, "error", err)
+		}
+	}
+}
+
+// respondExisting responde a una clave ya registrada sin volver a procesar
+func (m *IdempotencyMiddleware) respondExisting(c *gin.Context, record idempotency.Record, bodyHash string) {
+	switch {
+	case record.BodyHash != bodyHash:
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "IDEMPOTENCY_KEY_REUSED",
+			"message": "X-Idempotency-Key was already used with a different payload",
+		})
+	case record.State != idempotency.StateCompleted || record.Response == nil:
+		c.Header("Retry-After", "5")
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "IDEMPOTENCY_IN_PROGRESS",
+			"message": "A request with this X-Idempotency-Key is still in progress",
+		})
+	default:
+		// Reproducir la respuesta registrada originalmente
+		c.Header("X-Idempotent-Replayed", "true")
+		c.Data(record.Response.StatusCode, record.Response.ContentType, record.Response.Body)
+	}
+	c.Abort()
+}
+
+// responseRecorder copia el body de la respuesta mientras se escribe al cliente
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+)
+
+// ReplayCache recuerda las firmas ya aceptadas para rechazar peticiones repetidas
+type ReplayCache interface {
+	// Remember registra key hasta expiresAt. Retorna false si key ya estaba registrada.
+	Remember(key string, expiresAt time.Time) bool
+	// Forget elimina key, por ejemplo cuando la entrega falló y se espera un reintento
+	Forget(key string)
+}
+
+// replayCacheSweepInterval es la frecuencia mínima con que se eliminan las entradas expiradas
+const replayCacheSweepInterval = time.Minute
+
+// MemoryReplayCache es un ReplayCache en memoria para una sola instancia del receptor
+type MemoryReplayCache struct {
+	mu        sync.Mutex
+	entries   map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryReplayCache crea un cache de replay vacío
+func NewMemoryReplayCache() *MemoryReplayCache {
+	return &MemoryReplayCache{
+		entries: make(map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+// Remember implementa ReplayCache
+func (c *MemoryReplayCache) Remember(key string, expiresAt time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	c.sweep(now)
+
+	if existing, ok := c.entries[key]; ok && now.Before(existing) {
+		return false
+	}
+	c.entries[key] = expiresAt
+	return true
+}
+
+// Forget implementa ReplayCache
+func (c *MemoryReplayCache) Forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// sweep elimina las entradas expiradas como máximo una vez por replayCacheSweepInterval
+func (c *MemoryReplayCache) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < replayCacheSweepInterval {
+		return
+	}
+	for key, expiresAt := range c.entries {
+		if !now.Before(expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+	c.lastSweep = now
+}
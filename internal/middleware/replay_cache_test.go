@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryReplayCache(t *testing.T) {
+	start := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+
+	type step struct {
+		at       time.Duration // desde start
+		forget   bool
+		key      string
+		ttl      time.Duration
+		wantSeen bool // Remember retorna false
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "repeated key is rejected",
+			steps: []step{
+				{key: "a", ttl: time.Minute},
+				{at: 30 * time.Second, key: "a", ttl: time.Minute, wantSeen: true},
+				{at: 30 * time.Second, key: "b", ttl: time.Minute},
+			},
+		},
+		{
+			name: "expired key is accepted again",
+			steps: []step{
+				{key: "a", ttl: time.Minute},
+				{at: time.Minute, key: "a", ttl: time.Minute},
+			},
+		},
+		{
+			name: "forgotten key is accepted again",
+			steps: []step{
+				{key: "a", ttl: time.Hour},
+				{forget: true, key: "a"},
+				{at: time.Second, key: "a", ttl: time.Hour},
+			},
+		},
+		{
+			name: "sweep keeps keys that did not expire",
+			steps: []step{
+				{key: "short", ttl: time.Minute},
+				{key: "long", ttl: time.Hour},
+				{at: 2 * time.Minute, key: "other", ttl: time.Minute},
+				{at: 2 * time.Minute, key: "long", ttl: time.Hour, wantSeen: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewMemoryReplayCache()
+			var now time.Time
+			cache.now = func() time.Time { return now }
+
+			for i, s := range tt.steps {
+				now = start.Add(s.at)
+				if s.forget {
+					cache.Forget(s.key)
+					continue
+				}
+				if fresh := cache.Remember(s.key, now.Add(s.ttl)); fresh == s.wantSeen {
+					t.Errorf("step %d: Remember(%q) = %v, want %v", i, s.key, fresh, !s.wantSeen)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"strconv"
+)
+
+// ErrUnknownWebhook se retorna cuando no hay secretos configurados para el webhook recibido
+var ErrUnknownWebhook = errors.New("no secret configured for webhook")
+
+// WebhookRef identifica el origen de una petición para elegir sus secretos.
+// WebhookID viene de X-Webhook-ID o, si no está, del webhook_id del body.
+// ContractID viene del body (data.contract_id o bill.contract_id) y es 0 si no se pudo leer.
+type WebhookRef struct {
+	WebhookID  string
+	ContractID int
+}
+
+// SecretResolver obtiene los secretos con los que se puede firmar una petición
+type SecretResolver interface {
+	// Resolve retorna los secretos de ref o ErrUnknownWebhook si no hay ninguno configurado
+	Resolve(ctx context.Context, ref WebhookRef) ([]Secret, error)
+}
+
+// StaticSecretResolver usa los mismos secretos para todos los webhooks
+type StaticSecretResolver []Secret
+
+// Resolve implementa SecretResolver
+func (r StaticSecretResolver) Resolve(ctx context.Context, ref WebhookRef) ([]Secret, error) {
+	if len(r) == 0 {
+		return nil, ErrUnknownWebhook
+	}
+	return r, nil
+}
+
+// FileSecretResolver resuelve secretos por webhook_id, luego por contract_id y por último,
+// si está habilitado, con los secretos por defecto. Se crea con LoadSecretResolver.
+type FileSecretResolver struct {
+	defaults  []Secret
+	fallback  bool // Usar defaults para los webhooks sin secretos propios
+	webhooks  map[string][]Secret
+	contracts map[string][]Secret
+}
+
+// Resolve implementa SecretResolver
+func (r *FileSecretResolver) Resolve(ctx context.Context, ref WebhookRef) ([]Secret, error) {
+	if secrets, ok := r.webhooks[ref.WebhookID]; ok && ref.WebhookID != "" {
+		return secrets, nil
+	}
+	if secrets, ok := r.contracts[strconv.Itoa(ref.ContractID)]; ok && ref.ContractID != 0 {
+		return secrets, nil
+	}
+	if r.fallback && len(r.defaults) > 0 {
+		return r.defaults, nil
+	}
+	return nil, ErrUnknownWebhook
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// SecretIDContextKey es la clave del gin.Context donde se guarda el ID del secreto que validó la firma
+const SecretIDContextKey = "webhook_secret_id"
+
+// Secret es una clave de firma con una ventana de vigencia opcional.
+// Tener varias activas a la vez permite rotar WEBHOOK_SECRET_KEY sin interrupciones.
+type Secret struct {
+	ID        string    `json:"id"`
+	Key       string    `json:"key"`
+	NotBefore time.Time `json:"not_before,omitempty"` // Cero = sin límite inferior
+	NotAfter  time.Time `json:"not_after,omitempty"`  // Cero = sin límite superior
+}
+
+// ActiveAt indica si el secreto es válido en t
+func (s Secret) ActiveAt(t time.Time) bool {
+	if s.Key == "" {
+		return false
+	}
+	if !s.NotBefore.IsZero() && t.Before(s.NotBefore) {
+		return false
+	}
+	if !s.NotAfter.IsZero() && t.After(s.NotAfter) {
+		return false
+	}
+	return true
+}
+
+// secretsFile es el formato del archivo de secretos (WEBHOOK_SECRETS_FILE)
+type secretsFile struct {
+	Secrets   []Secret            `json:"secrets"`   // Secretos por defecto
+	Webhooks  map[string][]Secret `json:"webhooks"`  // Secretos por webhook_id
+	Contracts map[string][]Secret `json:"contracts"` // Secretos por contract_id
+	// FallbackToDefault usa los secretos por defecto para los webhooks y contratos que no
+	// están en Webhooks ni en Contracts
+	FallbackToDefault bool `json:"fallback_to_default"`
+}
+
+// LoadSecretResolver carga un FileSecretResolver desde un archivo JSON con el formato:
+//
+//	{
+//	  "secrets": [
+//	    {"id": "2025-10", "key": "old-key", "not_after": "2025-11-15T00:00:00Z"},
+//	    {"id": "2025-11", "key": "new-key", "not_before": "2025-11-01T00:00:00Z"}
+//	  ],
+//	  "webhooks": {
+//	    "12345": [{"id": "wh-12345", "key": "webhook-key"}]
+//	  },
+//	  "contracts": {
+//	    "1001": [{"id": "contract-1001", "key": "contract-key"}]
+//	  },
+//	  "fallback_to_default": false
+//	}
+//
+// Todas las secciones son opcionales, pero debe haber al menos un secreto. Si el archivo solo
+// tiene "secrets" se usan para todos los webhooks; si tiene secretos por webhook o por contrato
+// los demás webhooks se rechazan, salvo que "fallback_to_default" sea true.
+func LoadSecretResolver(path string) (*FileSecretResolver, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+
+	var file secretsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets file: %w", err)
+	}
+
+	if len(file.Secrets) == 0 && len(file.Webhooks) == 0 && len(file.Contracts) == 0 {
+		return nil, errors.New("at least one secret is required")
+	}
+	if file.FallbackToDefault && len(file.Secrets) == 0 {
+		return nil, errors.New("fallback_to_default requires default secrets")
+	}
+	if len(file.Secrets) > 0 {
+		if err := validateSecrets(file.Secrets); err != nil {
+			return nil, err
+		}
+	}
+	for webhookID, secrets := range file.Webhooks {
+		if err := validateSecrets(secrets); err != nil {
+			return nil, fmt.Errorf("webhook %s: %w", webhookID, err)
+		}
+	}
+	for contractID, secrets := range file.Contracts {
+		if err := validateSecrets(secrets); err != nil {
+			return nil, fmt.Errorf("contract %s: %w", contractID, err)
+		}
+	}
+
+	return &FileSecretResolver{
+		defaults:  file.Secrets,
+		fallback:  file.FallbackToDefault || (len(file.Webhooks) == 0 && len(file.Contracts) == 0),
+		webhooks:  file.Webhooks,
+		contracts: file.Contracts,
+	}, nil
+}
+
+// validateSecrets verifica que los secretos tengan ID único, clave y una ventana coherente
+func validateSecrets(secrets []Secret) error {
+	if len(secrets) == 0 {
+		return errors.New("at least one secret is required")
+	}
+
+	seen := make(map[string]bool, len(secrets))
+	for i, secret := range secrets {
+		if secret.ID == "" {
+			return fmt.Errorf("secret %d: id is required", i)
+		}
+		if seen[secret.ID] {
+			return fmt.Errorf("secret %q: duplicated id", secret.ID)
+		}
+		seen[secret.ID] = true
+
+		if secret.Key == "" {
+			return fmt.Errorf("secret %q: key is required", secret.ID)
+		}
+		if !secret.NotBefore.IsZero() && !secret.NotAfter.IsZero() && !secret.NotBefore.Before(secret.NotAfter) {
+			return fmt.Errorf("secret %q: not_before must be before not_after", secret.ID)
+		}
+	}
+	return nil
+}
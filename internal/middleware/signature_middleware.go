@@ -2,122 +2,382 @@ package middleware
 
 import (
 	"bytes"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"io"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
+	"webhook_receiver/internal/logging"
+	"webhook_receiver/internal/signature"
+	"webhook_receiver/internal/tracing"
+
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultTimestampTolerance es la diferencia máxima por defecto entre X-Webhook-Timestamp y el reloj local
+const DefaultTimestampTolerance = 5 * time.Minute
+
+// DefaultLegacyReplayWindow es el tiempo por defecto que se recuerda una firma v0. v0 no
+// autentica el timestamp, así que una firma capturada se puede reenviar con un timestamp
+// nuevo en cualquier momento: se recuerda por mucho más que la tolerancia.
+const DefaultLegacyReplayWindow = 24 * time.Hour
+
+// DefaultSignatureSchemes son los esquemas aceptados por defecto: legacy y v1 durante la migración
+var DefaultSignatureSchemes = []string{signature.SchemeLegacy, signature.SchemeV1}
+
+// Motivos de rechazo de la verificación de firma, para métricas y observadores
+const (
+	FailureMissingSignature   = "missing_signature"
+	FailureMissingTimestamp   = "missing_timestamp"
+	FailureBadTimestamp       = "bad_timestamp"
+	FailureStaleTimestamp     = "stale_timestamp"
+	FailureFutureTimestamp    = "future_timestamp"
+	FailureUnreadableBody     = "unreadable_body"
+	FailureUnsupportedScheme  = "unsupported_scheme"
+	FailureWebhookIDMismatch  = "webhook_id_mismatch"
+	FailureUnknownWebhook     = "unknown_webhook"
+	FailureSecretsUnavailable = "secrets_unavailable"
+	FailureInvalidSignature   = "invalid_signature"
+	FailureReplay             = "replay"
 )
 
+// replayKeysContextKey es la clave del contexto de Gin con la que VerifySignature le pasa a
+// RejectReplays las claves de replay de la petición
+const replayKeysContextKey = "webhook_replay_keys"
+
+// rejectedContextKey marca en el contexto de Gin las peticiones que la verificación de firma o
+// el control de replay rechazaron, para que IdempotencyMiddleware no registre esa respuesta
+const rejectedContextKey = "webhook_signature_rejected"
+
+// replayKey es una clave del cache de replay y hasta cuándo se recuerda
+type replayKey struct {
+	key       string
+	expiresAt time.Time
+}
+
 // WebhookSignatureMiddleware middleware para verificar la firma de webhooks
 type WebhookSignatureMiddleware struct {
-	secretKey string
+	resolver           SecretResolver
+	tolerance          time.Duration
+	legacyReplayWindow time.Duration
+	replayCache        ReplayCache
+	schemes            []string
+	onFailure          func(reason string)
 }
 
-// NewWebhookSignatureMiddleware crea una nueva instancia del middleware
-func NewWebhookSignatureMiddleware(secretKey string) *WebhookSignatureMiddleware {
-	return &WebhookSignatureMiddleware{
-		secretKey: secretKey,
+// SignatureOption configura opciones adicionales del middleware
+type SignatureOption func(*WebhookSignatureMiddleware)
+
+// WithTimestampTolerance define la diferencia máxima aceptada, hacia el pasado o el futuro,
+// entre X-Webhook-Timestamp y el reloj local
+func WithTimestampTolerance(tolerance time.Duration) SignatureOption {
+	return func(m *WebhookSignatureMiddleware) {
+		if tolerance > 0 {
+			m.tolerance = tolerance
+		}
 	}
 }
 
-// VerifySignature verifica la firma del webhook
-func (m *WebhookSignatureMiddleware) VerifySignature() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// 1. Obtener headers necesarios
-		signature := c.GetHeader("X-Webhook-Signature")
-		if signature == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error":   "UNAUTHORIZED",
-				"message": "Missing X-Webhook-Signature header",
-			})
-			c.Abort()
-			return
+// WithLegacyReplayWindow define cuánto tiempo se recuerda una firma v0 para rechazar su
+// reenvío. Como v0 no firma el timestamp, pasada esta ventana una firma capturada se puede
+// volver a usar: para una protección completa acepta solo v1.
+func WithLegacyReplayWindow(window time.Duration) SignatureOption {
+	return func(m *WebhookSignatureMiddleware) {
+		if window > 0 {
+			m.legacyReplayWindow = window
 		}
+	}
+}
 
-		timestamp := c.GetHeader("X-Webhook-Timestamp")
-		if timestamp == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error":   "UNAUTHORIZED",
-				"message": "Missing X-Webhook-Timestamp header",
-			})
-			c.Abort()
-			return
+// WithReplayCache reemplaza el cache de replay en memoria por defecto
+func WithReplayCache(cache ReplayCache) SignatureOption {
+	return func(m *WebhookSignatureMiddleware) {
+		if cache != nil {
+			m.replayCache = cache
 		}
+	}
+}
 
-		// 2. Validar el timestamp (no más de 5 minutos de antigüedad)
-		webhookTime, err := time.Parse(time.RFC3339, timestamp)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error":   "UNAUTHORIZED",
-				"message": "Invalid timestamp format",
-			})
-			c.Abort()
-			return
+// WithSignatureSchemes define qué esquemas de firma (signature.SchemeLegacy, signature.SchemeV1) se aceptan
+func WithSignatureSchemes(schemes ...string) SignatureOption {
+	return func(m *WebhookSignatureMiddleware) {
+		if len(schemes) > 0 {
+			m.schemes = schemes
 		}
+	}
+}
 
-		// Verificar que el webhook no sea muy antiguo (5 minutos)
-		if time.Since(webhookTime) > 5*time.Minute {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error":   "UNAUTHORIZED",
-				"message": "Webhook timestamp too old",
-			})
-			c.Abort()
+// WithFailureObserver llama a observe con el motivo (Failure*) de cada petición rechazada
+func WithFailureObserver(observe func(reason string)) SignatureOption {
+	return func(m *WebhookSignatureMiddleware) {
+		m.onFailure = observe
+	}
+}
+
+// NewWebhookSignatureMiddleware crea una nueva instancia del middleware.
+// Se acepta cualquier firma generada con uno de los secretos vigentes que retorne resolver.
+func NewWebhookSignatureMiddleware(resolver SecretResolver, opts ...SignatureOption) *WebhookSignatureMiddleware {
+	m := &WebhookSignatureMiddleware{
+		resolver:           resolver,
+		tolerance:          DefaultTimestampTolerance,
+		legacyReplayWindow: DefaultLegacyReplayWindow,
+		replayCache:        NewMemoryReplayCache(),
+		schemes:            DefaultSignatureSchemes,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// VerifySignature verifica la firma del webhook. Las peticiones repetidas se rechazan
+// después, en RejectReplays.
+func (m *WebhookSignatureMiddleware) VerifySignature() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// La verificación tiene su propio span; el handler continúa con el contexto original
+		parent := c.Request.Context()
+		ctx, span := tracing.Start(parent, "webhook.verify_signature")
+		c.Request = c.Request.WithContext(ctx)
+		replayKeys, ok := m.verify(c)
+		span.End()
+		c.Request = c.Request.WithContext(parent)
+		if !ok {
 			return
 		}
 
-		// 3. Leer el payload completo
-		payload, err := io.ReadAll(c.Request.Body)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error":   "BAD_REQUEST",
-				"message": "Failed to read request body",
-			})
-			c.Abort()
+		// Los logs siguientes de la petición, incluido el de acceso, llevan secret_id
+		secretID := slog.String("secret_id", c.GetString(SecretIDContextKey))
+		c.Request = c.Request.WithContext(logging.With(parent, secretID))
+
+		c.Set(replayKeysContextKey, replayKeys)
+		c.Next()
+	}
+}
+
+// RejectReplays rechaza las peticiones cuya firma ya fue aceptada: las firmas v1 dentro de la
+// ventana de tolerancia (pasada esa ventana el timestamp ya no es aceptado) y las v0 durante
+// la ventana de legacy, porque no autentican el timestamp. Va después de
+// VerifySignature y de IdempotencyMiddleware.Handle, para que un reenvío idéntico con la
+// misma X-Idempotency-Key reciba la respuesta registrada en lugar de un rechazo por replay.
+// Sus rechazos nunca se registran como respuesta de la clave: reject marca la petición y
+// Handle libera la clave.
+func (m *WebhookSignatureMiddleware) RejectReplays() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		replayKeys, _ := c.Value(replayKeysContextKey).([]replayKey)
+		if !m.rememberAll(replayKeys) {
+			m.reject(c, FailureReplay, http.StatusUnauthorized, "UNAUTHORIZED", "Webhook replay detected")
 			return
 		}
 
-		// 4. Verificar la firma
-		isValid, err := m.verifySignature(m.secretKey, payload, signature)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "INTERNAL_ERROR",
-				"message": "Failed to verify signature",
-			})
-			c.Abort()
-			return
+		// Continuar con el siguiente handler
+		c.Next()
+
+		// Si el procesamiento falló con un error reintentable, permitir que
+		// el emisor reintente con la misma firma
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			for _, key := range replayKeys {
+				m.replayCache.Forget(key.key)
+			}
 		}
+	}
+}
 
-		if !isValid {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error":   "UNAUTHORIZED",
-				"message": "Invalid signature",
-			})
-			c.Abort()
-			return
+// verify ejecuta las validaciones de la firma. Si la petición es válida deja el body listo
+// para el handler y retorna sus claves de replay; si no, responde el rechazo y retorna false.
+func (m *WebhookSignatureMiddleware) verify(c *gin.Context) ([]replayKey, bool) {
+	// 1. Obtener headers necesarios
+	signatureHeader := c.GetHeader("X-Webhook-Signature")
+	if signatureHeader == "" {
+		m.reject(c, FailureMissingSignature, http.StatusUnauthorized, "UNAUTHORIZED", "Missing X-Webhook-Signature header")
+		return nil, false
+	}
+
+	timestamp := c.GetHeader("X-Webhook-Timestamp")
+	if timestamp == "" {
+		m.reject(c, FailureMissingTimestamp, http.StatusUnauthorized, "UNAUTHORIZED", "Missing X-Webhook-Timestamp header")
+		return nil, false
+	}
+
+	// 2. Validar el timestamp (dentro de la tolerancia configurada)
+	webhookTime, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		m.reject(c, FailureBadTimestamp, http.StatusUnauthorized, "UNAUTHORIZED", "Invalid timestamp format")
+		return nil, false
+	}
+
+	// Verificar que el webhook no sea muy antiguo
+	age := time.Since(webhookTime)
+	if age > m.tolerance {
+		m.reject(c, FailureStaleTimestamp, http.StatusUnauthorized, "UNAUTHORIZED", "Webhook timestamp too old")
+		return nil, false
+	}
+
+	// Verificar que el webhook no venga del futuro (desfase de reloj simétrico)
+	if -age > m.tolerance {
+		m.reject(c, FailureFutureTimestamp, http.StatusUnauthorized, "UNAUTHORIZED", "Webhook timestamp too far in the future")
+		return nil, false
+	}
+
+	// 3. Leer el payload completo
+	payload, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		m.reject(c, FailureUnreadableBody, http.StatusBadRequest, "BAD_REQUEST", "Failed to read request body")
+		return nil, false
+	}
+
+	// 4. Verificar la firma con los esquemas aceptados
+	signatures := signature.ParseHeader(signatureHeader)
+	if !signature.HasAccepted(signatures, m.schemes) {
+		m.reject(c, FailureUnsupportedScheme, http.StatusUnauthorized, "UNAUTHORIZED", "No signature with an accepted scheme")
+		return nil, false
+	}
+
+	// 5. Obtener los secretos del webhook (X-Webhook-ID o webhook_id del body)
+	ref, err := webhookRef(c.GetHeader("X-Webhook-ID"), payload)
+	if err != nil {
+		m.reject(c, FailureWebhookIDMismatch, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		return nil, false
+	}
+
+	secrets, err := m.resolver.Resolve(c.Request.Context(), ref)
+	if errors.Is(err, ErrUnknownWebhook) {
+		m.reject(c, FailureUnknownWebhook, http.StatusUnauthorized, "UNKNOWN_WEBHOOK", "No secret configured for webhook "+ref.WebhookID)
+		return nil, false
+	}
+	if err != nil {
+		m.reject(c, FailureSecretsUnavailable, http.StatusServiceUnavailable, "SECRETS_UNAVAILABLE", "Failed to resolve webhook secrets")
+		return nil, false
+	}
+
+	secretID, isValid := m.verifySignature(secrets, time.Now(), timestamp, payload, signatures)
+	if !isValid {
+		m.reject(c, FailureInvalidSignature, http.StatusUnauthorized, "UNAUTHORIZED", "Invalid signature")
+		return nil, false
+	}
+
+	// Registrar qué secreto validó la firma para saber cuándo se puede retirar uno viejo
+	c.Set(SecretIDContextKey, secretID)
+	slog.InfoContext(c.Request.Context(), "Webhook signature verified", "webhook_id", ref.WebhookID, "secret_id", secretID)
+	span := trace.SpanFromContext(c.Request.Context())
+	span.SetAttributes(attribute.String("webhook.secret_id", secretID))
+	if id, err := strconv.Atoi(ref.WebhookID); err == nil {
+		span.SetAttributes(tracing.AttrWebhookID.Int(id))
+	}
+
+	// 6. Restaurar el body para que el handler pueda leerlo
+	c.Request.Body = io.NopCloser(bytes.NewReader(payload))
+	return m.replayKeys(signatures, timestamp, webhookTime), true
+}
+
+// reject responde el rechazo de la verificación y notifica su motivo al observador
+func (m *WebhookSignatureMiddleware) reject(c *gin.Context, reason string, status int, code, message string) {
+	if m.onFailure != nil {
+		m.onFailure(reason)
+	}
+	attrs := []any{"reason", reason, "status", status, "message", message}
+	if webhookID := c.GetHeader("X-Webhook-ID"); webhookID != "" {
+		attrs = append(attrs, "webhook_id", webhookID)
+	}
+	slog.WarnContext(c.Request.Context(), "Webhook signature rejected", attrs...)
+	span := trace.SpanFromContext(c.Request.Context())
+	span.SetAttributes(attribute.String("webhook.signature.failure", reason))
+	span.SetStatus(codes.Error, message)
+	c.Set(rejectedContextKey, true)
+	c.JSON(status, gin.H{
+		"error":   code,
+		"message": message,
+	})
+	c.Abort()
+}
+
+// replayKeys retorna una clave de replay por cada firma de un esquema aceptado. Se usan
+// todas para que quitar una de las firmas del header no permita repetir la petición. La
+// firma v1 cubre el timestamp y se recuerda mientras ese timestamp sea aceptado; la v0 no lo
+// cubre, así que su clave es solo la firma y se recuerda durante la ventana de legacy.
+func (m *WebhookSignatureMiddleware) replayKeys(signatures []signature.Signature, timestamp string, webhookTime time.Time) []replayKey {
+	keys := make([]replayKey, 0, len(signatures))
+	for _, sig := range signatures {
+		if !signature.HasAccepted([]signature.Signature{sig}, m.schemes) {
+			continue
+		}
+		if sig.Scheme == signature.SchemeLegacy {
+			keys = append(keys, replayKey{key: sig.Scheme + "=" + sig.Value, expiresAt: time.Now().Add(m.legacyReplayWindow)})
+			continue
 		}
+		keys = append(keys, replayKey{key: sig.Scheme + "=" + sig.Value + "|" + timestamp, expiresAt: webhookTime.Add(m.tolerance)})
+	}
+	return keys
+}
+
+// rememberAll registra todas las claves y retorna false si alguna ya había sido vista
+func (m *WebhookSignatureMiddleware) rememberAll(keys []replayKey) bool {
+	fresh := true
+	for _, key := range keys {
+		if !m.replayCache.Remember(key.key, key.expiresAt) {
+			fresh = false
+		}
+	}
+	return fresh
+}
 
-		// 5. Restaurar el body para que el handler pueda leerlo
-		c.Request.Body = io.NopCloser(bytes.NewReader(payload))
+// errWebhookIDMismatch se retorna cuando X-Webhook-ID y el webhook_id del body no coinciden
+var errWebhookIDMismatch = errors.New("X-Webhook-ID does not match payload webhook_id")
 
-		// Continuar con el siguiente handler
-		c.Next()
+// webhookRef identifica el webhook a partir del header X-Webhook-ID y del body. Si ambos
+// traen webhook_id deben coincidir, para que no se pueda elegir el secreto de otro webhook.
+func webhookRef(headerID string, payload []byte) (WebhookRef, error) {
+	var body struct {
+		WebhookID *int `json:"webhook_id"`
+		Data      struct {
+			ContractID int `json:"contract_id"`
+		} `json:"data"`
+		Bill struct {
+			ContractID int `json:"contract_id"`
+		} `json:"bill"`
 	}
+	// Un body que no es JSON se resuelve solo con el header; el handler lo rechazará después
+	_ = json.Unmarshal(payload, &body)
+
+	ref := WebhookRef{WebhookID: headerID, ContractID: body.Data.ContractID}
+	if ref.ContractID == 0 {
+		ref.ContractID = body.Bill.ContractID
+	}
+
+	if body.WebhookID != nil {
+		bodyID := strconv.Itoa(*body.WebhookID)
+		switch {
+		case ref.WebhookID == "":
+			ref.WebhookID = bodyID
+		case ref.WebhookID != bodyID:
+			return ref, errWebhookIDMismatch
+		}
+	}
+	return ref, nil
 }
 
-// verifySignature verifica la firma HMAC del payload
-func (m *WebhookSignatureMiddleware) verifySignature(secretKey string, payload []byte, receivedSignature string) (bool, error) {
-	// Generar la firma esperada
-	mac := hmac.New(sha256.New, []byte(secretKey))
-	mac.Write(payload)
-	expectedSignature := hex.EncodeToString(mac.Sum(nil))
+// verifySignature verifica la firma HMAC del payload contra todos los secretos vigentes en now
+// y retorna el ID del secreto que coincidió. Se prueban todos los secretos, sin cortar al
+// primer acierto, para que el tiempo de respuesta no revele cuál de ellos coincidió.
+func (m *WebhookSignatureMiddleware) verifySignature(secrets []Secret, now time.Time, timestamp string, payload []byte, signatures []signature.Signature) (string, bool) {
+	matchedID := ""
+	matched := false
+	for _, secret := range secrets {
+		if !secret.ActiveAt(now) {
+			continue
+		}
 
-	// Comparar las firmas usando una función de tiempo constante
-	return hmac.Equal(
-		[]byte(receivedSignature),
-		[]byte(expectedSignature),
-	), nil
+		// Las firmas se comparan usando una función de tiempo constante
+		valid := signature.Verify([]byte(secret.Key), timestamp, payload, signatures, m.schemes)
+		if valid && !matched {
+			matchedID = secret.ID
+			matched = true
+		}
+	}
+	return matchedID, matched
 }
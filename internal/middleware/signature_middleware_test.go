@@ -0,0 +1,326 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"webhook_receiver/internal/idempotency"
+	"webhook_receiver/internal/logging"
+	"webhook_receiver/internal/signature"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	testSecret = "secret_key"
+	testBody   = `{"webhook_id":12345,"data_type":"consumption","data":{"contract_id":1001}}`
+)
+
+// resolverFunc adapta una función a SecretResolver
+type resolverFunc func(ctx context.Context, ref WebhookRef) ([]Secret, error)
+
+func (f resolverFunc) Resolve(ctx context.Context, ref WebhookRef) ([]Secret, error) {
+	return f(ctx, ref)
+}
+
+// delivery es una petición firmada al endpoint de prueba
+type delivery struct {
+	body      string
+	schemes   []string // esquemas con los que se firma; vacío = v1
+	signature string   // reemplaza la firma calculada
+	offset    time.Duration
+	timestamp string // reemplaza el timestamp calculado
+	webhookID string
+	idKey     string
+}
+
+func (d delivery) request(t *testing.T) *http.Request {
+	t.Helper()
+	body := d.body
+	if body == "" {
+		body = testBody
+	}
+	timestamp := d.timestamp
+	if timestamp == "" {
+		timestamp = time.Now().Add(d.offset).UTC().Format(time.RFC3339)
+	}
+	schemes := d.schemes
+	if len(schemes) == 0 {
+		schemes = []string{signature.SchemeV1}
+	}
+	sig, err := signature.Header([]byte(testSecret), timestamp, []byte(body), schemes...)
+	if err != nil {
+		t.Fatalf("signature.Header() error = %v", err)
+	}
+	if d.signature != "" {
+		sig = d.signature
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	if sig != "-" {
+		req.Header.Set("X-Webhook-Signature", sig)
+	}
+	if timestamp != "-" {
+		req.Header.Set("X-Webhook-Timestamp", timestamp)
+	}
+	if d.webhookID != "" {
+		req.Header.Set("X-Webhook-ID", d.webhookID)
+	}
+	if d.idKey != "" {
+		req.Header.Set("X-Idempotency-Key", d.idKey)
+	}
+	return req
+}
+
+// newTestEngine arma la cadena de middlewares como el router: VerifySignature, idempotencia
+// y RejectReplays. El handler responde los status de statuses en orden (200 al agotarse).
+func newTestEngine(resolver SecretResolver, failures *[]string, statuses ...int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	signatureMiddleware := NewWebhookSignatureMiddleware(resolver,
+		WithFailureObserver(func(reason string) { *failures = append(*failures, reason) }),
+	)
+	idempotencyMiddleware := NewIdempotencyMiddleware(idempotency.NewMemoryStore(100, idempotency.Options{}))
+
+	engine := gin.New()
+	engine.POST("/webhook",
+		signatureMiddleware.VerifySignature(),
+		idempotencyMiddleware.Handle(),
+		signatureMiddleware.RejectReplays(),
+		func(c *gin.Context) {
+			status := http.StatusOK
+			if len(statuses) > 0 {
+				status, statuses = statuses[0], statuses[1:]
+			}
+			c.JSON(status, gin.H{"status": status})
+		},
+	)
+	return engine
+}
+
+func TestVerifySignature(t *testing.T) {
+	static := StaticSecretResolver{{ID: "default", Key: testSecret}}
+
+	tests := []struct {
+		name        string
+		resolver    SecretResolver
+		delivery    delivery
+		wantStatus  int
+		wantFailure string
+	}{
+		{name: "valid v1", delivery: delivery{}, wantStatus: http.StatusOK},
+		{name: "valid v0", delivery: delivery{schemes: []string{signature.SchemeLegacy}}, wantStatus: http.StatusOK},
+		{name: "missing signature", delivery: delivery{signature: "-"}, wantStatus: http.StatusUnauthorized, wantFailure: FailureMissingSignature},
+		{name: "missing timestamp", delivery: delivery{timestamp: "-"}, wantStatus: http.StatusUnauthorized, wantFailure: FailureMissingTimestamp},
+		{name: "bad timestamp", delivery: delivery{timestamp: "yesterday"}, wantStatus: http.StatusUnauthorized, wantFailure: FailureBadTimestamp},
+		{name: "stale timestamp", delivery: delivery{offset: -10 * time.Minute}, wantStatus: http.StatusUnauthorized, wantFailure: FailureStaleTimestamp},
+		{name: "future timestamp", delivery: delivery{offset: 10 * time.Minute}, wantStatus: http.StatusUnauthorized, wantFailure: FailureFutureTimestamp},
+		{name: "unsupported scheme", delivery: delivery{signature: "v9=abc"}, wantStatus: http.StatusUnauthorized, wantFailure: FailureUnsupportedScheme},
+		{name: "invalid signature", delivery: delivery{signature: "v1=deadbeef"}, wantStatus: http.StatusUnauthorized, wantFailure: FailureInvalidSignature},
+		{name: "webhook id mismatch", delivery: delivery{webhookID: "99999"}, wantStatus: http.StatusUnauthorized, wantFailure: FailureWebhookIDMismatch},
+		{name: "matching webhook id", delivery: delivery{webhookID: "12345"}, wantStatus: http.StatusOK},
+		{
+			name:        "unknown webhook",
+			resolver:    StaticSecretResolver{},
+			wantStatus:  http.StatusUnauthorized,
+			wantFailure: FailureUnknownWebhook,
+		},
+		{
+			name: "secrets unavailable",
+			resolver: resolverFunc(func(context.Context, WebhookRef) ([]Secret, error) {
+				return nil, errors.New("vault unavailable")
+			}),
+			wantStatus:  http.StatusServiceUnavailable,
+			wantFailure: FailureSecretsUnavailable,
+		},
+		{
+			name: "expired secret",
+			resolver: StaticSecretResolver{
+				{ID: "old", Key: testSecret, NotAfter: time.Now().Add(-time.Hour)},
+				{ID: "new", Key: "new_key"},
+			},
+			wantStatus:  http.StatusUnauthorized,
+			wantFailure: FailureInvalidSignature,
+		},
+		{
+			name: "resolver receives webhook and contract",
+			resolver: resolverFunc(func(_ context.Context, ref WebhookRef) ([]Secret, error) {
+				if ref.WebhookID != "12345" || ref.ContractID != 1001 {
+					return nil, ErrUnknownWebhook
+				}
+				return []Secret{{ID: "wh-12345", Key: testSecret}}, nil
+			}),
+			wantStatus: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := tt.resolver
+			if resolver == nil {
+				resolver = static
+			}
+			var failures []string
+			engine := newTestEngine(resolver, &failures)
+
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, tt.delivery.request(t))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantFailure == "" && len(failures) > 0 {
+				t.Errorf("failures = %v, want none", failures)
+			}
+			if tt.wantFailure != "" && (len(failures) != 1 || failures[0] != tt.wantFailure) {
+				t.Errorf("failures = %v, want [%s]", failures, tt.wantFailure)
+			}
+		})
+	}
+}
+
+func TestRejectReplays(t *testing.T) {
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+	later := time.Now().Add(30 * time.Second).UTC().Format(time.RFC3339)
+	legacy := []string{signature.SchemeLegacy}
+
+	tests := []struct {
+		name         string
+		statuses     []int // respuestas del handler
+		deliveries   []delivery
+		wantStatuses []int
+		wantReplayed []bool // X-Idempotent-Replayed
+	}{
+		{
+			name:         "v1 resend is a replay",
+			deliveries:   []delivery{{timestamp: timestamp}, {timestamp: timestamp}},
+			wantStatuses: []int{http.StatusOK, http.StatusUnauthorized},
+		},
+		{
+			name:         "v1 with a new timestamp is a new delivery",
+			deliveries:   []delivery{{timestamp: timestamp}, {timestamp: later}},
+			wantStatuses: []int{http.StatusOK, http.StatusOK},
+		},
+		{
+			name:         "v0 resend with a new timestamp is a replay",
+			deliveries:   []delivery{{schemes: legacy, timestamp: timestamp}, {schemes: legacy, timestamp: later}},
+			wantStatuses: []int{http.StatusOK, http.StatusUnauthorized},
+		},
+		{
+			name: "dropping one of the signatures is a replay",
+			deliveries: []delivery{
+				{schemes: []string{signature.SchemeLegacy, signature.SchemeV1}, timestamp: timestamp},
+				{schemes: legacy, timestamp: later},
+			},
+			wantStatuses: []int{http.StatusOK, http.StatusUnauthorized},
+		},
+		{
+			name:         "identical resend with idempotency key gets the recorded response",
+			deliveries:   []delivery{{timestamp: timestamp, idKey: "k1"}, {timestamp: timestamp, idKey: "k1"}},
+			wantStatuses: []int{http.StatusOK, http.StatusOK},
+			wantReplayed: []bool{false, true},
+		},
+		{
+			name: "same idempotency key with a replayed signature is rejected, not recorded",
+			deliveries: []delivery{
+				{timestamp: timestamp},
+				{timestamp: timestamp, idKey: "k1"},
+				{timestamp: timestamp, idKey: "k1"},
+			},
+			wantStatuses: []int{http.StatusOK, http.StatusUnauthorized, http.StatusUnauthorized},
+			wantReplayed: []bool{false, false, false},
+		},
+		{
+			name: "replayed signature does not poison a new idempotency key",
+			deliveries: []delivery{
+				{timestamp: timestamp, idKey: "k1"},
+				{timestamp: timestamp, idKey: "k2"},
+				{timestamp: later, idKey: "k2"},
+			},
+			wantStatuses: []int{http.StatusOK, http.StatusUnauthorized, http.StatusOK},
+			wantReplayed: []bool{false, false, false},
+		},
+		{
+			name:         "server error lets the sender retry the same signature",
+			statuses:     []int{http.StatusServiceUnavailable},
+			deliveries:   []delivery{{timestamp: timestamp}, {timestamp: timestamp}, {timestamp: timestamp}},
+			wantStatuses: []int{http.StatusServiceUnavailable, http.StatusOK, http.StatusUnauthorized},
+		},
+		{
+			name:         "client error keeps the signature",
+			statuses:     []int{http.StatusUnprocessableEntity},
+			deliveries:   []delivery{{timestamp: timestamp}, {timestamp: timestamp}},
+			wantStatuses: []int{http.StatusUnprocessableEntity, http.StatusUnauthorized},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var failures []string
+			engine := newTestEngine(StaticSecretResolver{{ID: "default", Key: testSecret}}, &failures, tt.statuses...)
+
+			for i, d := range tt.deliveries {
+				w := httptest.NewRecorder()
+				engine.ServeHTTP(w, d.request(t))
+				if w.Code != tt.wantStatuses[i] {
+					t.Fatalf("delivery %d: status = %d, want %d (%s)", i+1, w.Code, tt.wantStatuses[i], w.Body.String())
+				}
+				if w.Code == http.StatusUnauthorized && failures[len(failures)-1] != FailureReplay {
+					t.Errorf("delivery %d: failure = %s, want %s", i+1, failures[len(failures)-1], FailureReplay)
+				}
+				if tt.wantReplayed != nil {
+					if replayed := w.Header().Get("X-Idempotent-Replayed") == "true"; replayed != tt.wantReplayed[i] {
+						t.Errorf("delivery %d: replayed = %v, want %v", i+1, replayed, tt.wantReplayed[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestVerifySignatureLogsSecretID(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(logging.NewHandler(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	gin.SetMode(gin.TestMode)
+	signatureMiddleware := NewWebhookSignatureMiddleware(StaticSecretResolver{{ID: "current", Key: testSecret}})
+	engine := gin.New()
+	engine.Use(logging.Middleware())
+	engine.POST("/webhook", signatureMiddleware.VerifySignature(), func(c *gin.Context) {
+		slog.InfoContext(c.Request.Context(), "Webhook processed")
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, delivery{}.request(t))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%s)", w.Code, w.Body.String())
+	}
+
+	records := make(map[string]map[string]any)
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var record map[string]any
+		if err := json.Unmarshal(line, &record); err != nil {
+			t.Fatalf("log line is not JSON: %s", line)
+		}
+		records[record["msg"].(string)] = record
+	}
+	// El log de la verificación, los del handler y el de acceso llevan el secreto que validó la firma
+	for _, msg := range []string{"Webhook signature verified", "Webhook processed", "HTTP request"} {
+		record, ok := records[msg]
+		if !ok {
+			t.Errorf("no %q log at info level in %s", msg, buf.String())
+			continue
+		}
+		if record["secret_id"] != "current" || record["request_id"] == nil {
+			t.Errorf("%q log = %v, want secret_id current and a request_id", msg, record)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"webhook_receiver/internal/dto"
+)
+
+// decodeConsumption decodifica webhooks de tipo CONSUMO
+func decodeConsumption(body []byte) (Event, error) {
+	var payload dto.WebhookPayload
+
+	// Parsear el payload específico de consumo
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return Event{}, fmt.Errorf("%w: failed to parse consumption payload: %w", ErrInvalidPayload, decodeFieldError(err))
+	}
+
+	// Validar la semántica del payload
+	if err := payload.Validate(); err != nil {
+		return Event{}, fmt.Errorf("%w: %w", ErrInvalidPayload, err)
+	}
+
+	return Event{
+		WebhookID:  payload.WebhookID,
+		ContractID: payload.Data.ContractID,
+		Payload:    payload,
+	}, nil
+}
+
+// decodeBill decodifica webhooks de tipo FACTURAS
+func decodeBill(body []byte) (Event, error) {
+	var payload dto.BillWebhookPayload
+
+	// Parsear el payload específico de facturas
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return Event{}, fmt.Errorf("%w: failed to parse bills payload: %w", ErrInvalidPayload, decodeFieldError(err))
+	}
+
+	// Validar el payload según su trigger_type
+	if err := payload.Validate(); err != nil {
+		return Event{}, fmt.Errorf("%w: %w", ErrInvalidPayload, err)
+	}
+
+	return Event{
+		TriggerType: payload.TriggerType,
+		WebhookID:   payload.WebhookID,
+		ContractID:  payload.Bill.ContractID,
+		Payload:     payload,
+	}, nil
+}
+
+// decodeFieldError convierte los errores de decodificación que identifican un campo
+// en un *dto.ValidationError, para reportarlos igual que los errores de validación
+func decodeFieldError(err error) error {
+	var consumptionErr *dto.ConsumptionDecodeError
+	if errors.As(err, &consumptionErr) {
+		field := "data.consumption"
+		if errors.Is(err, dto.ErrUnknownGroupBy) {
+			field = "group_by"
+		}
+		return &dto.ValidationError{Errors: []dto.FieldError{{Field: field, Message: consumptionErr.Error()}}}
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return &dto.ValidationError{Errors: []dto.FieldError{{
+			Field:   typeErr.Field,
+			Message: fmt.Sprintf("must be %s, got %s", typeErr.Type, typeErr.Value),
+		}}}
+	}
+	return err
+}
+
+// consumptionHandler adapta Processor.OnConsumption a HandleFunc
+func consumptionHandler(p Processor) HandleFunc {
+	return func(ctx context.Context, event Event) (string, error) {
+		payload, ok := event.Payload.(dto.WebhookPayload)
+		if !ok {
+			return "", fmt.Errorf("%w: unexpected payload type %T for consumption", ErrInvalidPayload, event.Payload)
+		}
+
+		if err := p.OnConsumption(ctx, payload); err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("Consumption webhook processed successfully for contract %d (%s): %d %s entries, active_energy=%.2f",
+			payload.Data.ContractID, payload.Data.ContractName, payload.Data.ConsumptionEntries(), payload.GroupBy,
+			totalActiveEnergy(payload)), nil
+	}
+}
+
+// billHandler adapta Processor.OnBill a HandleFunc
+func billHandler(p Processor) HandleFunc {
+	return func(ctx context.Context, event Event) (string, error) {
+		payload, ok := event.Payload.(dto.BillWebhookPayload)
+		if !ok {
+			return "", fmt.Errorf("%w: unexpected payload type %T for bills", ErrInvalidPayload, event.Payload)
+		}
+
+		if err := p.OnBill(ctx, payload); err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("Bills webhook processed successfully: %s event for bill %d",
+			payload.TriggerType, payload.Bill.BillID), nil
+	}
+}
+
+// totalActiveEnergy suma la energía activa de todos los registros de consumo
+func totalActiveEnergy(payload dto.WebhookPayload) float64 {
+	var total float64
+	switch payload.GroupBy {
+	case dto.GroupByHour:
+		hours, _ := payload.Data.HourlyConsumption()
+		for _, h := range hours {
+			total += valueOrZero(h.ActiveEnergy)
+		}
+	case dto.GroupByDay:
+		days, _ := payload.Data.DailyConsumption()
+		for _, d := range days {
+			total += valueOrZero(d.ActiveEnergy)
+		}
+	case dto.GroupByMonth:
+		months, _ := payload.Data.MonthlyConsumption()
+		for _, m := range months {
+			total += valueOrZero(m.ActiveEnergy)
+		}
+	case dto.GroupByDateAndHour:
+		dates, _ := payload.Data.DateAndHourlyConsumption()
+		for _, d := range dates {
+			for _, h := range d.Hours {
+				total += valueOrZero(h.ActiveEnergy)
+			}
+		}
+	}
+	return total
+}
+
+// valueOrZero retorna el valor de una métrica opcional o 0 si no viene en el payload
+func valueOrZero(v *float64) float64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
@@ -0,0 +1,49 @@
+package processor
+
+import "errors"
+
+// retryableError marca un error del procesador como transitorio
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+// permanentError marca un error del procesador como definitivo
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Retryable marca err como transitorio (base de datos caída, timeout de un servicio externo, ...).
+// El receptor responde 503 para que bia-consumptions reintente la entrega.
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retryableError{err: err}
+}
+
+// Permanent marca err como definitivo: reintentar la entrega no cambiaría el resultado.
+// El receptor responde 422 para que bia-consumptions no reintente.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsRetryable indica si err fue marcado con Retryable
+func IsRetryable(err error) bool {
+	var target *retryableError
+	return errors.As(err, &target)
+}
+
+// IsPermanent indica si err fue marcado con Permanent o corresponde a un payload inválido
+func IsPermanent(err error) bool {
+	var target *permanentError
+	return errors.As(err, &target) || errors.Is(err, ErrInvalidPayload) || errors.Is(err, ErrUnknownDataType)
+}
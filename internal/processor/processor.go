@@ -0,0 +1,87 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"webhook_receiver/internal/dto"
+	"webhook_receiver/internal/tracing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Processor recibe los webhooks ya decodificados de los tipos que envía bia-consumptions.
+// Implementa esta interfaz para agregar tu lógica de negocio sin modificar el handler.
+type Processor interface {
+	// OnConsumption se invoca por cada webhook con data_type "consumption"
+	OnConsumption(ctx context.Context, payload dto.WebhookPayload) error
+	// OnBill se invoca por cada webhook con data_type "bills"
+	OnBill(ctx context.Context, payload dto.BillWebhookPayload) error
+}
+
+// NoopProcessor acepta todos los eventos sin hacer nada con ellos
+type NoopProcessor struct{}
+
+// OnConsumption implementa Processor
+func (NoopProcessor) OnConsumption(ctx context.Context, payload dto.WebhookPayload) error {
+	return nil
+}
+
+// OnBill implementa Processor
+func (NoopProcessor) OnBill(ctx context.Context, payload dto.BillWebhookPayload) error {
+	return nil
+}
+
+// Chain ejecuta varios procesadores en orden y se detiene en el primer error
+type Chain []Processor
+
+// OnConsumption implementa Processor
+func (c Chain) OnConsumption(ctx context.Context, payload dto.WebhookPayload) error {
+	for _, p := range c {
+		ctx, span := startProcessorSpan(ctx, p)
+		err := p.OnConsumption(ctx, payload)
+		tracing.End(span, err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OnBill implementa Processor
+func (c Chain) OnBill(ctx context.Context, payload dto.BillWebhookPayload) error {
+	for _, p := range c {
+		ctx, span := startProcessorSpan(ctx, p)
+		err := p.OnBill(ctx, payload)
+		tracing.End(span, err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startProcessorSpan crea el span de un procesador de la cadena, nombrado por su tipo
+func startProcessorSpan(ctx context.Context, p Processor) (context.Context, trace.Span) {
+	return tracing.Start(ctx, fmt.Sprintf("processor %T", p))
+}
+
+type receivedAtKey struct{}
+
+// WithReceivedAt retorna un contexto con la hora de recepción del webhook
+func WithReceivedAt(ctx context.Context, receivedAt time.Time) context.Context {
+	if receivedAt.IsZero() {
+		return ctx
+	}
+	return context.WithValue(ctx, receivedAtKey{}, receivedAt)
+}
+
+// ReceivedAt retorna la hora en que se recibió el webhook que se está procesando,
+// o la hora actual si el contexto no la tiene
+func ReceivedAt(ctx context.Context) time.Time {
+	if receivedAt, ok := ctx.Value(receivedAtKey{}).(time.Time); ok {
+		return receivedAt
+	}
+	return time.Now()
+}
@@ -0,0 +1,188 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"webhook_receiver/internal/dto"
+	"webhook_receiver/internal/tracing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tipos de datos soportados por defecto
+const (
+	DataTypeConsumption = "consumption"
+	DataTypeBills       = "bills"
+)
+
+var (
+	// ErrUnknownDataType se retorna cuando no hay nada registrado para el data_type recibido
+	ErrUnknownDataType = errors.New("unknown data_type")
+	// ErrInvalidPayload envuelve los errores de decodificación o validación del payload
+	ErrInvalidPayload = errors.New("invalid payload")
+	// ErrMalformedJSON indica que el body ni siquiera es JSON válido
+	ErrMalformedJSON = errors.New("malformed JSON")
+)
+
+// Event representa un webhook ya decodificado, listo para ser procesado
+type Event struct {
+	DataType    string
+	TriggerType string
+	WebhookID   int
+	ContractID  int
+	Headers     dto.WebhookHeaders
+	Body        []byte
+	// ReceivedAt es la hora en que el receptor recibió el webhook (no la del emisor)
+	ReceivedAt time.Time
+	// Payload contiene el DTO concreto (dto.WebhookPayload, dto.BillWebhookPayload, ...)
+	Payload interface{}
+}
+
+// DecodeFunc convierte el body crudo en un Event. Debe completar Payload y los
+// campos de identificación (WebhookID, ContractID, TriggerType) que apliquen.
+type DecodeFunc func(body []byte) (Event, error)
+
+// HandleFunc procesa un Event y retorna un mensaje descriptivo para la respuesta
+type HandleFunc func(ctx context.Context, event Event) (string, error)
+
+// Hook se ejecuta con cada Event procesado correctamente, de cualquier data_type
+// (reenvío a otros servicios, publicación en un broker, ...). Un error en un hook
+// hace fallar el procesamiento del evento.
+type Hook func(ctx context.Context, event Event) error
+
+type registration struct {
+	decode DecodeFunc
+	handle HandleFunc
+}
+
+// Registry asocia cada data_type con su decoder y su procesador
+type Registry struct {
+	mu      sync.RWMutex
+	entries map[string]registration
+	hooks   []Hook
+}
+
+// NewRegistry crea un registry vacío
+func NewRegistry() *Registry {
+	return &Registry{
+		entries: make(map[string]registration),
+	}
+}
+
+// NewDefaultRegistry crea un registry con los tipos "consumption" y "bills"
+// delegando el procesamiento en p
+func NewDefaultRegistry(p Processor) *Registry {
+	r := NewRegistry()
+	r.Register(DataTypeConsumption, decodeConsumption, consumptionHandler(p))
+	r.Register(DataTypeBills, decodeBill, billHandler(p))
+	return r
+}
+
+// Register agrega (o reemplaza) el decoder y procesador de un data_type
+func (r *Registry) Register(dataType string, decode DecodeFunc, handle HandleFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[dataType] = registration{decode: decode, handle: handle}
+}
+
+// AddHook agrega un hook que se ejecuta, en el orden en que se agregó, después de procesar cada evento
+func (r *Registry) AddHook(hook Hook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = append(r.hooks, hook)
+}
+
+// DataTypes retorna los data_type registrados
+func (r *Registry) DataTypes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	dataTypes := make([]string, 0, len(r.entries))
+	for dataType := range r.entries {
+		dataTypes = append(dataTypes, dataType)
+	}
+	return dataTypes
+}
+
+// Decode detecta el data_type del body y lo decodifica con el decoder registrado
+func (r *Registry) Decode(body []byte, headers dto.WebhookHeaders) (Event, error) {
+	var base struct {
+		DataType string `json:"data_type"`
+	}
+	if err := json.Unmarshal(body, &base); err != nil {
+		return Event{}, fmt.Errorf("%w: %w: %v", ErrInvalidPayload, ErrMalformedJSON, err)
+	}
+
+	entry, ok := r.lookup(base.DataType)
+	if !ok {
+		return Event{}, fmt.Errorf("%w: %q", ErrUnknownDataType, base.DataType)
+	}
+
+	event, err := entry.decode(body)
+	if err != nil {
+		// El data_type sí es conocido: se conserva para los logs y las métricas
+		return Event{DataType: base.DataType}, err
+	}
+
+	event.DataType = base.DataType
+	event.Headers = headers
+	event.Body = body
+	event.ReceivedAt = time.Now()
+	return event, nil
+}
+
+// Process ejecuta el procesador registrado para el data_type del evento y luego los hooks.
+// La hora de recepción del evento queda disponible en el contexto (ver ReceivedAt).
+func (r *Registry) Process(ctx context.Context, event Event) (string, error) {
+	entry, ok := r.lookup(event.DataType)
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownDataType, event.DataType)
+	}
+
+	ctx, span := tracing.Start(WithReceivedAt(ctx, event.ReceivedAt), "webhook.process",
+		trace.WithAttributes(tracing.EventAttributes(event.DataType, event.TriggerType, event.WebhookID, event.ContractID)...))
+	message, err := r.process(ctx, entry, event)
+	tracing.End(span, err)
+	return message, err
+}
+
+// process ejecuta el handler y luego los hooks, deteniéndose en el primer error
+func (r *Registry) process(ctx context.Context, entry registration, event Event) (string, error) {
+	message, err := entry.handle(ctx, event)
+	if err != nil {
+		return "", err
+	}
+
+	r.mu.RLock()
+	hooks := r.hooks
+	r.mu.RUnlock()
+	for _, hook := range hooks {
+		if err := hook(ctx, event); err != nil {
+			return "", err
+		}
+	}
+	return message, nil
+}
+
+// Dispatch decodifica y procesa el body en un solo paso
+func (r *Registry) Dispatch(ctx context.Context, body []byte, headers dto.WebhookHeaders) (Event, string, error) {
+	event, err := r.Decode(body, headers)
+	if err != nil {
+		return event, "", err
+	}
+
+	message, err := r.Process(ctx, event)
+	return event, message, err
+}
+
+func (r *Registry) lookup(dataType string) (registration, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entry, ok := r.entries[dataType]
+	return entry, ok
+}
@@ -0,0 +1,221 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"webhook_receiver/internal/dto"
+)
+
+// meterReading es el payload de "meter_reading", un data_type personalizado de prueba
+type meterReading struct {
+	WebhookID  int    `json:"webhook_id"`
+	ContractID int    `json:"contract_id"`
+	Kind       string `json:"kind"`
+}
+
+func decodeMeterReading(body []byte) (Event, error) {
+	var reading meterReading
+	if err := json.Unmarshal(body, &reading); err != nil {
+		return Event{}, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	if reading.Kind == "" {
+		return Event{}, fmt.Errorf("%w: kind is required", ErrInvalidPayload)
+	}
+	return Event{WebhookID: reading.WebhookID, ContractID: reading.ContractID, TriggerType: reading.Kind, Payload: reading}, nil
+}
+
+func TestRegistryDecode(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("meter_reading", decodeMeterReading, func(ctx context.Context, event Event) (string, error) {
+		return "ok", nil
+	})
+	headers := dto.WebhookHeaders{WebhookID: "7", IDKey: "k1"}
+
+	tests := []struct {
+		name         string
+		body         string
+		wantErrIs    []error
+		wantDataType string
+	}{
+		{name: "custom data_type", body: `{"data_type":"meter_reading","webhook_id":7,"contract_id":1001,"kind":"snapshot"}`, wantDataType: "meter_reading"},
+		{name: "malformed JSON", body: `{"data_type":`, wantErrIs: []error{ErrInvalidPayload, ErrMalformedJSON}},
+		{name: "unknown data_type", body: `{"data_type":"weather"}`, wantErrIs: []error{ErrUnknownDataType}},
+		{name: "built-in type not registered", body: `{"data_type":"consumption"}`, wantErrIs: []error{ErrUnknownDataType}},
+		{name: "decoder error keeps the data_type", body: `{"data_type":"meter_reading","webhook_id":7}`, wantErrIs: []error{ErrInvalidPayload}, wantDataType: "meter_reading"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := registry.Decode([]byte(tt.body), headers)
+			for _, target := range tt.wantErrIs {
+				if !errors.Is(err, target) {
+					t.Errorf("Decode() error = %v, want %v", err, target)
+				}
+			}
+			if event.DataType != tt.wantDataType {
+				t.Errorf("DataType = %q, want %q", event.DataType, tt.wantDataType)
+			}
+			if tt.wantErrIs != nil {
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+
+			want := meterReading{WebhookID: 7, ContractID: 1001, Kind: "snapshot"}
+			if !reflect.DeepEqual(event.Payload, want) {
+				t.Errorf("Payload = %+v, want %+v", event.Payload, want)
+			}
+			if event.WebhookID != 7 || event.ContractID != 1001 || event.TriggerType != "snapshot" {
+				t.Errorf("event ids = %d/%d/%q", event.WebhookID, event.ContractID, event.TriggerType)
+			}
+			if event.Headers != headers || string(event.Body) != tt.body || event.ReceivedAt.IsZero() {
+				t.Errorf("Decode() did not fill headers, body and received_at: %+v", event)
+			}
+		})
+	}
+}
+
+func TestRegistryDispatch(t *testing.T) {
+	errHandler := errors.New("handler failed")
+	errHook := errors.New("hook failed")
+	body := `{"data_type":"meter_reading","webhook_id":7,"contract_id":1001,"kind":"snapshot"}`
+
+	tests := []struct {
+		name        string
+		handlerErr  error
+		hookErrs    map[string]error // hook que falla y su error
+		wantCalls   []string
+		wantMessage string
+		wantErrIs   error
+	}{
+		{
+			name:        "handler then hooks in order",
+			wantCalls:   []string{"handler", "first", "second"},
+			wantMessage: "stored",
+		},
+		{
+			name:       "handler error skips the hooks",
+			handlerErr: Retryable(errHandler),
+			wantCalls:  []string{"handler"},
+			wantErrIs:  errHandler,
+		},
+		{
+			name:      "hook error stops the remaining hooks",
+			hookErrs:  map[string]error{"first": errHook},
+			wantCalls: []string{"handler", "first"},
+			wantErrIs: errHook,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			registry := NewRegistry()
+			registry.Register("meter_reading", decodeMeterReading, func(ctx context.Context, event Event) (string, error) {
+				calls = append(calls, "handler")
+				if ReceivedAt(ctx) != event.ReceivedAt {
+					t.Errorf("ReceivedAt(ctx) = %v, want %v", ReceivedAt(ctx), event.ReceivedAt)
+				}
+				return "stored", tt.handlerErr
+			})
+			for _, name := range []string{"first", "second"} {
+				name := name
+				registry.AddHook(func(ctx context.Context, event Event) error {
+					calls = append(calls, name)
+					if event.DataType != "meter_reading" {
+						t.Errorf("hook %s: DataType = %q", name, event.DataType)
+					}
+					return tt.hookErrs[name]
+				})
+			}
+
+			event, message, err := registry.Dispatch(context.Background(), []byte(body), dto.WebhookHeaders{})
+			if !errors.Is(err, tt.wantErrIs) {
+				t.Fatalf("Dispatch() error = %v, want %v", err, tt.wantErrIs)
+			}
+			if tt.handlerErr != nil && !IsRetryable(err) {
+				t.Errorf("Dispatch() error = %v, want the handler's retryable error", err)
+			}
+			if message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", message, tt.wantMessage)
+			}
+			if event.DataType != "meter_reading" {
+				t.Errorf("DataType = %q", event.DataType)
+			}
+			if !reflect.DeepEqual(calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRegistryProcessUnknownDataType(t *testing.T) {
+	_, err := NewRegistry().Process(context.Background(), Event{DataType: "weather", ReceivedAt: time.Now()})
+	if !errors.Is(err, ErrUnknownDataType) || !IsPermanent(err) {
+		t.Errorf("Process() error = %v, want a permanent ErrUnknownDataType", err)
+	}
+}
+
+// recordingProcessor registra las llamadas y retorna err
+type recordingProcessor struct {
+	name  string
+	calls *[]string
+	err   error
+}
+
+func (p recordingProcessor) OnConsumption(ctx context.Context, payload dto.WebhookPayload) error {
+	*p.calls = append(*p.calls, p.name+".consumption")
+	return p.err
+}
+
+func (p recordingProcessor) OnBill(ctx context.Context, payload dto.BillWebhookPayload) error {
+	*p.calls = append(*p.calls, p.name+".bill")
+	return p.err
+}
+
+func TestChain(t *testing.T) {
+	errStore := errors.New("store failed")
+
+	tests := []struct {
+		name      string
+		errs      []error // error de cada procesador
+		wantCalls []string
+		wantErrIs error
+	}{
+		{name: "runs every processor in order", errs: []error{nil, nil, nil}, wantCalls: []string{"p0", "p1", "p2"}},
+		{name: "stops at the first error", errs: []error{nil, errStore, nil}, wantCalls: []string{"p0", "p1"}, wantErrIs: errStore},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, kind := range []string{"consumption", "bill"} {
+				var calls []string
+				chain := Chain{}
+				for i, err := range tt.errs {
+					chain = append(chain, recordingProcessor{name: fmt.Sprintf("p%d", i), calls: &calls, err: err})
+				}
+
+				var err error
+				if kind == "consumption" {
+					err = chain.OnConsumption(context.Background(), dto.WebhookPayload{})
+				} else {
+					err = chain.OnBill(context.Background(), dto.BillWebhookPayload{})
+				}
+				if !errors.Is(err, tt.wantErrIs) {
+					t.Fatalf("On%s() error = %v, want %v", kind, err, tt.wantErrIs)
+				}
+				want := make([]string, len(tt.wantCalls))
+				for i, call := range tt.wantCalls {
+					want[i] = call + "." + kind
+				}
+				if !reflect.DeepEqual(calls, want) {
+					t.Errorf("calls = %v, want %v", calls, want)
+				}
+			}
+		})
+	}
+}
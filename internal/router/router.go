@@ -1,66 +1,235 @@
 package router
 
 import (
-	"os"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
 
+	"webhook_receiver/internal/cloudevents"
+	"webhook_receiver/internal/config"
+	"webhook_receiver/internal/deadletter"
+	"webhook_receiver/internal/executor"
+	"webhook_receiver/internal/forwarder"
 	"webhook_receiver/internal/handlers"
+	"webhook_receiver/internal/idempotency"
+	"webhook_receiver/internal/inbox"
+	"webhook_receiver/internal/invoice"
+	"webhook_receiver/internal/logging"
+	"webhook_receiver/internal/metrics"
 	"webhook_receiver/internal/middleware"
+	"webhook_receiver/internal/processor"
+	"webhook_receiver/internal/sink"
+	"webhook_receiver/internal/storage"
+	"webhook_receiver/internal/tracing"
+	"webhook_receiver/internal/worker"
 
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 )
 
-// NewRouter crea y configura el router principal
-func NewRouter() *gin.Engine {
-	// Cargar variables de entorno
-	if err := godotenv.Load(); err != nil {
-		// Si no existe el archivo .env, continuar sin error
+// Cleanup espera a los webhooks encolados y libera los recursos abiertos por NewRouter
+// (base de datos, inbox, sinks y exporter de trazas). Se llama al apagar, después de
+// detener el servidor HTTP; ctx limita la espera.
+type Cleanup func(ctx context.Context) error
+
+// closers acumula las funciones de cierre de los recursos abiertos, que se ejecutan en
+// orden inverso al de apertura
+type closers []func(ctx context.Context) error
+
+func (c *closers) add(close func(ctx context.Context) error) {
+	*c = append(*c, close)
+}
+
+func (c closers) close(ctx context.Context) error {
+	var errs []error
+	for i := len(c) - 1; i >= 0; i-- {
+		errs = append(errs, c[i](ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// NewRouter crea y configura el router principal a partir de la configuración ya validada
+// (ver config.Load). Si algún recurso no se puede abrir cierra los ya abiertos y retorna
+// el error.
+func NewRouter(cfg *config.Config) (*gin.Engine, Cleanup, error) {
+	var resources closers
+	router, err := newRouter(cfg, &resources)
+	if err != nil {
+		return nil, nil, errors.Join(err, resources.close(context.Background()))
 	}
+	return router, resources.close, nil
+}
 
+func newRouter(cfg *config.Config, resources *closers) (*gin.Engine, error) {
 	// Configurar Gin
-	gin.SetMode(getGinMode())
+	gin.SetMode(cfg.Mode)
 
 	// Crear router
 	router := gin.New()
 
+	// Trazas de OpenTelemetry (TRACING_EXPORTER=otlp|stdout; vacío = desactivadas)
+	exporter := cfg.Tracing.Exporter
+	shutdownTracing, err := tracing.Setup(context.Background(), exporter, cfg.Tracing.ServiceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up tracing: %w", err)
+	}
+	resources.add(shutdownTracing)
+
 	// Middleware global
-	router.Use(gin.Logger())
+	router.Use(logging.Middleware())
 	router.Use(gin.Recovery())
+	if exporter != tracing.ExporterNone {
+		router.Use(tracing.Middleware())
+	}
 	router.Use(corsMiddleware())
 
-	// Obtener secret key de las variables de entorno
-	secretKey := os.Getenv("WEBHOOK_SECRET_KEY")
-	if secretKey == "" {
-		secretKey = "secret_key" // Solo para desarrollo
+	// Métricas de Prometheus en /metrics (METRICS_ENABLED=false las desactiva)
+	var webhookMetrics *metrics.Metrics
+	if cfg.Metrics.Enabled {
+		webhookMetrics = metrics.New()
+		router.Use(webhookMetrics.Middleware())
+	}
+
+	// Obtener secretos de firma
+	secretResolver, err := newSecretResolver(cfg.Webhook)
+	if err != nil {
+		return nil, err
 	}
 
 	// Crear middleware de verificación de firma
-	signatureMiddleware := middleware.NewWebhookSignatureMiddleware(secretKey)
+	signatureMiddleware := middleware.NewWebhookSignatureMiddleware(secretResolver,
+		middleware.WithTimestampTolerance(cfg.Webhook.TimestampTolerance),
+		middleware.WithLegacyReplayWindow(cfg.Webhook.LegacyReplayWindow),
+		middleware.WithSignatureSchemes(cfg.Webhook.SignatureSchemes...),
+		middleware.WithFailureObserver(webhookMetrics.ObserveSignatureFailure),
+	)
+
+	// Crear middleware de idempotencia
+	idempotencyStore, err := newIdempotencyStore(cfg.Idempotency, resources)
+	if err != nil {
+		return nil, err
+	}
+	idempotencyMiddleware := middleware.NewIdempotencyMiddleware(idempotencyStore)
+
+	// Crear registry de procesadores (agrega tu implementación de processor.Processor en newProcessor)
+	db, err := openDatabase(cfg.Database)
+	if err != nil {
+		return nil, err
+	}
+	if db != nil {
+		resources.add(func(context.Context) error { return db.Close() })
+	}
+	registry := processor.NewDefaultRegistry(newProcessor(db, cfg.Invoice, resources))
+	if err := addForwarder(registry, cfg.Forward); err != nil {
+		return nil, err
+	}
+	if err := addSink(registry, cfg.Sink, resources); err != nil {
+		return nil, err
+	}
+	if err := addCommandHooks(registry, db, cfg.Hooks, resources); err != nil {
+		return nil, err
+	}
 
 	// Crear handlers
-	webhookHandler := handlers.NewWebhookHandler()
+	var handlerOpts []handlers.HandlerOption
+	webhookInbox, err := newInbox(cfg.Inbox)
+	if err != nil {
+		return nil, err
+	}
+	if webhookInbox != nil {
+		resources.add(func(context.Context) error { return webhookInbox.Close() })
+		handlerOpts = append(handlerOpts, handlers.WithInbox(webhookInbox))
+	}
+	// Los reintentos con backoff y el dead-letter queue son del modo asíncrono: en modo
+	// síncrono el emisor recibe el error y reintenta la entrega
+	var deadLetters deadletter.Store
+	if cfg.Async.Enabled {
+		if deadLetters, err = newDeadLetterStore(cfg.DeadLetter); err != nil {
+			return nil, err
+		}
+		handlerOpts = append(handlerOpts,
+			handlers.WithAsync(workerOptions(cfg.Async, registry.DataTypes())),
+			handlers.WithDeadLetters(deadLetters),
+			handlers.WithRetryPolicy(cfg.Retry.Policy()),
+		)
+	}
+	handlerOpts = append(handlerOpts, handlers.WithMetrics(webhookMetrics))
+	webhookHandler := handlers.NewWebhookHandler(registry, handlerOpts...)
+	// Se registra después del inbox y la base de datos para cerrarse antes que ellos: los
+	// webhooks encolados terminan de procesarse y registrar su resultado
+	resources.add(webhookHandler.Shutdown)
+	webhookMetrics.WatchQueues(webhookHandler.QueueDepth)
+	var deadLetterHandler *handlers.DeadLetterHandler
+	if deadLetters != nil {
+		deadLetterHandler = handlers.NewDeadLetterHandler(deadLetters, webhookHandler)
+	}
+
+	// Reprocesar los webhooks que quedaron pendientes en el inbox antes de un reinicio. Los
+	// pendientes se leen aquí, antes de recibir peticiones, para no tomar webhooks en curso.
+	if pending := webhookHandler.PendingInbox(); len(pending) > 0 {
+		recoverCtx, cancelRecover := context.WithCancel(context.Background())
+		resources.add(func(context.Context) error {
+			cancelRecover()
+			return nil
+		})
+		go webhookHandler.RecoverInbox(recoverCtx, pending)
+	}
+
+	// Crear middleware de administración (sin ADMIN_TOKEN no se exponen los endpoints de admin)
+	var adminMiddleware *middleware.AdminAuthMiddleware
+	switch {
+	case cfg.AdminToken == "":
+		slog.Warn("ADMIN_TOKEN is not set: admin endpoints are disabled")
+	case deadLetterHandler == nil:
+		slog.Warn("Admin endpoints require async mode (ASYNC_MODE=true): admin endpoints are disabled")
+	default:
+		adminMiddleware = middleware.NewAdminAuthMiddleware(cfg.AdminToken)
+	}
 
 	// Configurar rutas
-	configureRoutes(router, webhookHandler, signatureMiddleware)
+	configureRoutes(router, webhookHandler, deadLetterHandler, signatureMiddleware, idempotencyMiddleware, adminMiddleware, webhookMetrics)
 
-	return router
+	return router, nil
 }
 
 // configureRoutes configura todas las rutas de la aplicación
-func configureRoutes(router *gin.Engine, webhookHandler *handlers.WebhookHandler, signatureMiddleware *middleware.WebhookSignatureMiddleware) {
+func configureRoutes(router *gin.Engine, webhookHandler *handlers.WebhookHandler, deadLetterHandler *handlers.DeadLetterHandler, signatureMiddleware *middleware.WebhookSignatureMiddleware, idempotencyMiddleware *middleware.IdempotencyMiddleware, adminMiddleware *middleware.AdminAuthMiddleware, webhookMetrics *metrics.Metrics) {
 	// Grupo de rutas públicas (sin autenticación)
 	public := router.Group("/")
 	{
 		public.GET("/health", webhookHandler.HealthCheck)
+		if webhookMetrics != nil {
+			public.GET("/metrics", gin.WrapH(webhookMetrics.Handler()))
+		}
 	}
 
 	// Grupo de rutas protegidas (con verificación de firma)
 	protected := router.Group("/")
 	protected.Use(signatureMiddleware.VerifySignature())
+	// La idempotencia va antes del control de replay: un reenvío idéntico con la misma
+	// X-Idempotency-Key recibe la respuesta registrada en lugar de un 401. Los 401 de replay
+	// no se registran bajo la clave
+	protected.Use(idempotencyMiddleware.Handle())
+	protected.Use(signatureMiddleware.RejectReplays())
 	{
 		protected.POST("/webhook", webhookHandler.ReceiveWebhook)
 	}
 
+	// Grupo de rutas de administración (con token Bearer)
+	if adminMiddleware != nil {
+		admin := router.Group("/admin")
+		admin.Use(adminMiddleware.RequireToken())
+		{
+			admin.GET("/dead-letters", deadLetterHandler.List)
+			admin.DELETE("/dead-letters", deadLetterHandler.Purge)
+			admin.POST("/dead-letters/redrive", deadLetterHandler.RedriveAll)
+			admin.GET("/dead-letters/:id", deadLetterHandler.Get)
+			admin.DELETE("/dead-letters/:id", deadLetterHandler.Delete)
+			admin.POST("/dead-letters/:id/redrive", deadLetterHandler.Redrive)
+		}
+	}
+
 	// Ruta de documentación (solo en desarrollo)
 	if gin.Mode() != gin.ReleaseMode {
 		router.GET("/", func(c *gin.Context) {
@@ -70,26 +239,228 @@ func configureRoutes(router *gin.Engine, webhookHandler *handlers.WebhookHandler
 				"endpoints": gin.H{
 					"health":  "GET /health",
 					"webhook": "POST /webhook (requires signature verification)",
+					"admin":   "GET|DELETE /admin/dead-letters, POST /admin/dead-letters/:id/redrive (requires ADMIN_TOKEN)",
 				},
 			})
 		})
 	}
 }
 
-// getGinMode retorna el modo de Gin basado en variables de entorno
-func getGinMode() string {
-	env := os.Getenv("GIN_MODE")
-	if env == "" {
-		env = os.Getenv("GO_ENV")
+// newSecretResolver carga los secretos de firma desde WEBHOOK_SECRETS_FILE (secretos con
+// vigencia, por webhook_id y por contract_id) o, si no está definido, desde WEBHOOK_SECRET_KEY
+func newSecretResolver(cfg config.WebhookConfig) (middleware.SecretResolver, error) {
+	if cfg.SecretsFile != "" {
+		resolver, err := middleware.LoadSecretResolver(cfg.SecretsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load webhook secrets: %w", err)
+		}
+		return resolver, nil
+	}
+
+	secretKey := cfg.SecretKey
+	if secretKey == "" {
+		// Solo para desarrollo: en producción config.Validate exige una clave
+		slog.Warn("WEBHOOK_SECRET_KEY is not set: using the development secret key")
+		secretKey = config.DevelopmentSecretKey
+	}
+	return middleware.StaticSecretResolver{{ID: "default", Key: secretKey}}, nil
+}
+
+// openDatabase abre la base de datos SQLite de DATABASE_PATH; retorna nil si no está configurada
+func openDatabase(cfg config.DatabaseConfig) (*sql.DB, error) {
+	if cfg.Path == "" {
+		return nil, nil
+	}
+	db, err := storage.Open(context.Background(), cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return db, nil
+}
+
+// newProcessor arma la cadena de procesadores. Con la base de datos configurada las lecturas
+// de consumo y el ciclo de vida de las facturas se guardan en SQLite antes de ejecutar el
+// resto de la cadena.
+func newProcessor(db *sql.DB, cfg config.InvoiceConfig, resources *closers) processor.Processor {
+	chain := processor.Chain{}
+
+	if db != nil {
+		bills := storage.NewBillRepository(db)
+		chain = append(chain, storage.NewProcessor(storage.NewConsumptionRepository(db), bills))
+
+		// Descargar el XML de la factura electrónica referenciado en xml_url
+		if cfg.FetchEnabled {
+			retryPolicy := invoice.DefaultRetryPolicy
+			retryPolicy.MaxAttempts = cfg.FetchAttempts
+			fetcher := invoice.NewFetcher(
+				invoice.WithTimeout(cfg.FetchTimeout),
+				invoice.WithMaxSize(int64(cfg.MaxSize)),
+				invoice.WithRetryPolicy(retryPolicy),
+				invoice.WithAllowedHosts(cfg.AllowedHosts...),
+			)
+			enricher := storage.NewInvoiceEnricher(fetcher, bills)
+			resources.add(enricher.Close)
+			chain = append(chain, enricher)
+		}
+	} else if cfg.FetchEnabled {
+		slog.Warn("INVOICE_FETCH_ENABLED requires DATABASE_PATH: invoice enrichment is disabled")
+	}
+
+	// Agrega aquí tu implementación de processor.Processor
+	chain = append(chain, processor.NoopProcessor{})
+	return chain
+}
+
+// addForwarder reenvía cada evento procesado a los targets de FORWARD_TARGETS_FILE
+func addForwarder(registry *processor.Registry, cfg config.ForwardConfig) error {
+	if cfg.TargetsFile == "" {
+		return nil
+	}
+
+	targets, err := forwarder.LoadTargets(cfg.TargetsFile)
+	if err != nil {
+		return fmt.Errorf("failed to load forward targets: %w", err)
+	}
+	fwd := forwarder.NewForwarder(targets,
+		forwarder.WithRetryPolicy(cfg.Retry.Policy()),
+		forwarder.WithCircuitBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
+	)
+	registry.AddHook(fwd.Forward)
+	slog.Info("Forwarding webhooks", "targets", len(targets))
+	return nil
+}
+
+// addCommandHooks ejecuta los comandos de HOOKS_FILE con cada evento procesado. Con la base
+// de datos configurada el resultado de cada ejecución se guarda en hook_runs. Al apagar se
+// esperan los comandos en curso antes de cerrar la base de datos.
+func addCommandHooks(registry *processor.Registry, db *sql.DB, cfg config.HooksConfig, resources *closers) error {
+	if cfg.File == "" {
+		return nil
+	}
+
+	hooks, err := executor.LoadConfig(cfg.File)
+	if err != nil {
+		return fmt.Errorf("failed to load command hooks: %w", err)
+	}
+	var opts []executor.Option
+	if db != nil {
+		opts = append(opts, executor.WithRecorder(storage.NewHookRunRepository(db)))
+	}
+	commands := executor.NewExecutor(hooks, opts...)
+	resources.add(commands.Close)
+	registry.AddHook(commands.Run)
+	slog.Info("Running command hooks", "hooks", len(hooks.Hooks))
+	return nil
+}
+
+// addSink publica cada evento procesado en el broker de SINK_TYPE (kafka o nats). El sink
+// se cierra con resources al apagar.
+func addSink(registry *processor.Registry, cfg config.SinkConfig, resources *closers) error {
+	var (
+		eventSink sink.Sink
+		err       error
+	)
+	switch cfg.Type {
+	case "":
+		return nil
+	case "kafka":
+		eventSink, err = sink.NewKafkaSink(cfg.KafkaBrokers)
+	case "nats":
+		eventSink, err = sink.NewNATSSink(cfg.NATSURL)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create event sink: %w", err)
+	}
+	resources.add(func(context.Context) error { return eventSink.Close() })
+
+	opts := []sink.PublisherOption{
+		sink.WithTopicPrefix(cfg.TopicPrefix),
+		sink.WithPublishTimeout(cfg.PublishTimeout),
+	}
+	// SINK_CLOUDEVENTS=structured|binary publica los eventos como CloudEvents
+	if mode := cloudevents.Mode(cfg.CloudEvents); mode != cloudevents.ModeNone {
+		headerPrefix := cloudevents.HTTPHeaderPrefix
+		if cfg.Type == "kafka" {
+			headerPrefix = cloudevents.KafkaHeaderPrefix
+		}
+		opts = append(opts, sink.WithCloudEvents(mode, headerPrefix))
 	}
 
-	switch env {
-	case "production", "prod":
-		return gin.ReleaseMode
-	case "test":
-		return gin.TestMode
+	for dataType, topic := range cfg.Topics {
+		opts = append(opts, sink.WithTopic(dataType, topic))
+	}
+
+	registry.AddHook(sink.NewPublisher(eventSink, opts...).Publish)
+	slog.Info("Publishing webhook events", "sink_type", cfg.Type)
+	return nil
+}
+
+// newInbox abre el inbox durable en INBOX_DIR; retorna nil si no está configurado
+func newInbox(cfg config.InboxConfig) (*inbox.Inbox, error) {
+	if cfg.Dir == "" {
+		return nil, nil
+	}
+
+	webhookInbox, err := inbox.Open(cfg.Dir, inbox.Options{SegmentSize: int64(cfg.SegmentSize)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open inbox: %w", err)
+	}
+	return webhookInbox, nil
+}
+
+// newDeadLetterStore crea el dead-letter queue configurado en DEAD_LETTER_STORE (memory o file)
+func newDeadLetterStore(cfg config.DeadLetterConfig) (deadletter.Store, error) {
+	switch cfg.Store {
+	case "file":
+		store, err := deadletter.NewFileStore(cfg.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create dead-letter store: %w", err)
+		}
+		return store, nil
+	default:
+		return deadletter.NewMemoryStore(), nil
+	}
+}
+
+// workerOptions arma la configuración del pool asíncrono. ASYNC_WORKERS y ASYNC_QUEUE_SIZE
+// aplican a todos los data_type; ASYNC_WORKERS_<DATA_TYPE> y ASYNC_QUEUE_SIZE_<DATA_TYPE>
+// (por ejemplo ASYNC_WORKERS_BILLS) los sobrescriben para un tipo.
+func workerOptions(cfg config.AsyncConfig, dataTypes []string) worker.Options {
+	opts := worker.Options{
+		Default: worker.QueueOptions{
+			Workers:   cfg.Workers,
+			QueueSize: cfg.QueueSize,
+		},
+		PerDataType: make(map[string]worker.QueueOptions),
+	}
+
+	for _, dataType := range dataTypes {
+		queue := cfg.DataTypes[dataType]
+		opts.PerDataType[dataType] = worker.QueueOptions{
+			Workers:   queue.Workers,
+			QueueSize: queue.QueueSize,
+		}
+	}
+	return opts
+}
+
+// newIdempotencyStore crea el store de idempotencia configurado en IDEMPOTENCY_STORE (memory o file)
+func newIdempotencyStore(cfg config.IdempotencyConfig, resources *closers) (idempotency.Store, error) {
+	opts := idempotency.Options{
+		TTL:         cfg.TTL,
+		LockTimeout: cfg.LockTimeout,
+	}
+
+	switch cfg.Store {
+	case "file":
+		store, err := idempotency.NewFileStore(cfg.Dir, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create idempotency store: %w", err)
+		}
+		resources.add(func(context.Context) error { return store.Close() })
+		return store, nil
 	default:
-		return gin.DebugMode
+		return idempotency.NewMemoryStore(cfg.MaxKeys, opts), nil
 	}
 }
 
@@ -98,7 +469,7 @@ func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Webhook-Signature, X-Webhook-Timestamp, X-Webhook-ID, X-Idempotency-Key")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Webhook-Signature, X-Webhook-Timestamp, X-Webhook-ID, X-Idempotency-Key, X-Request-ID")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
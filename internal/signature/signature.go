@@ -0,0 +1,113 @@
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Esquemas de firma soportados en X-Webhook-Signature
+const (
+	// SchemeLegacy firma solo el body: hex(HMAC-SHA256(secret, body))
+	SchemeLegacy = "v0"
+	// SchemeV1 firma el timestamp junto con el body: hex(HMAC-SHA256(secret, timestamp + "." + body))
+	SchemeV1 = "v1"
+)
+
+// Signature es una firma individual del header X-Webhook-Signature
+type Signature struct {
+	Scheme string
+	Value  string
+}
+
+// ParseHeader interpreta el header X-Webhook-Signature. Formatos aceptados:
+//
+//	<hex>                 firma legacy (v0) sin prefijo
+//	v1=<hex>              firma con esquema explícito
+//	v0=<hex>,v1=<hex>     varias firmas separadas por coma o espacio (migración)
+func ParseHeader(header string) []Signature {
+	fields := strings.FieldsFunc(header, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+
+	signatures := make([]Signature, 0, len(fields))
+	for _, field := range fields {
+		scheme, value, found := strings.Cut(field, "=")
+		if !found {
+			signatures = append(signatures, Signature{Scheme: SchemeLegacy, Value: field})
+			continue
+		}
+		signatures = append(signatures, Signature{Scheme: scheme, Value: value})
+	}
+	return signatures
+}
+
+// Compute calcula la firma hex de body con el esquema indicado
+func Compute(scheme string, secret []byte, timestamp string, body []byte) (string, error) {
+	mac := hmac.New(sha256.New, secret)
+	switch scheme {
+	case SchemeLegacy:
+		mac.Write(body)
+	case SchemeV1:
+		mac.Write([]byte(timestamp))
+		mac.Write([]byte("."))
+		mac.Write(body)
+	default:
+		return "", fmt.Errorf("unsupported signature scheme %q", scheme)
+	}
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Header construye el valor de X-Webhook-Signature con una firma por cada esquema
+func Header(secret []byte, timestamp string, body []byte, schemes ...string) (string, error) {
+	parts := make([]string, 0, len(schemes))
+	for _, scheme := range schemes {
+		value, err := Compute(scheme, secret, timestamp, body)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, scheme+"="+value)
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// Verify indica si alguna de las firmas, de un esquema aceptado, corresponde a body.
+// Todas las comparaciones se hacen en tiempo constante y sin cortar al primer acierto.
+func Verify(secret []byte, timestamp string, body []byte, signatures []Signature, accepted []string) bool {
+	valid := false
+	for _, sig := range signatures {
+		if !contains(accepted, sig.Scheme) {
+			continue
+		}
+
+		expected, err := Compute(sig.Scheme, secret, timestamp, body)
+		if err != nil {
+			continue
+		}
+		if hmac.Equal([]byte(sig.Value), []byte(expected)) {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// HasAccepted indica si alguna de las firmas usa un esquema aceptado
+func HasAccepted(signatures []Signature, accepted []string) bool {
+	for _, sig := range signatures {
+		if contains(accepted, sig.Scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
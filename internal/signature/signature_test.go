@@ -0,0 +1,126 @@
+package signature
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   []Signature
+	}{
+		{name: "empty", header: "", want: []Signature{}},
+		{name: "legacy without prefix", header: "abc123", want: []Signature{{Scheme: SchemeLegacy, Value: "abc123"}}},
+		{name: "explicit scheme", header: "v1=abc123", want: []Signature{{Scheme: SchemeV1, Value: "abc123"}}},
+		{
+			name:   "comma separated",
+			header: "v0=aaa,v1=bbb",
+			want:   []Signature{{Scheme: SchemeLegacy, Value: "aaa"}, {Scheme: SchemeV1, Value: "bbb"}},
+		},
+		{
+			name:   "space separated with unknown scheme",
+			header: "v1=bbb v9=ccc",
+			want:   []Signature{{Scheme: SchemeV1, Value: "bbb"}, {Scheme: "v9", Value: "ccc"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseHeader(tt.header); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseHeader(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompute(t *testing.T) {
+	secret := []byte("secret_key")
+	body := []byte(`{"webhook_id":12345}`)
+
+	tests := []struct {
+		name      string
+		scheme    string
+		timestamp string
+		want      string
+		wantErr   bool
+	}{
+		// Valores calculados con: printf "%s" "$mensaje" | openssl dgst -sha256 -hmac secret_key
+		{name: "legacy ignores the timestamp", scheme: SchemeLegacy, timestamp: "2024-01-15T10:30:00Z",
+			want: "4dde43d104b5091382bdfde52f3d124ab7dba33aed706a1040cc31f7b7087c25"},
+		{name: "v1 covers the timestamp", scheme: SchemeV1, timestamp: "2024-01-15T10:30:00Z",
+			want: "a7531c845fa21c7645ca57fbcb93aaabff5af94521c7550aaa0cdcc8442da02a"},
+		{name: "unknown scheme", scheme: "v9", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Compute(tt.scheme, secret, tt.timestamp, body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Compute() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Compute() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	secret := []byte("secret_key")
+	body := []byte(`{"webhook_id":12345}`)
+	const timestamp = "2024-01-15T10:30:00Z"
+	both, err := Header(secret, timestamp, body, SchemeLegacy, SchemeV1)
+	if err != nil {
+		t.Fatalf("Header() error = %v", err)
+	}
+	legacy, _ := Compute(SchemeLegacy, secret, timestamp, body)
+	v1, _ := Compute(SchemeV1, secret, timestamp, body)
+
+	tests := []struct {
+		name      string
+		secret    string // vacío = secret_key
+		header    string
+		timestamp string
+		accepted  []string
+		want      bool
+	}{
+		{name: "legacy without prefix", header: legacy, timestamp: timestamp, accepted: []string{SchemeLegacy}, want: true},
+		{name: "v1", header: "v1=" + v1, timestamp: timestamp, accepted: []string{SchemeV1}, want: true},
+		{name: "v1 with another timestamp", header: "v1=" + v1, timestamp: "2024-01-15T10:31:00Z", accepted: []string{SchemeV1}},
+		{name: "legacy with another timestamp", header: legacy, timestamp: "2024-01-15T10:31:00Z", accepted: []string{SchemeLegacy}, want: true},
+		{name: "scheme not accepted", header: legacy, timestamp: timestamp, accepted: []string{SchemeV1}},
+		{name: "both schemes during migration", header: both, timestamp: timestamp, accepted: []string{SchemeV1}, want: true},
+		{name: "one valid among invalid", header: "v1=deadbeef,v1=" + v1, timestamp: timestamp, accepted: []string{SchemeV1}, want: true},
+		{name: "invalid", header: "v1=deadbeef", timestamp: timestamp, accepted: []string{SchemeLegacy, SchemeV1}},
+		{name: "other secret", secret: "other_key", header: "v1=" + v1, timestamp: timestamp, accepted: []string{SchemeV1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := secret
+			if tt.secret != "" {
+				key = []byte(tt.secret)
+			}
+			if got := Verify(key, tt.timestamp, body, ParseHeader(tt.header), tt.accepted); got != tt.want {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasAccepted(t *testing.T) {
+	tests := []struct {
+		header   string
+		accepted []string
+		want     bool
+	}{
+		{header: "abc", accepted: []string{SchemeLegacy, SchemeV1}, want: true},
+		{header: "abc", accepted: []string{SchemeV1}},
+		{header: "v9=abc,v1=def", accepted: []string{SchemeV1}, want: true},
+		{header: "", accepted: []string{SchemeLegacy, SchemeV1}},
+	}
+	for _, tt := range tests {
+		if got := HasAccepted(ParseHeader(tt.header), tt.accepted); got != tt.want {
+			t.Errorf("HasAccepted(%q, %v) = %v, want %v", tt.header, tt.accepted, got, tt.want)
+		}
+	}
+}
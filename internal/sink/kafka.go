@@ -0,0 +1,52 @@
+package sink
+
+import (
+	"context"
+	"errors"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaSink publica en Kafka. Los mensajes con la misma clave (contract_id) van a la
+// misma partición, así que los eventos de un contrato se consumen en orden.
+type KafkaSink struct {
+	writer *kafka.Writer
+}
+
+// NewKafkaSink crea un sink para brokers (host:port). Cada publicación espera la
+// confirmación de todas las réplicas en sincronía.
+func NewKafkaSink(brokers []string) (*KafkaSink, error) {
+	if len(brokers) == 0 {
+		return nil, errors.New("at least one Kafka broker is required")
+	}
+	return &KafkaSink{
+		writer: &kafka.Writer{
+			Addr:                   kafka.TCP(brokers...),
+			Balancer:               &kafka.Hash{},
+			RequiredAcks:           kafka.RequireAll,
+			AllowAutoTopicCreation: false,
+			// Publicar de inmediato: el webhook espera la confirmación
+			BatchSize: 1,
+		},
+	}, nil
+}
+
+// Publish envía msg y espera la confirmación del broker
+func (s *KafkaSink) Publish(ctx context.Context, msg Message) error {
+	headers := make([]kafka.Header, 0, len(msg.Headers))
+	for key, value := range msg.Headers {
+		headers = append(headers, kafka.Header{Key: key, Value: []byte(value)})
+	}
+
+	return s.writer.WriteMessages(ctx, kafka.Message{
+		Topic:   msg.Topic,
+		Key:     []byte(msg.Key),
+		Value:   msg.Value,
+		Headers: headers,
+	})
+}
+
+// Close envía los mensajes pendientes y cierra las conexiones
+func (s *KafkaSink) Close() error {
+	return s.writer.Close()
+}
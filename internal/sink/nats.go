@@ -0,0 +1,56 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSSink publica en NATS JetStream. El subject es el topic seguido de la clave
+// (por ejemplo bia.webhooks.bills.2001), así que el stream debe capturar "<topic>.>"
+// y los consumidores pueden filtrar por contrato.
+type NATSSink struct {
+	conn *nats.Conn
+	js   nats.JetStreamContext
+}
+
+// NewNATSSink se conecta a url (por ejemplo nats://localhost:4222)
+func NewNATSSink(url string, opts ...nats.Option) (*NATSSink, error) {
+	conn, err := nats.Connect(url, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create JetStream context: %w", err)
+	}
+	return &NATSSink{conn: conn, js: js}, nil
+}
+
+// Publish envía msg y espera el ack de JetStream. La clave de idempotencia se usa como
+// Nats-Msg-Id para que JetStream descarte las publicaciones repetidas.
+func (s *NATSSink) Publish(ctx context.Context, msg Message) error {
+	subject := msg.Topic
+	if msg.Key != "" {
+		subject += "." + msg.Key
+	}
+
+	natsMsg := nats.NewMsg(subject)
+	natsMsg.Data = msg.Value
+	for key, value := range msg.Headers {
+		natsMsg.Header.Set(key, value)
+	}
+	if id := msg.Headers["idempotency_key"]; id != "" {
+		natsMsg.Header.Set(nats.MsgIdHdr, id)
+	}
+
+	_, err := s.js.PublishMsg(natsMsg, nats.Context(ctx))
+	return err
+}
+
+// Close vacía los mensajes pendientes y cierra la conexión
+func (s *NATSSink) Close() error {
+	return s.conn.Drain()
+}
@@ -0,0 +1,166 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"webhook_receiver/internal/cloudevents"
+	"webhook_receiver/internal/processor"
+	"webhook_receiver/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultTopicPrefix se antepone al data_type para obtener el topic de cada evento
+const DefaultTopicPrefix = "bia.webhooks."
+
+// DefaultPublishTimeout es el tiempo máximo para que el broker confirme una publicación
+const DefaultPublishTimeout = 10 * time.Second
+
+// Message es un evento listo para publicar en un broker
+type Message struct {
+	Topic   string
+	Key     string // clave de partición (contract_id)
+	Value   []byte
+	Headers map[string]string
+}
+
+// Sink publica mensajes en un broker. Publish debe retornar solo cuando el broker
+// confirmó el mensaje, para que el webhook no se confirme antes de estar publicado.
+type Sink interface {
+	Publish(ctx context.Context, msg Message) error
+	Close() error
+}
+
+// Publisher convierte los eventos procesados en mensajes y los publica en un Sink
+type Publisher struct {
+	sink    Sink
+	prefix  string
+	topics  map[string]string
+	timeout time.Duration
+	// Modo CloudEvents y prefijo de los headers de los atributos en modo binario
+	mode         cloudevents.Mode
+	headerPrefix string
+}
+
+// PublisherOption configura opciones adicionales del Publisher
+type PublisherOption func(*Publisher)
+
+// WithTopicPrefix define el prefijo de los topics (topic = prefijo + data_type)
+func WithTopicPrefix(prefix string) PublisherOption {
+	return func(p *Publisher) {
+		p.prefix = prefix
+	}
+}
+
+// WithTopic publica los eventos de dataType en topic en lugar del topic por defecto
+func WithTopic(dataType, topic string) PublisherOption {
+	return func(p *Publisher) {
+		p.topics[dataType] = topic
+	}
+}
+
+// WithPublishTimeout define el tiempo máximo de espera de la confirmación del broker
+func WithPublishTimeout(timeout time.Duration) PublisherOption {
+	return func(p *Publisher) {
+		if timeout > 0 {
+			p.timeout = timeout
+		}
+	}
+}
+
+// WithCloudEvents publica cada evento como CloudEvent en mode. En modo binario los atributos
+// van en headers con headerPrefix (cloudevents.KafkaHeaderPrefix en Kafka, cloudevents.HTTPHeaderPrefix en NATS).
+func WithCloudEvents(mode cloudevents.Mode, headerPrefix string) PublisherOption {
+	return func(p *Publisher) {
+		p.mode = mode
+		p.headerPrefix = headerPrefix
+	}
+}
+
+// NewPublisher crea una nueva instancia del Publisher
+func NewPublisher(sink Sink, opts ...PublisherOption) *Publisher {
+	p := &Publisher{
+		sink:    sink,
+		prefix:  DefaultTopicPrefix,
+		topics:  make(map[string]string),
+		timeout: DefaultPublishTimeout,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Topic retorna el topic en el que se publican los eventos de dataType
+func (p *Publisher) Topic(dataType string) string {
+	if topic, ok := p.topics[dataType]; ok {
+		return topic
+	}
+	return p.prefix + dataType
+}
+
+// Publish publica event y espera la confirmación del broker. Implementa processor.Hook.
+// Un error es reintentable: el webhook no se confirma y bia-consumptions lo vuelve a enviar.
+func (p *Publisher) Publish(ctx context.Context, event processor.Event) (err error) {
+	topic := p.Topic(event.DataType)
+	ctx, span := tracing.Start(ctx, "publish "+topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(tracing.EventAttributes(event.DataType, event.TriggerType, event.WebhookID, event.ContractID)...),
+		trace.WithAttributes(attribute.String("messaging.destination.name", topic)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	msg, err := p.message(event)
+	if err != nil {
+		return processor.Permanent(fmt.Errorf("failed to encode %s event: %w", event.DataType, err))
+	}
+	// Propagar la traza a los consumidores en los headers del mensaje
+	tracing.Inject(ctx, propagation.MapCarrier(msg.Headers))
+
+	if err := p.sink.Publish(ctx, msg); err != nil {
+		return processor.Retryable(fmt.Errorf("failed to publish %s event to %s: %w", event.DataType, msg.Topic, err))
+	}
+	return nil
+}
+
+// message arma el mensaje de event: el body original (o el CloudEvent) como valor y el
+// contract_id como clave
+func (p *Publisher) message(event processor.Event) (Message, error) {
+	msg := Message{
+		Topic: p.Topic(event.DataType),
+		Key:   strconv.Itoa(event.ContractID),
+		Value: event.Body,
+		Headers: map[string]string{
+			"data_type":  event.DataType,
+			"webhook_id": strconv.Itoa(event.WebhookID),
+		},
+	}
+	if event.TriggerType != "" {
+		msg.Headers["trigger_type"] = event.TriggerType
+	}
+	if event.Headers.IDKey != "" {
+		msg.Headers["idempotency_key"] = event.Headers.IDKey
+	}
+
+	if p.mode == cloudevents.ModeNone {
+		return msg, nil
+	}
+	encoded, err := cloudevents.FromEvent(event).Encode(p.mode, p.headerPrefix)
+	if err != nil {
+		return Message{}, err
+	}
+	msg.Value = encoded.Body
+	msg.Headers["content-type"] = encoded.ContentType
+	for name, value := range encoded.Headers {
+		msg.Headers[name] = value
+	}
+	return msg, nil
+}
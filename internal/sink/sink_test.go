@@ -0,0 +1,131 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"webhook_receiver/internal/cloudevents"
+	"webhook_receiver/internal/dto"
+	"webhook_receiver/internal/processor"
+)
+
+// fakeSink guarda los mensajes publicados y retorna err en cada Publish
+type fakeSink struct {
+	messages []Message
+	err      error
+}
+
+func (s *fakeSink) Publish(ctx context.Context, msg Message) error {
+	if _, ok := ctx.Deadline(); !ok {
+		return errors.New("publish without deadline")
+	}
+	if s.err != nil {
+		return s.err
+	}
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+func (s *fakeSink) Close() error {
+	return nil
+}
+
+func TestPublisherPublish(t *testing.T) {
+	body := []byte(`{"webhook_id":67890,"data_type":"bills","trigger_type":"paid"}`)
+	event := processor.Event{
+		DataType:    "bills",
+		TriggerType: "paid",
+		WebhookID:   67890,
+		ContractID:  2001,
+		Body:        body,
+		Payload:     dto.BillWebhookPayload{TriggerType: "paid"},
+		Headers:     dto.WebhookHeaders{IDKey: "key-1"},
+	}
+
+	tests := []struct {
+		name        string
+		opts        []PublisherOption
+		wantTopic   string
+		wantHeaders map[string]string
+		checkValue  func(t *testing.T, value []byte)
+	}{
+		{
+			name:        "default topic and original body",
+			wantTopic:   "bia.webhooks.bills",
+			wantHeaders: map[string]string{"data_type": "bills", "trigger_type": "paid", "webhook_id": "67890", "idempotency_key": "key-1"},
+			checkValue: func(t *testing.T, value []byte) {
+				if string(value) != string(body) {
+					t.Errorf("value = %s, want the original body", value)
+				}
+			},
+		},
+		{
+			name:      "topic prefix",
+			opts:      []PublisherOption{WithTopicPrefix("events.")},
+			wantTopic: "events.bills",
+		},
+		{
+			name:      "topic per data type",
+			opts:      []PublisherOption{WithTopicPrefix("events."), WithTopic("bills", "billing.events")},
+			wantTopic: "billing.events",
+		},
+		{
+			name:        "structured cloudevent",
+			opts:        []PublisherOption{WithCloudEvents(cloudevents.ModeStructured, cloudevents.KafkaHeaderPrefix)},
+			wantTopic:   "bia.webhooks.bills",
+			wantHeaders: map[string]string{"content-type": cloudevents.ContentTypeStructured},
+			checkValue: func(t *testing.T, value []byte) {
+				var ce map[string]interface{}
+				if err := json.Unmarshal(value, &ce); err != nil {
+					t.Fatalf("value is not a CloudEvent: %v", err)
+				}
+				if ce["type"] != cloudevents.TypePrefix+"bill.paid" || ce["id"] != "key-1" {
+					t.Errorf("CloudEvent type = %v, id = %v", ce["type"], ce["id"])
+				}
+			},
+		},
+		{
+			name:        "binary cloudevent",
+			opts:        []PublisherOption{WithCloudEvents(cloudevents.ModeBinary, cloudevents.KafkaHeaderPrefix)},
+			wantTopic:   "bia.webhooks.bills",
+			wantHeaders: map[string]string{"ce_type": cloudevents.TypePrefix + "bill.paid", "ce_id": "key-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeSink{}
+			if err := NewPublisher(fake, tt.opts...).Publish(context.Background(), event); err != nil {
+				t.Fatalf("Publish() error = %v", err)
+			}
+			if len(fake.messages) != 1 {
+				t.Fatalf("published %d messages, want 1", len(fake.messages))
+			}
+
+			msg := fake.messages[0]
+			if msg.Topic != tt.wantTopic {
+				t.Errorf("topic = %q, want %q", msg.Topic, tt.wantTopic)
+			}
+			if msg.Key != "2001" {
+				t.Errorf("key = %q, want the contract_id", msg.Key)
+			}
+			for name, want := range tt.wantHeaders {
+				if got := msg.Headers[name]; got != want {
+					t.Errorf("header %s = %q, want %q", name, got, want)
+				}
+			}
+			if tt.checkValue != nil {
+				tt.checkValue(t, msg.Value)
+			}
+		})
+	}
+}
+
+func TestPublisherPublishError(t *testing.T) {
+	fake := &fakeSink{err: errors.New("broker unavailable")}
+	err := NewPublisher(fake).Publish(context.Background(), processor.Event{DataType: "consumption"})
+	if err == nil || !processor.IsRetryable(err) {
+		t.Fatalf("Publish() error = %v, want retryable", err)
+	}
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"webhook_receiver/internal/dto"
+)
+
+// Estados del ciclo de vida de una factura
+const (
+	BillStateAvailable = "available"
+	BillStatePaid      = "paid"
+)
+
+// Resultado de aplicar un evento a una factura (columna outcome de bill_events)
+const (
+	BillOutcomeApplied  = "applied"  // el evento cambió la factura
+	BillOutcomeIgnored  = "ignored"  // duplicado o más antiguo que el estado guardado
+	BillOutcomeRejected = "rejected" // transición ilegal
+)
+
+var (
+	// ErrIllegalTransition envuelve todas las transiciones de estado no permitidas
+	ErrIllegalTransition = errors.New("illegal bill transition")
+	// ErrPaidBeforeAvailable indica un pago con timestamp anterior a la disponibilidad de la factura
+	ErrPaidBeforeAvailable = errors.New("paid before available")
+	// ErrPaymentConflict indica un segundo pago con otro transaction_id
+	ErrPaymentConflict = errors.New("bill already paid with a different transaction_id")
+	// ErrTotalChanged indica un evento cuyo total no coincide con el guardado
+	ErrTotalChanged = errors.New("bill total changed")
+	// ErrMissingPayment indica un evento paid sin datos de pago
+	ErrMissingPayment = errors.New("paid event without payment data")
+	// ErrUnknownTrigger indica un trigger_type sin transición definida
+	ErrUnknownTrigger = errors.New("unknown trigger_type")
+)
+
+// Bill es el estado guardado de una factura
+type Bill struct {
+	BillID     int
+	ContractID int
+	Period     string
+	Total      dto.Decimal
+	Status     string // status reportado en el payload
+	XmlUrl     string
+	State      string // BillStateAvailable o BillStatePaid
+	// AvailableAt y PaidAt son los timestamps (del payload) de los primeros eventos de cada estado.
+	// Un pago recibido antes que su evento available deja AvailableAt en nil hasta que llegue.
+	AvailableAt *time.Time
+	PaidAt      *time.Time
+	Payment     *dto.PaymentWebhookData
+	UpdatedAt   time.Time
+}
+
+// AwaitingAvailable indica que la factura se pagó pero aún no llegó su evento available
+func (b Bill) AwaitingAvailable() bool {
+	return b.State == BillStatePaid && b.AvailableAt == nil
+}
+
+// transitionBill aplica el evento payload a current (nil si la factura no existe).
+// El orden se resuelve con payload.Timestamp y no con el orden de llegada: un evento
+// available que llega después del pago completa la factura sin retroceder su estado.
+// Retorna la factura resultante y el outcome; las transiciones ilegales retornan un
+// error que envuelve ErrIllegalTransition.
+func transitionBill(current *Bill, payload dto.BillWebhookPayload, now time.Time) (Bill, string, error) {
+	eventAt := payload.Timestamp.UTC()
+
+	var next Bill
+	if current != nil {
+		next = *current
+		if !current.Total.Equal(payload.Bill.Total) {
+			return next, BillOutcomeRejected, illegal(fmt.Errorf("%w: stored %s, received %s", ErrTotalChanged,
+				current.Total, payload.Bill.Total))
+		}
+	} else {
+		next = Bill{BillID: payload.Bill.BillID, Total: payload.Bill.Total}
+	}
+
+	switch payload.TriggerType {
+	case dto.TriggerTypeAvailable:
+		if next.AvailableAt == nil && next.PaidAt != nil && next.PaidAt.Before(eventAt) {
+			return next, BillOutcomeRejected, illegal(fmt.Errorf("%w: paid at %s, available at %s", ErrPaidBeforeAvailable,
+				next.PaidAt.Format(time.RFC3339), eventAt.Format(time.RFC3339)))
+		}
+		if next.AvailableAt != nil && !eventAt.Before(*next.AvailableAt) {
+			// Duplicado o reenvío posterior: la factura ya estaba disponible
+			return next, BillOutcomeIgnored, nil
+		}
+
+		// AvailableAt guarda el primer evento available, aunque llegue después de otros
+		next.AvailableAt = &eventAt
+		if next.State == "" {
+			next.State = BillStateAvailable
+		}
+
+	case dto.TriggerTypePaid:
+		if payload.Payment == nil {
+			return next, BillOutcomeRejected, illegal(ErrMissingPayment)
+		}
+		if next.Payment != nil {
+			if next.Payment.TransactionID != payload.Payment.TransactionID {
+				return next, BillOutcomeRejected, illegal(fmt.Errorf("%w: stored %d, received %d", ErrPaymentConflict,
+					next.Payment.TransactionID, payload.Payment.TransactionID))
+			}
+			return next, BillOutcomeIgnored, nil
+		}
+		if next.AvailableAt != nil && eventAt.Before(*next.AvailableAt) {
+			return next, BillOutcomeRejected, illegal(fmt.Errorf("%w: available at %s, paid at %s", ErrPaidBeforeAvailable,
+				next.AvailableAt.Format(time.RFC3339), eventAt.Format(time.RFC3339)))
+		}
+
+		payment := *payload.Payment
+		next.PaidAt = &eventAt
+		next.Payment = &payment
+		next.State = BillStatePaid
+
+	default:
+		return next, BillOutcomeRejected, illegal(fmt.Errorf("%w: %q", ErrUnknownTrigger, payload.TriggerType))
+	}
+
+	// Los datos descriptivos se toman del evento más reciente
+	if current == nil || !eventAt.Before(latest(current)) {
+		next.ContractID = payload.Bill.ContractID
+		next.Period = payload.Bill.Period
+		next.Status = payload.Bill.Status
+		next.XmlUrl = payload.Bill.XmlUrl
+	}
+	next.UpdatedAt = now.UTC()
+	return next, BillOutcomeApplied, nil
+}
+
+// latest retorna el timestamp del evento más reciente aplicado a b
+func latest(b *Bill) time.Time {
+	var t time.Time
+	if b.AvailableAt != nil {
+		t = *b.AvailableAt
+	}
+	if b.PaidAt != nil && b.PaidAt.After(t) {
+		t = *b.PaidAt
+	}
+	return t
+}
+
+func illegal(err error) error {
+	return fmt.Errorf("%w: %w", ErrIllegalTransition, err)
+}
@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"webhook_receiver/internal/dto"
+)
+
+// billEvent arma un evento de la factura 1001. Los eventos paid llevan el pago transactionID;
+// con transactionID 0 el pago no viene.
+func billEvent(trigger, timestamp, total string, transactionID int) dto.BillWebhookPayload {
+	at := mustTime(timestamp)
+	payload := dto.BillWebhookPayload{
+		WebhookID:   7,
+		DataType:    "bills",
+		TriggerType: trigger,
+		Bill: dto.BillWebhookData{
+			BillID:     1001,
+			ContractID: 2001,
+			Period:     "2025-10",
+			Total:      dto.MustParseDecimal(total),
+			Status:     trigger,
+			XmlUrl:     "https://example.com/" + trigger + ".xml",
+		},
+		Timestamp: at,
+	}
+	if trigger == dto.TriggerTypePaid && transactionID != 0 {
+		payload.Payment = &dto.PaymentWebhookData{PaymentDate: at, TransactionID: transactionID, PaymentMethod: "PSE"}
+	}
+	return payload
+}
+
+func TestTransitionBill(t *testing.T) {
+	const (
+		t1 = "2025-10-01T10:00:00Z"
+		t2 = "2025-10-05T10:00:00Z"
+		t3 = "2025-10-09T10:00:00Z"
+	)
+	available := func(at string) dto.BillWebhookPayload { return billEvent(dto.TriggerTypeAvailable, at, "1250.75", 0) }
+	paid := func(at string, transactionID int) dto.BillWebhookPayload {
+		return billEvent(dto.TriggerTypePaid, at, "1250.75", transactionID)
+	}
+
+	tests := []struct {
+		name string
+		// events se aplican en orden de llegada; se verifica el resultado del último
+		events      []dto.BillWebhookPayload
+		wantOutcome string
+		wantErrIs   error
+		check       func(t *testing.T, bill Bill)
+	}{
+		{
+			name:        "available creates the bill",
+			events:      []dto.BillWebhookPayload{available(t1)},
+			wantOutcome: BillOutcomeApplied,
+			check: func(t *testing.T, bill Bill) {
+				if bill.State != BillStateAvailable || bill.AvailableAt == nil || bill.PaidAt != nil {
+					t.Errorf("bill = %+v, want available", bill)
+				}
+			},
+		},
+		{
+			name:        "paid after available",
+			events:      []dto.BillWebhookPayload{available(t1), paid(t2, 99)},
+			wantOutcome: BillOutcomeApplied,
+			check: func(t *testing.T, bill Bill) {
+				if bill.State != BillStatePaid || bill.Payment == nil || bill.Payment.TransactionID != 99 || bill.AwaitingAvailable() {
+					t.Errorf("bill = %+v, want paid with transaction 99", bill)
+				}
+				if bill.Status != dto.TriggerTypePaid || bill.XmlUrl != "https://example.com/paid.xml" {
+					t.Errorf("descriptive data = %q %q, want the paid event's", bill.Status, bill.XmlUrl)
+				}
+			},
+		},
+		{
+			name:        "paid timestamped before a stored available",
+			events:      []dto.BillWebhookPayload{available(t2), paid(t1, 99)},
+			wantOutcome: BillOutcomeRejected,
+			wantErrIs:   ErrPaidBeforeAvailable,
+		},
+		{
+			name:        "available timestamped after a stored paid",
+			events:      []dto.BillWebhookPayload{paid(t1, 99), available(t2)},
+			wantOutcome: BillOutcomeRejected,
+			wantErrIs:   ErrPaidBeforeAvailable,
+		},
+		{
+			name:        "paid first awaits available",
+			events:      []dto.BillWebhookPayload{paid(t2, 99)},
+			wantOutcome: BillOutcomeApplied,
+			check: func(t *testing.T, bill Bill) {
+				if !bill.AwaitingAvailable() {
+					t.Errorf("bill = %+v, want AwaitingAvailable", bill)
+				}
+			},
+		},
+		{
+			name:        "out-of-order available completes a paid bill",
+			events:      []dto.BillWebhookPayload{paid(t2, 99), available(t1)},
+			wantOutcome: BillOutcomeApplied,
+			check: func(t *testing.T, bill Bill) {
+				if bill.State != BillStatePaid || bill.AwaitingAvailable() || !bill.AvailableAt.Equal(mustTime(t1)) {
+					t.Errorf("bill = %+v, want paid with available_at %s", bill, t1)
+				}
+				// El evento available es más antiguo: los datos descriptivos siguen siendo los del pago
+				if bill.Status != dto.TriggerTypePaid {
+					t.Errorf("status = %q, want the paid event's", bill.Status)
+				}
+			},
+		},
+		{
+			name:        "second paid with another transaction_id",
+			events:      []dto.BillWebhookPayload{available(t1), paid(t2, 99), paid(t3, 100)},
+			wantOutcome: BillOutcomeRejected,
+			wantErrIs:   ErrPaymentConflict,
+		},
+		{
+			name:        "duplicate paid",
+			events:      []dto.BillWebhookPayload{available(t1), paid(t2, 99), paid(t2, 99)},
+			wantOutcome: BillOutcomeIgnored,
+		},
+		{
+			name:        "duplicate available",
+			events:      []dto.BillWebhookPayload{available(t1), available(t1)},
+			wantOutcome: BillOutcomeIgnored,
+		},
+		{
+			name:        "earlier available replaces available_at",
+			events:      []dto.BillWebhookPayload{available(t2), available(t1)},
+			wantOutcome: BillOutcomeApplied,
+			check: func(t *testing.T, bill Bill) {
+				if !bill.AvailableAt.Equal(mustTime(t1)) {
+					t.Errorf("available_at = %v, want %s", bill.AvailableAt, t1)
+				}
+			},
+		},
+		{
+			name:        "paid without payment",
+			events:      []dto.BillWebhookPayload{available(t1), paid(t2, 0)},
+			wantOutcome: BillOutcomeRejected,
+			wantErrIs:   ErrMissingPayment,
+		},
+		{
+			name:        "changed total",
+			events:      []dto.BillWebhookPayload{available(t1), billEvent(dto.TriggerTypePaid, t2, "1250.70", 99)},
+			wantOutcome: BillOutcomeRejected,
+			wantErrIs:   ErrTotalChanged,
+		},
+		{
+			name:        "same total with another scale",
+			events:      []dto.BillWebhookPayload{available(t1), billEvent(dto.TriggerTypePaid, t2, "1250.750", 99)},
+			wantOutcome: BillOutcomeApplied,
+		},
+		{
+			name:        "unknown trigger type",
+			events:      []dto.BillWebhookPayload{billEvent("cancelled", t1, "1250.75", 0)},
+			wantOutcome: BillOutcomeRejected,
+			wantErrIs:   ErrUnknownTrigger,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				current *Bill
+				bill    Bill
+				outcome string
+				err     error
+			)
+			now := time.Date(2025, 10, 10, 0, 0, 0, 0, time.UTC)
+			for i, event := range tt.events {
+				bill, outcome, err = transitionBill(current, event, now)
+				if i < len(tt.events)-1 && outcome != BillOutcomeApplied {
+					t.Fatalf("event %d: outcome = %s (%v), want applied", i+1, outcome, err)
+				}
+				// Como Apply, solo se guarda la factura de los eventos aplicados
+				if outcome == BillOutcomeApplied {
+					saved := bill
+					current = &saved
+				}
+			}
+
+			if outcome != tt.wantOutcome {
+				t.Errorf("outcome = %s, want %s", outcome, tt.wantOutcome)
+			}
+			if tt.wantErrIs != nil {
+				if !errors.Is(err, tt.wantErrIs) || !errors.Is(err, ErrIllegalTransition) {
+					t.Errorf("error = %v, want an illegal transition wrapping %v", err, tt.wantErrIs)
+				}
+			} else if err != nil {
+				t.Errorf("error = %v", err)
+			}
+			if tt.check != nil {
+				tt.check(t, bill)
+			}
+		})
+	}
+}
+
+func mustTime(value string) time.Time {
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+	return at
+}
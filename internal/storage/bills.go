@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"webhook_receiver/internal/dto"
+)
+
+// ErrBillNotFound se retorna cuando no existe una factura con el bill_id indicado
+var ErrBillNotFound = errors.New("bill not found")
+
+// BillRepository guarda el ciclo de vida de las facturas en SQLite
+type BillRepository struct {
+	db *sql.DB
+}
+
+// NewBillRepository crea un repositorio sobre una base abierta con Open
+func NewBillRepository(db *sql.DB) *BillRepository {
+	return &BillRepository{
+		db: db,
+	}
+}
+
+// Apply aplica un evento available o paid a su factura y lo registra en bill_events.
+// Las transiciones ilegales se registran como rejected y retornan un error que envuelve
+// ErrIllegalTransition; los duplicados y eventos antiguos se registran como ignored.
+func (r *BillRepository) Apply(ctx context.Context, payload dto.BillWebhookPayload, receivedAt time.Time) (Bill, string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Bill{}, "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := getBill(ctx, tx, payload.Bill.BillID)
+	if err != nil && !errors.Is(err, ErrBillNotFound) {
+		return Bill{}, "", err
+	}
+	var currentPtr *Bill
+	if err == nil {
+		currentPtr = &current
+	}
+
+	next, outcome, transitionErr := transitionBill(currentPtr, payload, time.Now())
+	if outcome == BillOutcomeApplied {
+		if err := saveBill(ctx, tx, next); err != nil {
+			return Bill{}, "", err
+		}
+	}
+
+	reason := ""
+	if transitionErr != nil {
+		reason = transitionErr.Error()
+	}
+	if _, err := tx.ExecContext(ctx, `
+INSERT INTO bill_events (bill_id, trigger_type, event_timestamp, webhook_id, outcome, reason, received_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		payload.Bill.BillID, payload.TriggerType, payload.Timestamp.UTC(), payload.WebhookID, outcome, reason, receivedAt.UTC(),
+	); err != nil {
+		return Bill{}, "", fmt.Errorf("failed to record bill event: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Bill{}, "", fmt.Errorf("failed to commit bill %d: %w", payload.Bill.BillID, err)
+	}
+	return next, outcome, transitionErr
+}
+
+// Get retorna el estado guardado de una factura
+func (r *BillRepository) Get(ctx context.Context, billID int) (Bill, error) {
+	return getBill(ctx, r.db, billID)
+}
+
+// queryer es la parte común de *sql.DB y *sql.Tx que usan las consultas de facturas
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func getBill(ctx context.Context, q queryer, billID int) (Bill, error) {
+	var (
+		bill          Bill
+		availableAt   sql.NullTime
+		paidAt        sql.NullTime
+		paymentDate   sql.NullTime
+		transactionID sql.NullInt64
+		paymentMethod sql.NullString
+	)
+	err := q.QueryRowContext(ctx, `
+SELECT bill_id, contract_id, period, total, status, xml_url, state,
+	available_at, paid_at, payment_date, transaction_id, payment_method, updated_at
+FROM bills WHERE bill_id = ?`, billID).Scan(
+		&bill.BillID, &bill.ContractID, &bill.Period, &bill.Total, &bill.Status, &bill.XmlUrl, &bill.State,
+		&availableAt, &paidAt, &paymentDate, &transactionID, &paymentMethod, &bill.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Bill{}, fmt.Errorf("%w: %d", ErrBillNotFound, billID)
+	}
+	if err != nil {
+		return Bill{}, fmt.Errorf("failed to read bill %d: %w", billID, err)
+	}
+
+	if availableAt.Valid {
+		bill.AvailableAt = &availableAt.Time
+	}
+	if paidAt.Valid {
+		bill.PaidAt = &paidAt.Time
+	}
+	if paymentDate.Valid {
+		bill.Payment = &dto.PaymentWebhookData{
+			PaymentDate:   paymentDate.Time,
+			TransactionID: int(transactionID.Int64),
+			PaymentMethod: paymentMethod.String,
+		}
+	}
+	return bill, nil
+}
+
+func saveBill(ctx context.Context, q queryer, bill Bill) error {
+	var (
+		paymentDate   sql.NullTime
+		transactionID sql.NullInt64
+		paymentMethod sql.NullString
+	)
+	if bill.Payment != nil {
+		paymentDate = sql.NullTime{Time: bill.Payment.PaymentDate.UTC(), Valid: true}
+		transactionID = sql.NullInt64{Int64: int64(bill.Payment.TransactionID), Valid: true}
+		paymentMethod = sql.NullString{String: bill.Payment.PaymentMethod, Valid: true}
+	}
+
+	_, err := q.ExecContext(ctx, `
+INSERT INTO bills (
+	bill_id, contract_id, period, total, status, xml_url, state,
+	available_at, paid_at, payment_date, transaction_id, payment_method, updated_at
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (bill_id) DO UPDATE SET
+	contract_id    = excluded.contract_id,
+	period         = excluded.period,
+	total          = excluded.total,
+	status         = excluded.status,
+	xml_url        = excluded.xml_url,
+	state          = excluded.state,
+	available_at   = excluded.available_at,
+	paid_at        = excluded.paid_at,
+	payment_date   = excluded.payment_date,
+	transaction_id = excluded.transaction_id,
+	payment_method = excluded.payment_method,
+	updated_at     = excluded.updated_at`,
+		bill.BillID, bill.ContractID, bill.Period, bill.Total, bill.Status, bill.XmlUrl, bill.State,
+		nullTime(bill.AvailableAt), nullTime(bill.PaidAt), paymentDate, transactionID, paymentMethod, bill.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save bill %d: %w", bill.BillID, err)
+	}
+	return nil
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
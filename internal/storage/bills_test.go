@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"webhook_receiver/internal/dto"
+)
+
+func TestBillRepositoryApply(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	repo := NewBillRepository(db)
+
+	// El pago llega antes que la disponibilidad, luego un duplicado y un segundo pago ilegal
+	events := []struct {
+		payload     dto.BillWebhookPayload
+		wantOutcome string
+		wantErrIs   error
+	}{
+		{payload: billEvent(dto.TriggerTypePaid, "2025-10-05T10:00:00Z", "1250.75", 99), wantOutcome: BillOutcomeApplied},
+		{payload: billEvent(dto.TriggerTypeAvailable, "2025-10-01T10:00:00Z", "1250.75", 0), wantOutcome: BillOutcomeApplied},
+		{payload: billEvent(dto.TriggerTypePaid, "2025-10-05T10:00:00Z", "1250.75", 99), wantOutcome: BillOutcomeIgnored},
+		{payload: billEvent(dto.TriggerTypePaid, "2025-10-06T10:00:00Z", "1250.75", 100), wantOutcome: BillOutcomeRejected, wantErrIs: ErrPaymentConflict},
+	}
+	for i, event := range events {
+		_, outcome, err := repo.Apply(ctx, event.payload, time.Now())
+		if outcome != event.wantOutcome || !errors.Is(err, event.wantErrIs) {
+			t.Fatalf("Apply(%d) = %s, %v, want %s, %v", i+1, outcome, err, event.wantOutcome, event.wantErrIs)
+		}
+	}
+
+	bill, err := repo.Get(ctx, 1001)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if bill.State != BillStatePaid || bill.AwaitingAvailable() || bill.Payment == nil || bill.Payment.TransactionID != 99 {
+		t.Errorf("bill = %+v, want paid with transaction 99 and available_at", bill)
+	}
+	if !bill.Total.Equal(dto.MustParseDecimal("1250.75")) || bill.Total.String() != "1250.75" {
+		t.Errorf("total = %s, want 1250.75", bill.Total)
+	}
+	if _, err := repo.Get(ctx, 9999); !errors.Is(err, ErrBillNotFound) {
+		t.Errorf("Get() of a missing bill error = %v, want ErrBillNotFound", err)
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT trigger_type, outcome, reason <> '' FROM bill_events WHERE bill_id = ? ORDER BY id`, 1001)
+	if err != nil {
+		t.Fatalf("query bill_events: %v", err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var trigger, outcome string
+		var hasReason bool
+		if err := rows.Scan(&trigger, &outcome, &hasReason); err != nil {
+			t.Fatalf("scan bill_events: %v", err)
+		}
+		if hasReason != (outcome == BillOutcomeRejected) {
+			t.Errorf("%s %s: has reason = %v, want a reason only for rejected events", trigger, outcome, hasReason)
+		}
+		got = append(got, trigger+":"+outcome)
+	}
+	want := []string{"paid:applied", "available:applied", "paid:ignored", "paid:rejected"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("bill_events = %v, want %v", got, want)
+	}
+}
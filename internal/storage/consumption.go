@@ -0,0 +1,241 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"webhook_receiver/internal/dto"
+)
+
+// Granularidades de las lecturas de consumo. date_and_hour se guarda como "hour".
+const (
+	GranularityHour  = "hour"
+	GranularityDay   = "day"
+	GranularityMonth = "month"
+	// GranularityHourOfPeriod es una hora del día agregada sobre un período de varios días
+	// (group_by "hour" con un período de más de un día)
+	GranularityHourOfPeriod = "hour_of_period"
+)
+
+// ConsumptionReading es una fila normalizada de consumo: una métrica por contrato, granularidad y período
+type ConsumptionReading struct {
+	ContractID  int
+	SIC         string
+	Granularity string
+	// PeriodKey identifica el período: "2025-10-08T13" (hora), "2025-10-08" (día), "2025-10"
+	// (mes) o "2025-10-01/2025-10-08T13" (hora de un período de varios días)
+	PeriodKey string
+	dto.WebhookEnergyMetrics
+	WebhookID  int
+	ReceivedAt time.Time
+	// PayloadTimestamp es el timestamp del webhook que escribió la fila; una fila solo se
+	// reemplaza con datos de un webhook igual o más reciente
+	PayloadTimestamp time.Time
+}
+
+// NormalizeConsumption convierte un webhook de consumo en lecturas. Las filas de group_by
+// "hour" no traen fecha: si el período es de un día son las horas de period.start_date; si
+// es de varios días cada hora agrega todo el período y se guarda como GranularityHourOfPeriod.
+func NormalizeConsumption(payload dto.WebhookPayload, receivedAt time.Time) ([]ConsumptionReading, error) {
+	data := payload.Data
+	reading := func(granularity, periodKey string, metrics dto.WebhookEnergyMetrics) ConsumptionReading {
+		return ConsumptionReading{
+			ContractID:           data.ContractID,
+			SIC:                  data.SIC,
+			Granularity:          granularity,
+			PeriodKey:            periodKey,
+			WebhookEnergyMetrics: metrics,
+			WebhookID:            payload.WebhookID,
+			ReceivedAt:           receivedAt.UTC(),
+			PayloadTimestamp:     payload.Timestamp.UTC(),
+		}
+	}
+
+	var readings []ConsumptionReading
+	switch payload.GroupBy {
+	case dto.GroupByHour:
+		hours, _ := data.HourlyConsumption()
+		if len(hours) == 0 {
+			break
+		}
+		granularity, prefix, err := hourlyPeriod(payload.Period)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range hours {
+			readings = append(readings, reading(granularity, hourKey(prefix, h.Hour), h.WebhookEnergyMetrics))
+		}
+	case dto.GroupByDay:
+		days, _ := data.DailyConsumption()
+		for _, d := range days {
+			readings = append(readings, reading(GranularityDay, d.Date, d.WebhookEnergyMetrics))
+		}
+	case dto.GroupByMonth:
+		months, _ := data.MonthlyConsumption()
+		for _, m := range months {
+			readings = append(readings, reading(GranularityMonth, m.Month, m.WebhookEnergyMetrics))
+		}
+	case dto.GroupByDateAndHour:
+		dates, _ := data.DateAndHourlyConsumption()
+		for _, d := range dates {
+			for _, h := range d.Hours {
+				readings = append(readings, reading(GranularityHour, hourKey(d.Date, h.Hour), h.WebhookEnergyMetrics))
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", dto.ErrUnknownGroupBy, payload.GroupBy)
+	}
+	return readings, nil
+}
+
+// hourKey arma la clave de período de una hora: "2025-10-08T13"
+func hourKey(date string, hour int) string {
+	return fmt.Sprintf("%sT%02d", date, hour)
+}
+
+// hourlyPeriod retorna la granularidad y el prefijo de clave de las filas de group_by "hour"
+// según el período del webhook (end_date exclusivo)
+func hourlyPeriod(period dto.WebhookPeriod) (string, string, error) {
+	start, err := time.Parse(dto.DateLayout, period.StartDate)
+	if err != nil {
+		return "", "", fmt.Errorf("period.start_date is required to store hourly consumption: %w", err)
+	}
+	end, err := time.Parse(dto.DateLayout, period.EndDate)
+	if err != nil {
+		return "", "", fmt.Errorf("period.end_date is required to store hourly consumption: %w", err)
+	}
+
+	if !end.After(start.AddDate(0, 0, 1)) {
+		return GranularityHour, period.StartDate, nil
+	}
+	return GranularityHourOfPeriod, period.StartDate + "/" + period.EndDate, nil
+}
+
+// ConsumptionRepository guarda lecturas de consumo en SQLite
+type ConsumptionRepository struct {
+	db *sql.DB
+}
+
+// NewConsumptionRepository crea un repositorio sobre una base abierta con Open
+func NewConsumptionRepository(db *sql.DB) *ConsumptionRepository {
+	return &ConsumptionRepository{
+		db: db,
+	}
+}
+
+// Save guarda las lecturas en una sola transacción. Si un período ya existe para el
+// contrato y la granularidad, sus valores se reemplazan (upsert) en lugar de duplicarse,
+// salvo que la fila guardada venga de un webhook con un timestamp más reciente: así un
+// reenvío atrasado no pisa datos corregidos.
+func (r *ConsumptionRepository) Save(ctx context.Context, readings []ConsumptionReading) error {
+	if len(readings) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+INSERT INTO consumption_readings (
+	contract_id, sic, granularity, period_key,
+	active_energy, active_export, inductive_penalized, reactive_capacitive,
+	webhook_id, received_at, payload_timestamp
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (contract_id, granularity, period_key) DO UPDATE SET
+	sic                 = excluded.sic,
+	active_energy       = excluded.active_energy,
+	active_export       = excluded.active_export,
+	inductive_penalized = excluded.inductive_penalized,
+	reactive_capacitive = excluded.reactive_capacitive,
+	webhook_id          = excluded.webhook_id,
+	received_at         = excluded.received_at,
+	payload_timestamp   = excluded.payload_timestamp
+WHERE excluded.payload_timestamp >= consumption_readings.payload_timestamp`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare upsert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, reading := range readings {
+		if _, err := stmt.ExecContext(ctx,
+			reading.ContractID, reading.SIC, reading.Granularity, reading.PeriodKey,
+			reading.ActiveEnergy, reading.ActiveExport, reading.InductivePenalized, reading.ReactiveCapacitive,
+			reading.WebhookID, reading.ReceivedAt, reading.PayloadTimestamp,
+		); err != nil {
+			return fmt.Errorf("failed to upsert reading %s %s for contract %d: %w",
+				reading.Granularity, reading.PeriodKey, reading.ContractID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit readings: %w", err)
+	}
+	return nil
+}
+
+// ConsumptionFilter selecciona lecturas; los campos vacíos o en cero no filtran.
+// From y To comparan PeriodKey (inclusive), por ejemplo From "2025-10-01" y To "2025-10-31T23".
+type ConsumptionFilter struct {
+	ContractID  int
+	Granularity string
+	From        string
+	To          string
+}
+
+// List retorna las lecturas que cumplen el filtro ordenadas por contrato y período
+func (r *ConsumptionRepository) List(ctx context.Context, filter ConsumptionFilter) ([]ConsumptionReading, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.ContractID != 0 {
+		conditions = append(conditions, "contract_id = ?")
+		args = append(args, filter.ContractID)
+	}
+	if filter.Granularity != "" {
+		conditions = append(conditions, "granularity = ?")
+		args = append(args, filter.Granularity)
+	}
+	if filter.From != "" {
+		conditions = append(conditions, "period_key >= ?")
+		args = append(args, filter.From)
+	}
+	if filter.To != "" {
+		conditions = append(conditions, "period_key <= ?")
+		args = append(args, filter.To)
+	}
+
+	query := `
+SELECT contract_id, sic, granularity, period_key,
+	active_energy, active_export, inductive_penalized, reactive_capacitive,
+	webhook_id, received_at, payload_timestamp
+FROM consumption_readings`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY contract_id, granularity, period_key"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query readings: %w", err)
+	}
+	defer rows.Close()
+
+	var readings []ConsumptionReading
+	for rows.Next() {
+		var reading ConsumptionReading
+		if err := rows.Scan(
+			&reading.ContractID, &reading.SIC, &reading.Granularity, &reading.PeriodKey,
+			&reading.ActiveEnergy, &reading.ActiveExport, &reading.InductivePenalized, &reading.ReactiveCapacitive,
+			&reading.WebhookID, &reading.ReceivedAt, &reading.PayloadTimestamp,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan reading: %w", err)
+		}
+		readings = append(readings, reading)
+	}
+	return readings, rows.Err()
+}
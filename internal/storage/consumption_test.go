@@ -0,0 +1,201 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"webhook_receiver/internal/dto"
+)
+
+// openTestDB abre una base SQLite temporal con las migraciones aplicadas
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := Open(context.Background(), filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// consumptionPayload decodifica un webhook de consumo del contrato 1001
+func consumptionPayload(t *testing.T, groupBy, startDate, endDate, consumption, timestamp string) dto.WebhookPayload {
+	t.Helper()
+	body := `{"webhook_id":7,"data_type":"consumption","group_by":"` + groupBy + `","send_interval":"daily",` +
+		`"period":{"start_date":"` + startDate + `","end_date":"` + endDate + `"},` +
+		`"data":{"contract_id":1001,"sic":"SIC1","consumption":` + consumption + `},"timestamp":"` + timestamp + `"}`
+	var payload dto.WebhookPayload
+	if err := json.Unmarshal([]byte(body), &payload); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	return payload
+}
+
+func TestNormalizeConsumption(t *testing.T) {
+	const timestamp = "2025-10-09T05:00:00-05:00"
+	hours := `[{"hour":0,"active_energy":1},{"hour":13,"active_energy":2}]`
+
+	tests := []struct {
+		name      string
+		payload   func(t *testing.T) dto.WebhookPayload
+		wantGran  []string
+		wantKeys  []string
+		wantErrIs error
+		wantErr   bool
+	}{
+		{
+			name: "hour of a one-day period",
+			payload: func(t *testing.T) dto.WebhookPayload {
+				return consumptionPayload(t, "hour", "2025-10-08", "2025-10-09", hours, timestamp)
+			},
+			wantGran: []string{GranularityHour, GranularityHour},
+			wantKeys: []string{"2025-10-08T00", "2025-10-08T13"},
+		},
+		{
+			name: "hour of a multi-day period",
+			payload: func(t *testing.T) dto.WebhookPayload {
+				return consumptionPayload(t, "hour", "2025-10-01", "2025-10-08", hours, timestamp)
+			},
+			wantGran: []string{GranularityHourOfPeriod, GranularityHourOfPeriod},
+			wantKeys: []string{"2025-10-01/2025-10-08T00", "2025-10-01/2025-10-08T13"},
+		},
+		{
+			name: "day",
+			payload: func(t *testing.T) dto.WebhookPayload {
+				return consumptionPayload(t, "day", "2025-10-01", "2025-10-03", `[{"date":"2025-10-01","active_energy":1},{"date":"2025-10-02","active_energy":2}]`, timestamp)
+			},
+			wantGran: []string{GranularityDay, GranularityDay},
+			wantKeys: []string{"2025-10-01", "2025-10-02"},
+		},
+		{
+			name: "month",
+			payload: func(t *testing.T) dto.WebhookPayload {
+				return consumptionPayload(t, "month", "2025-09-01", "2025-11-01", `[{"month":"2025-09","active_energy":1},{"month":"2025-10","active_energy":2}]`, timestamp)
+			},
+			wantGran: []string{GranularityMonth, GranularityMonth},
+			wantKeys: []string{"2025-09", "2025-10"},
+		},
+		{
+			name: "date and hour",
+			payload: func(t *testing.T) dto.WebhookPayload {
+				return consumptionPayload(t, "date_and_hour", "2025-10-01", "2025-10-03",
+					`[{"date":"2025-10-01","hours":[{"hour":23,"active_energy":1}]},{"date":"2025-10-02","hours":[{"hour":0,"active_energy":2}]}]`, timestamp)
+			},
+			wantGran: []string{GranularityHour, GranularityHour},
+			wantKeys: []string{"2025-10-01T23", "2025-10-02T00"},
+		},
+		{
+			name:    "hour without period",
+			payload: func(t *testing.T) dto.WebhookPayload { return consumptionPayload(t, "hour", "", "", hours, timestamp) },
+			wantErr: true,
+		},
+		{
+			name:      "unknown group_by",
+			payload:   func(t *testing.T) dto.WebhookPayload { return dto.WebhookPayload{GroupBy: "week"} },
+			wantErr:   true,
+			wantErrIs: dto.ErrUnknownGroupBy,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receivedAt := time.Date(2025, 10, 9, 6, 0, 0, 0, time.FixedZone("COT", -5*3600))
+			readings, err := NormalizeConsumption(tt.payload(t), receivedAt)
+			if tt.wantErr {
+				if err == nil || (tt.wantErrIs != nil && !errors.Is(err, tt.wantErrIs)) {
+					t.Fatalf("NormalizeConsumption() error = %v, want %v", err, tt.wantErrIs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NormalizeConsumption() error = %v", err)
+			}
+
+			var granularities, keys []string
+			for i, reading := range readings {
+				granularities = append(granularities, reading.Granularity)
+				keys = append(keys, reading.PeriodKey)
+				if reading.ContractID != 1001 || reading.SIC != "SIC1" || reading.WebhookID != 7 {
+					t.Errorf("reading %d ids = %d/%s/%d", i, reading.ContractID, reading.SIC, reading.WebhookID)
+				}
+				if reading.ActiveEnergy == nil || *reading.ActiveEnergy != float64(i+1) {
+					t.Errorf("reading %d active_energy = %v, want %d", i, reading.ActiveEnergy, i+1)
+				}
+				if reading.ReceivedAt.Location() != time.UTC || reading.PayloadTimestamp.Location() != time.UTC {
+					t.Errorf("reading %d times are not UTC: %v, %v", i, reading.ReceivedAt, reading.PayloadTimestamp)
+				}
+			}
+			if !reflect.DeepEqual(granularities, tt.wantGran) || !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("readings = %v %v, want %v %v", granularities, keys, tt.wantGran, tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestConsumptionRepositorySave(t *testing.T) {
+	ctx := context.Background()
+	repo := NewConsumptionRepository(openTestDB(t))
+
+	// Cada entrega reenvía la hora 13 del 2025-10-08 con otro valor y otro timestamp. Los
+	// timestamps se guardan como texto y se comparan lexicográficamente en SQLite: los casos
+	// con fracciones de segundo y zonas horarias cubren esa comparación con el driver real.
+	deliveries := []struct {
+		name       string
+		timestamp  string
+		energy     string
+		wantEnergy float64
+	}{
+		{name: "first delivery", timestamp: "2025-10-08T10:30:00.5Z", energy: "1", wantEnergy: 1},
+		{name: "older whole second is ignored", timestamp: "2025-10-08T10:30:00Z", energy: "2", wantEnergy: 1},
+		{name: "older fraction is ignored", timestamp: "2025-10-08T10:30:00.25Z", energy: "3", wantEnergy: 1},
+		{name: "same timestamp replaces", timestamp: "2025-10-08T10:30:00.5Z", energy: "4", wantEnergy: 4},
+		{name: "longer newer fraction replaces", timestamp: "2025-10-08T10:30:00.55Z", energy: "5", wantEnergy: 5},
+		{name: "newer replaces", timestamp: "2025-10-08T11:00:00Z", energy: "6", wantEnergy: 6},
+		{name: "older in another zone is ignored", timestamp: "2025-10-08T05:59:59-05:00", energy: "7", wantEnergy: 6},
+		{name: "newer in another zone replaces", timestamp: "2025-10-08T06:00:01-05:00", energy: "8", wantEnergy: 8},
+	}
+	for _, d := range deliveries {
+		t.Run(d.name, func(t *testing.T) {
+			payload := consumptionPayload(t, "hour", "2025-10-08", "2025-10-09",
+				`[{"hour":13,"active_energy":`+d.energy+`},{"hour":14,"active_energy":1}]`, d.timestamp)
+			readings, err := NormalizeConsumption(payload, time.Now())
+			if err != nil {
+				t.Fatalf("NormalizeConsumption() error = %v", err)
+			}
+			if err := repo.Save(ctx, readings); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+
+			stored, err := repo.List(ctx, ConsumptionFilter{ContractID: 1001, Granularity: GranularityHour})
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if len(stored) != 2 {
+				t.Fatalf("List() = %d rows, want 2 (a re-sent period replaces instead of duplicating)", len(stored))
+			}
+			if stored[0].PeriodKey != "2025-10-08T13" || *stored[0].ActiveEnergy != d.wantEnergy {
+				t.Errorf("%s active_energy = %v, want %v", stored[0].PeriodKey, *stored[0].ActiveEnergy, d.wantEnergy)
+			}
+		})
+	}
+
+	// Los filtros de List comparan la clave de período
+	others := consumptionPayload(t, "day", "2025-10-01", "2025-10-03",
+		`[{"date":"2025-10-01","active_energy":1},{"date":"2025-10-02","active_energy":2}]`, "2025-10-03T00:00:00Z")
+	readings, err := NormalizeConsumption(others, time.Now())
+	if err != nil {
+		t.Fatalf("NormalizeConsumption() error = %v", err)
+	}
+	if err := repo.Save(ctx, readings); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	days, err := repo.List(ctx, ConsumptionFilter{Granularity: GranularityDay, From: "2025-10-02", To: "2025-10-31"})
+	if err != nil || len(days) != 1 || days[0].PeriodKey != "2025-10-02" {
+		t.Errorf("List(day from 2025-10-02) = %+v, %v", days, err)
+	}
+}
@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"webhook_receiver/internal/executor"
+)
+
+// HookRunRepository guarda el registro de ejecuciones de los hooks de comandos
+type HookRunRepository struct {
+	db *sql.DB
+}
+
+// NewHookRunRepository crea una nueva instancia del repositorio
+func NewHookRunRepository(db *sql.DB) *HookRunRepository {
+	return &HookRunRepository{db: db}
+}
+
+// RecordRun guarda una ejecución. Implementa executor.Recorder.
+func (r *HookRunRepository) RecordRun(ctx context.Context, run executor.Run) error {
+	_, err := r.db.ExecContext(ctx, `
+INSERT INTO hook_runs (
+	hook, data_type, trigger_type, webhook_id, contract_id, idempotency_key, exit_code, error, output, started_at, duration_ms
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.Hook, run.DataType, run.TriggerType, run.WebhookID, run.ContractID, run.IdempotencyKey,
+		run.ExitCode, run.Error, run.Output, run.StartedAt.UTC(), run.Duration.Milliseconds(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record run of hook %s: %w", run.Hook, err)
+	}
+	return nil
+}
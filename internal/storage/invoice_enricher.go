@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"webhook_receiver/internal/dto"
+	"webhook_receiver/internal/invoice"
+	"webhook_receiver/internal/processor"
+)
+
+// DefaultInvoiceQueueSize es la cantidad de descargas pendientes antes de descartar nuevas
+const DefaultInvoiceQueueSize = 100
+
+// invoiceJob es una descarga pendiente del XML de una factura
+type invoiceJob struct {
+	ctx    context.Context // Contexto sin cancelación de la petición, para los logs y la traza
+	billID int
+	xmlURL string
+	total  dto.Decimal
+}
+
+// InvoiceEnricher implementa processor.Processor descargando el XML de xml_url y
+// asociando la factura electrónica a la factura guardada. Debe ejecutarse después de
+// Processor en la cadena, para que la factura ya exista.
+//
+// La descarga se hace en segundo plano: el webhook no espera al servidor del XML y un
+// fallo de la descarga no rechaza el evento, solo queda en los logs. La factura sin XML se
+// vuelve a intentar la próxima vez que llegue su xml_url.
+type InvoiceEnricher struct {
+	fetcher *invoice.Fetcher
+	bills   *BillRepository
+
+	mu      sync.Mutex
+	pending map[int]bool // Facturas con descarga encolada o en curso
+	closed  bool
+	queue   chan invoiceJob
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// NewInvoiceEnricher crea una nueva instancia del enriquecedor e inicia la descarga en segundo plano
+func NewInvoiceEnricher(fetcher *invoice.Fetcher, bills *BillRepository) *InvoiceEnricher {
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &InvoiceEnricher{
+		fetcher: fetcher,
+		bills:   bills,
+		pending: make(map[int]bool),
+		queue:   make(chan invoiceJob, DefaultInvoiceQueueSize),
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// OnConsumption implementa processor.Processor; los webhooks de consumo no se enriquecen
+func (e *InvoiceEnricher) OnConsumption(ctx context.Context, payload dto.WebhookPayload) error {
+	return nil
+}
+
+// OnBill encola la descarga del XML la primera vez que se recibe el xml_url de una factura.
+// Si el total del XML no coincide con bill.total la factura queda marcada (total_mismatch).
+func (e *InvoiceEnricher) OnBill(ctx context.Context, payload dto.BillWebhookPayload) error {
+	billID := payload.Bill.BillID
+	if payload.Bill.XmlUrl == "" {
+		return nil
+	}
+
+	if _, err := e.bills.GetInvoice(ctx, billID); err == nil {
+		return nil
+	} else if !errors.Is(err, ErrInvoiceNotFound) {
+		return processor.Retryable(err)
+	}
+	if _, err := e.bills.Get(ctx, billID); err != nil {
+		// La transición fue rechazada o aún no se guardó: no hay factura a la cual asociar el XML
+		return nil
+	}
+
+	e.enqueue(ctx, invoiceJob{
+		ctx:    context.WithoutCancel(ctx),
+		billID: billID,
+		xmlURL: payload.Bill.XmlUrl,
+		total:  payload.Bill.Total,
+	})
+	return nil
+}
+
+// Close deja de aceptar descargas y espera las encoladas; si ctx expira cancela la descarga en curso
+func (e *InvoiceEnricher) Close(ctx context.Context) error {
+	e.mu.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.queue)
+	}
+	e.mu.Unlock()
+
+	select {
+	case <-e.done:
+		e.cancel()
+		return nil
+	case <-ctx.Done():
+		e.cancel()
+		<-e.done
+		return ctx.Err()
+	}
+}
+
+// enqueue agrega job a la cola sin bloquear; si la factura ya tiene una descarga pendiente o la
+// cola está llena se descarta
+func (e *InvoiceEnricher) enqueue(ctx context.Context, job invoiceJob) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed || e.pending[job.billID] {
+		return
+	}
+	select {
+	case e.queue <- job:
+		e.pending[job.billID] = true
+	default:
+		slog.WarnContext(ctx, "Invoice fetch queue is full: the XML will be fetched on the next bill webhook",
+			"bill_id", job.billID)
+	}
+}
+
+// run descarga los XML encolados hasta que se cierre la cola
+func (e *InvoiceEnricher) run() {
+	defer close(e.done)
+	for job := range e.queue {
+		if e.ctx.Err() == nil {
+			e.fetch(job)
+		}
+
+		e.mu.Lock()
+		delete(e.pending, job.billID)
+		e.mu.Unlock()
+	}
+}
+
+// fetch descarga y asocia el XML de job; los errores solo se registran en los logs
+func (e *InvoiceEnricher) fetch(job invoiceJob) {
+	// Conservar los valores de la petición (request_id, traza) pero cancelar con Close
+	ctx, cancel := context.WithCancel(job.ctx)
+	defer cancel()
+	stop := context.AfterFunc(e.ctx, cancel)
+	defer stop()
+
+	inv, err := e.fetcher.Fetch(ctx, job.xmlURL)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to fetch invoice XML",
+			"bill_id", job.billID, "temporary", invoice.IsTemporary(err), "error", err)
+		return
+	}
+
+	mismatch := !inv.Total.Equal(job.total)
+	if mismatch {
+		slog.WarnContext(ctx, "Invoice XML total does not match webhook total",
+			"bill_id", job.billID, "invoice_total", inv.Total.String(), "webhook_total", job.total.String())
+	}
+
+	err = e.bills.AttachInvoice(ctx, BillInvoice{
+		BillID:        job.billID,
+		Invoice:       *inv,
+		TotalMismatch: mismatch,
+		FetchedAt:     time.Now(),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to save invoice XML", "bill_id", job.billID, "error", err)
+	}
+}
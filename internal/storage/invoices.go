@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"webhook_receiver/internal/invoice"
+)
+
+// ErrInvoiceNotFound se retorna cuando la factura no tiene XML asociado
+var ErrInvoiceNotFound = errors.New("invoice not found")
+
+// BillInvoice es la factura electrónica asociada a una factura guardada
+type BillInvoice struct {
+	BillID int
+	invoice.Invoice
+	// TotalMismatch indica que el total del XML no coincide con el total del webhook
+	TotalMismatch bool
+	FetchedAt     time.Time
+}
+
+// AttachInvoice asocia (o reemplaza) la factura electrónica de billID
+func (r *BillRepository) AttachInvoice(ctx context.Context, billInvoice BillInvoice) error {
+	lines, err := json.Marshal(billInvoice.Lines)
+	if err != nil {
+		return fmt.Errorf("failed to encode invoice lines: %w", err)
+	}
+	taxes, err := json.Marshal(billInvoice.Taxes)
+	if err != nil {
+		return fmt.Errorf("failed to encode invoice taxes: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+INSERT INTO bill_invoices (
+	bill_id, invoice_number, cufe, issue_date, due_date, currency, total, total_mismatch, lines, taxes, fetched_at
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (bill_id) DO UPDATE SET
+	invoice_number = excluded.invoice_number,
+	cufe           = excluded.cufe,
+	issue_date     = excluded.issue_date,
+	due_date       = excluded.due_date,
+	currency       = excluded.currency,
+	total          = excluded.total,
+	total_mismatch = excluded.total_mismatch,
+	lines          = excluded.lines,
+	taxes          = excluded.taxes,
+	fetched_at     = excluded.fetched_at`,
+		billInvoice.BillID, billInvoice.Number, billInvoice.CUFE, billInvoice.IssueDate, billInvoice.DueDate,
+		billInvoice.Currency, billInvoice.Total, billInvoice.TotalMismatch, string(lines), string(taxes),
+		billInvoice.FetchedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to attach invoice to bill %d: %w", billInvoice.BillID, err)
+	}
+	return nil
+}
+
+// GetInvoice retorna la factura electrónica asociada a billID
+func (r *BillRepository) GetInvoice(ctx context.Context, billID int) (BillInvoice, error) {
+	var (
+		billInvoice  BillInvoice
+		lines, taxes string
+	)
+	err := r.db.QueryRowContext(ctx, `
+SELECT bill_id, invoice_number, cufe, issue_date, due_date, currency, total, total_mismatch, lines, taxes, fetched_at
+FROM bill_invoices WHERE bill_id = ?`, billID).Scan(
+		&billInvoice.BillID, &billInvoice.Number, &billInvoice.CUFE, &billInvoice.IssueDate, &billInvoice.DueDate,
+		&billInvoice.Currency, &billInvoice.Total, &billInvoice.TotalMismatch, &lines, &taxes, &billInvoice.FetchedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return BillInvoice{}, fmt.Errorf("%w: bill %d", ErrInvoiceNotFound, billID)
+	}
+	if err != nil {
+		return BillInvoice{}, fmt.Errorf("failed to read invoice of bill %d: %w", billID, err)
+	}
+
+	if err := json.Unmarshal([]byte(lines), &billInvoice.Lines); err != nil {
+		return BillInvoice{}, fmt.Errorf("invalid invoice lines of bill %d: %w", billID, err)
+	}
+	if err := json.Unmarshal([]byte(taxes), &billInvoice.Taxes); err != nil {
+		return BillInvoice{}, fmt.Errorf("invalid invoice taxes of bill %d: %w", billID, err)
+	}
+	return billInvoice, nil
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// migration es un cambio de esquema versionado. Las migraciones ya publicadas no se
+// modifican: los cambios nuevos se agregan al final con la siguiente versión.
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create consumption_readings",
+		sql: `
+CREATE TABLE consumption_readings (
+	contract_id         INTEGER NOT NULL,
+	sic                 TEXT    NOT NULL,
+	granularity         TEXT    NOT NULL,
+	period_key          TEXT    NOT NULL,
+	active_energy       REAL,
+	active_export       REAL,
+	inductive_penalized REAL,
+	reactive_capacitive REAL,
+	webhook_id          INTEGER NOT NULL,
+	received_at         TIMESTAMP NOT NULL,
+	PRIMARY KEY (contract_id, granularity, period_key)
+);
+CREATE INDEX idx_consumption_readings_sic ON consumption_readings (sic, granularity, period_key);
+`,
+	},
+	{
+		version: 2,
+		name:    "create bills and bill_events",
+		sql: `
+CREATE TABLE bills (
+	bill_id        INTEGER PRIMARY KEY,
+	contract_id    INTEGER NOT NULL,
+	period         TEXT    NOT NULL,
+	total          TEXT    NOT NULL,
+	status         TEXT    NOT NULL,
+	xml_url        TEXT    NOT NULL,
+	state          TEXT    NOT NULL,
+	available_at   TIMESTAMP,
+	paid_at        TIMESTAMP,
+	payment_date   TIMESTAMP,
+	transaction_id INTEGER,
+	payment_method TEXT,
+	updated_at     TIMESTAMP NOT NULL
+);
+CREATE INDEX idx_bills_contract ON bills (contract_id, period);
+
+CREATE TABLE bill_events (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	bill_id         INTEGER NOT NULL,
+	trigger_type    TEXT    NOT NULL,
+	event_timestamp TIMESTAMP NOT NULL,
+	webhook_id      INTEGER NOT NULL,
+	outcome         TEXT    NOT NULL,
+	reason          TEXT    NOT NULL DEFAULT '',
+	received_at     TIMESTAMP NOT NULL
+);
+CREATE INDEX idx_bill_events_bill ON bill_events (bill_id, event_timestamp);
+`,
+	},
+	{
+		version: 3,
+		name:    "create bill_invoices",
+		sql: `
+CREATE TABLE bill_invoices (
+	bill_id        INTEGER PRIMARY KEY REFERENCES bills (bill_id),
+	invoice_number TEXT    NOT NULL,
+	cufe           TEXT    NOT NULL,
+	issue_date     TEXT    NOT NULL,
+	due_date       TEXT    NOT NULL,
+	currency       TEXT    NOT NULL,
+	total          TEXT    NOT NULL,
+	total_mismatch INTEGER NOT NULL DEFAULT 0,
+	lines          TEXT    NOT NULL,
+	taxes          TEXT    NOT NULL,
+	fetched_at     TIMESTAMP NOT NULL
+);
+CREATE INDEX idx_bill_invoices_cufe ON bill_invoices (cufe);
+`,
+	},
+	{
+		version: 4,
+		name:    "create hook_runs",
+		sql: `
+CREATE TABLE hook_runs (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	hook            TEXT    NOT NULL,
+	data_type       TEXT    NOT NULL,
+	trigger_type    TEXT    NOT NULL DEFAULT '',
+	webhook_id      INTEGER NOT NULL,
+	contract_id     INTEGER NOT NULL,
+	idempotency_key TEXT    NOT NULL DEFAULT '',
+	exit_code       INTEGER NOT NULL,
+	error           TEXT    NOT NULL DEFAULT '',
+	output          TEXT    NOT NULL DEFAULT '',
+	started_at      TIMESTAMP NOT NULL,
+	duration_ms     INTEGER NOT NULL
+);
+CREATE INDEX idx_hook_runs_hook ON hook_runs (hook, started_at);
+`,
+	},
+	{
+		version: 5,
+		name:    "add consumption_readings.payload_timestamp",
+		// Las filas existentes quedan con el tiempo cero y las reemplaza cualquier webhook nuevo
+		sql: `
+ALTER TABLE consumption_readings ADD COLUMN payload_timestamp TIMESTAMP NOT NULL DEFAULT '0001-01-01 00:00:00+00:00';
+`,
+	},
+}
+
+// migrate aplica en orden las migraciones que aún no están registradas en schema_migrations
+func migrate(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    INTEGER PRIMARY KEY,
+	name       TEXT NOT NULL,
+	applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := apply(ctx, db, m); err != nil {
+			return fmt.Errorf("failed to apply migration %d (%s): %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+// apply ejecuta una migración y la registra en la misma transacción
+func apply(ctx context.Context, db *sql.DB, m migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, m.sql); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.version, m.name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
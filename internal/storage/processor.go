@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"webhook_receiver/internal/dto"
+	"webhook_receiver/internal/processor"
+)
+
+// Processor implementa processor.Processor guardando los webhooks en la base de datos
+type Processor struct {
+	consumptions *ConsumptionRepository
+	bills        *BillRepository
+}
+
+// NewProcessor crea un procesador que persiste las lecturas de consumo en consumptions
+// y el ciclo de vida de las facturas en bills
+func NewProcessor(consumptions *ConsumptionRepository, bills *BillRepository) *Processor {
+	return &Processor{
+		consumptions: consumptions,
+		bills:        bills,
+	}
+}
+
+// OnConsumption normaliza el webhook de consumo y guarda sus lecturas
+func (p *Processor) OnConsumption(ctx context.Context, payload dto.WebhookPayload) error {
+	readings, err := NormalizeConsumption(payload, processor.ReceivedAt(ctx))
+	if err != nil {
+		// Un payload que no se puede normalizar no se va a poder guardar en un reintento
+		return processor.Permanent(fmt.Errorf("failed to normalize consumption: %w", err))
+	}
+
+	if err := p.consumptions.Save(ctx, readings); err != nil {
+		return processor.Retryable(err)
+	}
+	return nil
+}
+
+// OnBill aplica el evento available o paid al ciclo de vida de la factura.
+// Las transiciones ilegales son rechazos definitivos.
+func (p *Processor) OnBill(ctx context.Context, payload dto.BillWebhookPayload) error {
+	bill, outcome, err := p.bills.Apply(ctx, payload, processor.ReceivedAt(ctx))
+	if errors.Is(err, ErrIllegalTransition) {
+		return processor.Permanent(fmt.Errorf("bill %d: %w", payload.Bill.BillID, err))
+	}
+	if err != nil {
+		return processor.Retryable(err)
+	}
+
+	if outcome == BillOutcomeIgnored {
+		slog.InfoContext(ctx, "Bill event ignored (duplicate or older than stored state)",
+			"bill_id", bill.BillID, "event_timestamp", payload.Timestamp.Format(time.RFC3339))
+	} else if bill.AwaitingAvailable() {
+		slog.WarnContext(ctx, "Bill paid before its available event was received", "bill_id", bill.BillID)
+	}
+	return nil
+}
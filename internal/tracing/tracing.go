@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName es el nombre del tracer con el que se crean todos los spans del receptor
+const TracerName = "webhook_receiver"
+
+// Exporters soportados
+const (
+	ExporterNone   = ""
+	ExporterOTLP   = "otlp"   // OTLP sobre HTTP; se configura con las variables OTEL_EXPORTER_OTLP_*
+	ExporterStdout = "stdout" // spans en JSON por stdout, útil en desarrollo
+)
+
+// Atributos comunes de los spans de un webhook
+const (
+	AttrWebhookID   = attribute.Key("webhook.id")
+	AttrDataType    = attribute.Key("webhook.data_type")
+	AttrTriggerType = attribute.Key("webhook.trigger_type")
+	AttrContractID  = attribute.Key("webhook.contract_id")
+)
+
+// Setup crea el exporter indicado e instala el TracerProvider global. Con ExporterNone no
+// instala nada y los spans no se registran. Retorna la función que vacía y cierra el exporter.
+func Setup(ctx context.Context, exporter, serviceName string) (func(context.Context) error, error) {
+	var (
+		spanExporter sdktrace.SpanExporter
+		err          error
+	)
+	switch exporter {
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		spanExporter, err = otlptracehttp.New(ctx)
+	case ExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q (expected otlp or stdout)", exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s exporter: %w", exporter, err)
+	}
+
+	provider := Install(serviceName, sdktrace.WithBatcher(spanExporter))
+	return provider.Shutdown, nil
+}
+
+// Install instala como globales un TracerProvider con opts (por ejemplo
+// sdktrace.WithSyncer(tracetest.NewInMemoryExporter()) en pruebas) y el propagador W3C
+// (traceparent y baggage)
+func Install(serviceName string, opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	res := resource.NewSchemaless(semconv.ServiceName(serviceName))
+	provider := sdktrace.NewTracerProvider(append([]sdktrace.TracerProviderOption{sdktrace.WithResource(res)}, opts...)...)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider
+}
+
+// Start crea un span con el tracer del receptor
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(TracerName).Start(ctx, name, opts...)
+}
+
+// EventAttributes retorna los atributos que identifican un webhook
+func EventAttributes(dataType, triggerType string, webhookID, contractID int) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		AttrDataType.String(dataType),
+		AttrWebhookID.Int(webhookID),
+		AttrContractID.Int(contractID),
+	}
+	if triggerType != "" {
+		attrs = append(attrs, AttrTriggerType.String(triggerType))
+	}
+	return attrs
+}
+
+// End registra err en el span (si no es nil) y lo termina
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject agrega el contexto de traza de ctx (traceparent) a carrier
+func Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+}
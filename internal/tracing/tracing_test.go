@@ -0,0 +1,153 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// install instala un TracerProvider con un exporter en memoria y lo retorna
+func install(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := Install("webhook-receiver-test", sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+	return exporter
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const parentTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	tests := []struct {
+		name        string
+		traceparent string
+		status      int
+		wantStatus  codes.Code
+	}{
+		{name: "new trace", status: http.StatusOK, wantStatus: codes.Unset},
+		{name: "continues traceparent", traceparent: "00-" + parentTraceID + "-00f067aa0ba902b7-01", status: http.StatusOK, wantStatus: codes.Unset},
+		{name: "client error is not a span error", status: http.StatusUnauthorized, wantStatus: codes.Unset},
+		{name: "server error", status: http.StatusServiceUnavailable, wantStatus: codes.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := install(t)
+
+			engine := gin.New()
+			engine.Use(Middleware())
+			engine.POST("/webhook", func(c *gin.Context) {
+				_, span := Start(c.Request.Context(), "process")
+				span.End()
+				c.Status(tt.status)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/webhook", nil)
+			if tt.traceparent != "" {
+				req.Header.Set("traceparent", tt.traceparent)
+			}
+			engine.ServeHTTP(httptest.NewRecorder(), req)
+
+			spans := exporter.GetSpans()
+			if len(spans) != 2 {
+				t.Fatalf("exported %d spans, want 2", len(spans))
+			}
+			child, server := spans[0], spans[1]
+
+			if server.Name != "POST /webhook" || server.SpanKind != trace.SpanKindServer {
+				t.Errorf("server span = %q (%s), want POST /webhook (server)", server.Name, server.SpanKind)
+			}
+			if server.Status.Code != tt.wantStatus {
+				t.Errorf("server span status = %s, want %s", server.Status.Code, tt.wantStatus)
+			}
+			if !hasAttribute(server, semconv.HTTPResponseStatusCode(tt.status)) {
+				t.Errorf("server span has no %s attribute", semconv.HTTPResponseStatusCodeKey)
+			}
+			if child.Parent.SpanID() != server.SpanContext.SpanID() {
+				t.Error("the processing span is not a child of the server span")
+			}
+			if tt.traceparent != "" && server.SpanContext.TraceID().String() != parentTraceID {
+				t.Errorf("trace ID = %s, want the one from traceparent", server.SpanContext.TraceID())
+			}
+		})
+	}
+}
+
+func TestEnd(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus codes.Code
+		wantEvents int
+	}{
+		{name: "success", wantStatus: codes.Unset},
+		{name: "error", err: errors.New("broker unavailable"), wantStatus: codes.Error, wantEvents: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := install(t)
+
+			_, span := Start(context.Background(), "publish", trace.WithAttributes(EventAttributes("bills", "paid", 67890, 2001)...))
+			End(span, tt.err)
+
+			spans := exporter.GetSpans()
+			if len(spans) != 1 {
+				t.Fatalf("exported %d spans, want 1", len(spans))
+			}
+			if spans[0].Status.Code != tt.wantStatus || len(spans[0].Events) != tt.wantEvents {
+				t.Errorf("status = %s with %d events, want %s with %d", spans[0].Status.Code, len(spans[0].Events),
+					tt.wantStatus, tt.wantEvents)
+			}
+			if !hasAttribute(spans[0], AttrTriggerType.String("paid")) || !hasAttribute(spans[0], AttrContractID.Int(2001)) {
+				t.Errorf("span attributes = %v, want the event attributes", spans[0].Attributes)
+			}
+		})
+	}
+}
+
+func TestInject(t *testing.T) {
+	install(t)
+
+	ctx, span := Start(context.Background(), "forward billing")
+	defer span.End()
+
+	header := http.Header{}
+	Inject(ctx, propagation.HeaderCarrier(header))
+	want := "00-" + span.SpanContext().TraceID().String() + "-" + span.SpanContext().SpanID().String() + "-01"
+	if got := header.Get("traceparent"); got != want {
+		t.Errorf("traceparent = %q, want %q", got, want)
+	}
+}
+
+func TestSetupNone(t *testing.T) {
+	shutdown, err := Setup(context.Background(), ExporterNone, "webhook-receiver")
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown() error = %v", err)
+	}
+	if _, err := Setup(context.Background(), "zipkin", "webhook-receiver"); err == nil {
+		t.Error("Setup() with an unknown exporter returned no error")
+	}
+}
+
+func hasAttribute(span tracetest.SpanStub, want attribute.KeyValue) bool {
+	for _, attr := range span.Attributes {
+		if attr == want {
+			return true
+		}
+	}
+	return false
+}
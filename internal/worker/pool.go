@@ -0,0 +1,198 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"webhook_receiver/internal/processor"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Valores por defecto de QueueOptions
+const (
+	DefaultWorkers   = 4
+	DefaultQueueSize = 100
+)
+
+var (
+	// ErrQueueFull se retorna cuando la cola del data_type no tiene espacio (backpressure)
+	ErrQueueFull = errors.New("queue is full")
+	// ErrPoolClosed se retorna al encolar después de Shutdown
+	ErrPoolClosed = errors.New("worker pool is closed")
+)
+
+// Job es un evento verificado y decodificado pendiente de procesar
+type Job struct {
+	RecordID     string // ID del registro en el inbox, vacío si no hay inbox
+	DeadLetterID string // ID de la entrada del dead-letter queue cuando el job es un redrive
+	Event        processor.Event
+	// SpanContext es el contexto de traza de la petición que encoló el job, para que el
+	// procesamiento en segundo plano quede en la misma traza
+	SpanContext trace.SpanContext
+	// RequestID es el ID de la petición que encoló el job, para los logs
+	RequestID string
+}
+
+// RunFunc procesa un Job
+type RunFunc func(ctx context.Context, job Job)
+
+// QueueOptions configura la cola de un data_type
+type QueueOptions struct {
+	Workers   int // Cantidad de workers concurrentes
+	QueueSize int // Capacidad de la cola antes de rechazar con ErrQueueFull
+}
+
+func (o QueueOptions) withDefaults(fallback QueueOptions) QueueOptions {
+	if o.Workers <= 0 {
+		o.Workers = fallback.Workers
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = fallback.QueueSize
+	}
+	return o
+}
+
+// Options configura el pool
+type Options struct {
+	// Default aplica a los data_type sin configuración propia
+	Default QueueOptions
+	// PerDataType permite dar más o menos capacidad a un data_type
+	PerDataType map[string]QueueOptions
+}
+
+// Pool procesa Jobs con un grupo acotado de workers y una cola por data_type,
+// así un tipo lento no bloquea a los demás
+type Pool struct {
+	mu     sync.RWMutex
+	opts   Options
+	run    RunFunc
+	queues map[string]chan Job
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	closed bool
+}
+
+// NewPool crea el pool; las colas de cada data_type se crean en el primer Enqueue
+func NewPool(opts Options, run RunFunc) *Pool {
+	opts.Default = opts.Default.withDefaults(QueueOptions{Workers: DefaultWorkers, QueueSize: DefaultQueueSize})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Pool{
+		opts:   opts,
+		run:    run,
+		queues: make(map[string]chan Job),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Enqueue agrega job a la cola de su data_type sin bloquear.
+// Retorna ErrQueueFull si la cola está llena.
+func (p *Pool) Enqueue(job Job) error {
+	dataType := job.Event.DataType
+
+	// El envío se hace con el lock de lectura tomado para no competir con el close de Shutdown
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return ErrPoolClosed
+	}
+	queue, ok := p.queues[dataType]
+	if ok {
+		defer p.mu.RUnlock()
+		select {
+		case queue <- job:
+			return nil
+		default:
+			return ErrQueueFull
+		}
+	}
+	p.mu.RUnlock()
+
+	if !p.startQueue(dataType) {
+		return ErrPoolClosed
+	}
+	return p.Enqueue(job)
+}
+
+// Depth retorna la cantidad de jobs esperando en la cola de cada data_type
+func (p *Pool) Depth() map[string]int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	depth := make(map[string]int, len(p.queues))
+	for dataType, queue := range p.queues {
+		depth[dataType] = len(queue)
+	}
+	return depth
+}
+
+// Shutdown deja de aceptar jobs y espera a que se procesen los encolados. Si ctx expire antes
+// cancela el procesamiento en curso, descarta los jobs que siguen encolados (quedan pendientes
+// en el inbox) y espera igualmente a que los workers terminen, para que los recursos que usan
+// se puedan cerrar después; en ese caso retorna ctx.Err().
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		for _, queue := range p.queues {
+			close(queue)
+		}
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		// Cancelar el procesamiento en curso; los jobs quedan pendientes en el inbox
+		p.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// startQueue crea la cola y los workers de dataType si aún no existen.
+// Retorna false si el pool ya fue cerrado.
+func (p *Pool) startQueue(dataType string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return false
+	}
+	if _, ok := p.queues[dataType]; ok {
+		return true
+	}
+
+	opts := p.opts.PerDataType[dataType].withDefaults(p.opts.Default)
+	queue := make(chan Job, opts.QueueSize)
+	p.queues[dataType] = queue
+
+	for i := 0; i < opts.Workers; i++ {
+		p.wg.Add(1)
+		go p.work(queue)
+	}
+	return true
+}
+
+func (p *Pool) work(queue chan Job) {
+	defer p.wg.Done()
+	for job := range queue {
+		if p.ctx.Err() != nil {
+			// Shutdown expiró: el job no se procesa y queda pendiente en el inbox
+			continue
+		}
+		p.run(p.ctx, job)
+	}
+}
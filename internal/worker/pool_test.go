@@ -0,0 +1,148 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"webhook_receiver/internal/processor"
+)
+
+func job(dataType, recordID string) Job {
+	return Job{RecordID: recordID, Event: processor.Event{DataType: dataType}}
+}
+
+func TestPoolQueues(t *testing.T) {
+	block := make(chan struct{})
+	started := make(chan string, 10)
+	var mu sync.Mutex
+	var ran []string
+	pool := NewPool(Options{
+		Default:     QueueOptions{Workers: 1, QueueSize: 1},
+		PerDataType: map[string]QueueOptions{"bills": {Workers: 1, QueueSize: 3}},
+	}, func(ctx context.Context, job Job) {
+		started <- job.RecordID
+		if job.Event.DataType == "consumption" {
+			<-block
+		}
+		mu.Lock()
+		ran = append(ran, job.RecordID)
+		mu.Unlock()
+	})
+
+	// El worker de consumption queda ocupado y su cola de un lugar se llena
+	if err := pool.Enqueue(job("consumption", "c1")); err != nil {
+		t.Fatalf("Enqueue(c1) error = %v", err)
+	}
+	<-started
+	if err := pool.Enqueue(job("consumption", "c2")); err != nil {
+		t.Fatalf("Enqueue(c2) error = %v", err)
+	}
+	if err := pool.Enqueue(job("consumption", "c3")); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Enqueue(c3) error = %v, want ErrQueueFull", err)
+	}
+	if depth := pool.Depth()["consumption"]; depth != 1 {
+		t.Errorf("Depth()[consumption] = %d, want 1", depth)
+	}
+
+	// Un data_type lento no bloquea a los demás, que tienen su propia cola y capacidad
+	for _, id := range []string{"b1", "b2", "b3"} {
+		if err := pool.Enqueue(job("bills", id)); err != nil {
+			t.Fatalf("Enqueue(%s) error = %v", id, err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("bills jobs did not run while consumption was blocked")
+		}
+	}
+
+	close(block)
+	if err := pool.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if len(ran) != 5 {
+		t.Errorf("ran = %v, want the 5 accepted jobs", ran)
+	}
+	if err := pool.Enqueue(job("consumption", "c4")); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Enqueue() after Shutdown error = %v, want ErrPoolClosed", err)
+	}
+	if err := pool.Enqueue(job("meter_reading", "m1")); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Enqueue() of a new data_type after Shutdown error = %v, want ErrPoolClosed", err)
+	}
+}
+
+func TestPoolShutdownTimeout(t *testing.T) {
+	started := make(chan struct{})
+	var mu sync.Mutex
+	var finished, ranQueued bool
+	pool := NewPool(Options{Default: QueueOptions{Workers: 1, QueueSize: 5}}, func(ctx context.Context, job Job) {
+		if job.RecordID != "slow" {
+			mu.Lock()
+			ranQueued = true
+			mu.Unlock()
+			return
+		}
+		close(started)
+		// El job ignora la cancelación un momento, como un procesador que termina su escritura
+		<-ctx.Done()
+		time.Sleep(50 * time.Millisecond)
+		mu.Lock()
+		finished = true
+		mu.Unlock()
+	})
+
+	if err := pool.Enqueue(job("consumption", "slow")); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	<-started
+	if err := pool.Enqueue(job("consumption", "queued")); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := pool.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown() error = %v, want context.DeadlineExceeded", err)
+	}
+
+	// Shutdown retorna cuando el worker terminó, sin procesar lo que seguía encolado
+	mu.Lock()
+	defer mu.Unlock()
+	if !finished {
+		t.Error("Shutdown() returned while a job was still running")
+	}
+	if ranQueued {
+		t.Error("queued job ran after the shutdown timeout")
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 500 * time.Millisecond}.WithDefaults()
+	if policy.MaxAttempts != DefaultMaxAttempts {
+		t.Errorf("MaxAttempts = %d, want %d", policy.MaxAttempts, DefaultMaxAttempts)
+	}
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 1, want: 100 * time.Millisecond},
+		{attempt: 2, want: 200 * time.Millisecond},
+		{attempt: 3, want: 400 * time.Millisecond},
+		{attempt: 4, want: 500 * time.Millisecond},
+		{attempt: 10, want: 500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			got := policy.Backoff(tt.attempt)
+			if got < tt.want*4/5 || got > tt.want*6/5 {
+				t.Fatalf("Backoff(%d) = %v, want %v ±20%%", tt.attempt, got, tt.want)
+			}
+		}
+	}
+}
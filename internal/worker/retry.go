@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Valores por defecto de RetryPolicy
+const (
+	DefaultMaxAttempts    = 5
+	DefaultInitialBackoff = time.Second
+	DefaultMaxBackoff     = time.Minute
+)
+
+// RetryPolicy define cuántas veces se intenta procesar un evento y cuánto se espera entre intentos
+type RetryPolicy struct {
+	MaxAttempts    int           // Intentos totales, incluido el primero
+	InitialBackoff time.Duration // Espera antes del segundo intento
+	MaxBackoff     time.Duration // Espera máxima entre intentos
+}
+
+// WithDefaults completa los valores no configurados
+func (p RetryPolicy) WithDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultMaxAttempts
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = DefaultInitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = DefaultMaxBackoff
+	}
+	return p
+}
+
+// Backoff retorna la espera después del intento número attempt (1 = primer intento):
+// crece exponencialmente desde InitialBackoff hasta MaxBackoff, con un jitter de ±20%
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < attempt && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+
+	jitter := time.Duration(rand.Int63n(int64(delay)/5*2+1)) - delay/5
+	return delay + jitter
+}
@@ -1,61 +1,86 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"webhook_receiver/internal/config"
+	"webhook_receiver/internal/logging"
 	"webhook_receiver/internal/router"
 
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 )
 
 func main() {
-	// Cargar variables de entorno
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system environment variables")
+	// Cargar la configuración: archivo (-config o CONFIG_FILE), .env, variables de entorno y flags
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to load configuration", "error", err)
+		os.Exit(1)
 	}
 
-	// Obtener puerto de las variables de entorno
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// Logs estructurados (LOG_LEVEL=debug|info|warn|error, LOG_FORMAT=text|json)
+	if _, err := logging.Setup(cfg.Log.Level, cfg.Log.Format); err != nil {
+		slog.Error("Failed to set up logging", "error", err)
+		os.Exit(1)
 	}
 
 	// Crear router
-	router := router.NewRouter()
-
-	// Configurar modo de Gin
-	gin.SetMode(getGinMode())
+	engine, cleanup, err := router.NewRouter(cfg)
+	if err != nil {
+		slog.Error("Failed to start webhook receiver", "error", err)
+		os.Exit(1)
+	}
 
 	// Iniciar servidor
-	log.Printf("🚀 Webhook Receiver starting on port %s", port)
-	log.Printf("📋 Available endpoints:")
-	log.Printf("   GET  /health - Health check")
-	log.Printf("   POST /webhook - Receive webhooks (requires signature verification)")
-
+	endpoints := []string{"GET /health", "POST /webhook"}
 	if gin.Mode() != gin.ReleaseMode {
-		log.Printf("   GET  / - Service information")
+		endpoints = append(endpoints, "GET /")
 	}
+	slog.Info("🚀 Webhook Receiver starting", "port", cfg.Port, "mode", cfg.Mode, "endpoints", endpoints)
 
-	if err := router.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	server := &http.Server{
+		Addr:    ":" + cfg.Port,
+		Handler: engine,
 	}
-}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	// Esperar SIGINT/SIGTERM o un error del servidor
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 
-// getGinMode retorna el modo de Gin basado en variables de entorno
-func getGinMode() string {
-	env := os.Getenv("GIN_MODE")
-	if env == "" {
-		env = os.Getenv("GO_ENV")
+	exitCode := 0
+	select {
+	case err := <-serveErr:
+		slog.Error("Failed to start server", "error", err)
+		exitCode = 1
+	case <-ctx.Done():
+		slog.Info("Shutting down webhook receiver", "timeout", cfg.ShutdownTimeout)
 	}
+	stop()
 
-	switch env {
-	case "production", "prod":
-		return gin.ReleaseMode
-	case "test":
-		return gin.TestMode
-	default:
-		return gin.DebugMode
+	// Apagado ordenado: dejar de aceptar peticiones y terminar las que están en curso, luego
+	// esperar a los webhooks encolados y cerrar el inbox, los sinks y la base de datos
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Failed to shut down HTTP server", "error", err)
+		exitCode = 1
+	}
+	if err := cleanup(shutdownCtx); err != nil {
+		slog.Error("Failed to release resources", "error", err)
+		exitCode = 1
 	}
+	cancel()
+	os.Exit(exitCode)
 }